@@ -11,6 +11,9 @@ import (
 	"github.com/LittleAksMax/bids-policy-service/internal/cache"
 	"github.com/LittleAksMax/bids-policy-service/internal/config"
 	"github.com/LittleAksMax/bids-policy-service/internal/db"
+	"github.com/LittleAksMax/bids-policy-service/internal/events"
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/LittleAksMax/bids-policy-service/internal/service"
 	"github.com/joho/godotenv"
 )
 
@@ -48,17 +51,102 @@ func main() {
 		}
 	}()
 
-	cacheCfg, err := cache.NewRedisRefreshStore(ctx, cfg.PolicyCache)
-	if err != nil {
-		log.Fatalf("cache connect error: %v", err)
+	var cacheCfg cache.RequestCache
+	if cfg.PolicyCache.Driver == config.CacheDriverMemory {
+		cacheCfg = cache.NewMemoryCache(cfg.PolicyCache.MaxEntries)
+	} else {
+		redisCache := cache.NewRedisCache(ctx, cfg.PolicyCache.Redis, cfg.PolicyCache.Namespace)
+		defer func() {
+			if err := redisCache.Client.Close(); err != nil {
+				log.Printf("redis close error: %v", err)
+			}
+		}()
+		cacheCfg = redisCache
+
+		if cfg.PolicyCache.Driver == config.CacheDriverTiered {
+			tieredCache := cache.NewTieredCache(cache.NewMemoryCache(cfg.PolicyCache.MaxEntries), redisCache, cfg.PolicyCache.L1TTL)
+			go tieredCache.Run(ctx)
+			cacheCfg = tieredCache
+		}
 	}
-	defer func() {
-		if err := cacheCfg.Client.Close(); err != nil {
-			log.Printf("redis close error: %v", err)
+
+	// Probe the database in the background while it is unavailable
+	go dbCfg.Breaker.Run(ctx, dbCfg.HealthCheck)
+
+	mongoPolicyRepo := repository.NewMongoPolicyRepository(dbCfg.Database)
+	policyRepo := repository.NewBreakerPolicyRepository(mongoPolicyRepo, dbCfg.Breaker)
+	approvalRepo := repository.NewMongoApprovalRepository(dbCfg.Database)
+	scheduleRepo := repository.NewMongoScheduleRepository(dbCfg.Database)
+	mongoAssignmentRepo := repository.NewMongoAssignmentRepository(dbCfg.Database)
+	assignmentRepo := repository.NewBreakerAssignmentRepository(mongoAssignmentRepo, dbCfg.Breaker)
+	outboxRepo := repository.NewMongoOutboxRepository(dbCfg.Database)
+	webhookRepo := repository.NewMongoWebhookRepository(dbCfg.Database)
+	changeRepo := repository.NewMongoChangeStreamRepository(dbCfg.Database)
+
+	convertService := service.NewConvertService()
+	policyService := service.NewPolicyService(policyRepo, cacheCfg, convertService, approvalRepo, assignmentRepo, cfg.Currency.ExchangeRates, service.CacheTTLs{
+		Policy:   cfg.PolicyCache.PolicyTTL,
+		NotFound: cfg.PolicyCache.NotFoundTTL,
+		List:     cfg.PolicyCache.ListTTL,
+		Stale:    cfg.PolicyCache.StaleTTL,
+	})
+	scheduleService := service.NewScheduleService(scheduleRepo, policyService)
+	webhookService := service.NewWebhookService(webhookRepo, outboxRepo, cfg.Webhooks.Timeout, cfg.Webhooks.MaxAttempts, cfg.Webhooks.Backoff)
+
+	// Build indexes and backfill data in the background, retrying while the database is
+	// unavailable, so that the service can start serving cached policies without it
+	go func() {
+		err := dbCfg.Breaker.Setup(ctx,
+			db.SetupStep{Name: "policy indexes", Run: mongoPolicyRepo.EnsureIndexes},
+			db.SetupStep{Name: "unique policy names", Run: mongoPolicyRepo.MigrateUniqueNames},
+			db.SetupStep{Name: "approval indexes", Run: approvalRepo.EnsureIndexes},
+			db.SetupStep{Name: "schedule indexes", Run: scheduleRepo.EnsureIndexes},
+			db.SetupStep{Name: "assignment indexes", Run: mongoAssignmentRepo.EnsureIndexes},
+			db.SetupStep{Name: "policy event indexes", Run: outboxRepo.EnsureIndexes},
+			db.SetupStep{Name: "policy event retention", Run: func(ctx context.Context) error {
+				return outboxRepo.EnsureRetention(ctx, cfg.Events.Retention)
+			}},
+			db.SetupStep{Name: "webhook indexes", Run: webhookRepo.EnsureIndexes},
+			db.SetupStep{Name: "webhook delivery retention", Run: func(ctx context.Context) error {
+				return webhookRepo.EnsureRetention(ctx, cfg.Events.Retention)
+			}},
+			// Without pre-images, purged policies are not evicted from the cache
+			db.SetupStep{Name: "policy pre-images", Run: changeRepo.EnablePreImages, Optional: true},
+			// Summarise policies stored before summaries covered everything they do now, so
+			// they can be filtered on; until then they are missing from filtered listings
+			db.SetupStep{Name: "policy summaries", Run: policyService.BackfillSummaries, Optional: true},
+		)
+		if err != nil {
+			log.Fatalf("database setup failed: %v", err)
 		}
 	}()
 
-	r := api.NewRouter(cfg, dbCfg, cacheCfg)
+	// Evict the cache and publish events for every policy change, including ones made directly in the database
+	bus := events.NewBus()
+	watcher := service.NewPolicyWatcher(changeRepo, policyService, bus, cfg.PolicyDB.RetryInterval)
+	go watcher.Run(ctx)
+
+	// Purge policies that have outlived their time in the trash
+	sweeper := service.NewTrashSweeper(policyRepo, cfg.Trash.Retention, cfg.Trash.SweepInterval)
+	go sweeper.Run(ctx)
+
+	// Apply policy windows and scheduled script changes as they fall due
+	scheduler := service.NewPolicyScheduler(scheduleService, cfg.Scheduler.Interval)
+	go scheduler.Run(ctx)
+
+	// Deliver policy events recorded in the outbox to webhook subscriptions
+	dispatcher := service.NewWebhookDispatcher(webhookService, cfg.Webhooks.DispatchInterval)
+	go dispatcher.Run(ctx)
+
+	r := api.NewRouter(cfg, dbCfg, cacheCfg, api.Services{
+		Convert:     convertService,
+		Policies:    policyService,
+		Approvals:   service.NewApprovalService(approvalRepo, scheduleRepo, policyService),
+		Schedules:   scheduleService,
+		Assignments: service.NewAssignmentService(assignmentRepo, policyService),
+		Webhooks:    webhookService,
+		Events:      service.NewPolicyEventService(outboxRepo, bus),
+	})
 	addr := fmt.Sprintf(":%d", cfg.Port)
 
 	log.Printf("starting server on %s (mode=%s)", addr, mode)
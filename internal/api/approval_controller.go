@@ -0,0 +1,226 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/LittleAksMax/bids-policy-service/internal/service"
+	"github.com/LittleAksMax/bids-util/requests"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type ApprovalController struct {
+	service service.ApprovalServiceInterface
+}
+
+func NewApprovalController(service service.ApprovalServiceInterface) *ApprovalController {
+	return &ApprovalController{service: service}
+}
+
+// GetApproversHandler lists the account's approvers (REST GET /approvers)
+func (ac *ApprovalController) GetApproversHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	approvers, err := ac.service.GetApprovers(r.Context(), userID)
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+			Success: false,
+			Error:   "failed to retrieve approvers",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    approvers,
+	})
+}
+
+// SetApproversHandler replaces an account's approvers: the user's own when opting in
+// (REST PUT /approvers), or those of an account they approve for (REST PUT /approvers/{ownerID})
+func (ac *ApprovalController) SetApproversHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+	ownerID := userID
+	if param := chi.URLParam(r, "ownerID"); param != "" {
+		parsed, err := uuid.Parse(param)
+		if err != nil {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+				Success: false,
+				Error:   "invalid owner ID",
+			})
+			return
+		}
+		ownerID = parsed
+	}
+
+	setReq := requests.GetRequestBody[SetApproversRequest](r)
+	if setReq == nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   "invalid request body",
+		})
+		return
+	}
+
+	approvers, err := ac.service.SetApprovers(r.Context(), userID, ownerID, setReq.ApproverIDs)
+	switch {
+	case errors.Is(err, service.ErrInvalidApprover):
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	case errors.Is(err, service.ErrApproversLocked):
+		requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	case errors.Is(err, service.ErrPendingApprovals):
+		requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	case err != nil:
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+			Success: false,
+			Error:   "failed to update approvers",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    approvers,
+	})
+}
+
+// ListChangeRequestsHandler lists change requests on the user's policies and on those they
+// approve for (REST GET /change-requests). Pending requests are listed unless a status is given;
+// status=all lists every request.
+func (ac *ApprovalController) ListChangeRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = repository.ChangeRequestPending
+	case statusQueryAll:
+		status = ""
+	case repository.ChangeRequestPending, repository.ChangeRequestApproved, repository.ChangeRequestRejected:
+	default:
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   "invalid status: " + status,
+		})
+		return
+	}
+
+	changeRequests, err := ac.service.ListChangeRequests(r.Context(), userID, status)
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+			Success: false,
+			Error:   "failed to list change requests",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    changeRequests,
+	})
+}
+
+// GetChangeRequestHandler retrieves a single change request (REST GET /change-requests/{id})
+func (ac *ApprovalController) GetChangeRequestHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	changeRequest, err := ac.service.GetChangeRequest(r.Context(), userID, id)
+	writeChangeRequestResult(w, changeRequest, err, "failed to retrieve change request")
+}
+
+// ApproveChangeRequestHandler approves and applies a change request
+// (REST POST /change-requests/{id}/approve)
+func (ac *ApprovalController) ApproveChangeRequestHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	changeRequest, err := ac.service.ApproveChangeRequest(r.Context(), userID, id)
+	writeChangeRequestResult(w, changeRequest, err, "failed to approve change request")
+}
+
+// RejectChangeRequestHandler rejects a change request (REST POST /change-requests/{id}/reject)
+func (ac *ApprovalController) RejectChangeRequestHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	rejectReq := requests.GetRequestBody[RejectChangeRequestRequest](r)
+	if rejectReq == nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   "invalid request body",
+		})
+		return
+	}
+
+	changeRequest, err := ac.service.RejectChangeRequest(r.Context(), userID, id, rejectReq.Comment)
+	writeChangeRequestResult(w, changeRequest, err, "failed to reject change request")
+}
+
+// CommentOnChangeRequestHandler adds a comment to a change request
+// (REST POST /change-requests/{id}/comments)
+func (ac *ApprovalController) CommentOnChangeRequestHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	commentReq := requests.GetRequestBody[ChangeCommentRequest](r)
+	if commentReq == nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   "invalid request body",
+		})
+		return
+	}
+
+	changeRequest, err := ac.service.CommentOnChangeRequest(r.Context(), userID, id, commentReq.Body)
+	writeChangeRequestResult(w, changeRequest, err, "failed to comment on change request")
+}
+
+func writeChangeRequestResult(w http.ResponseWriter, changeRequest *repository.ChangeRequest, err error, failure string) {
+	switch {
+	case errors.Is(err, service.ErrNotApprover), errors.Is(err, service.ErrSelfApproval):
+		requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	case errors.Is(err, service.ErrChangeRequestClosed), errors.Is(err, service.ErrStaleChangeRequest):
+		requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	case err != nil:
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+			Success: false,
+			Error:   failure,
+		})
+		return
+	}
+	if changeRequest == nil {
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{
+			Success: false,
+			Error:   "change request not found",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    changeRequest,
+	})
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/LittleAksMax/bids-policy-service/internal/service"
+	"github.com/LittleAksMax/bids-util/requests"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AssignmentController struct {
+	service service.AssignmentServiceInterface
+}
+
+func NewAssignmentController(service service.AssignmentServiceInterface) *AssignmentController {
+	return &AssignmentController{service: service}
+}
+
+// CreateAssignmentHandler assigns a policy to an entity (REST POST /assignments)
+func (ac *AssignmentController) CreateAssignmentHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	createReq := requests.GetRequestBody[CreateAssignmentRequest](r)
+	if createReq == nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   "invalid request body",
+		})
+		return
+	}
+
+	assignment, err := ac.service.CreateAssignment(r.Context(), userID, createReq.Marketplace, createReq.EntityType, createReq.EntityID, createReq.PolicyID)
+	if writeAssignmentError(w, err, "failed to create assignment") {
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusCreated, requests.APIResponse{
+		Success: true,
+		Data:    assignment,
+	})
+}
+
+// ListAssignmentsHandler lists the user's assignments, optionally filtered by marketplace,
+// entity_type and policy_id (REST GET /assignments)
+func (ac *AssignmentController) ListAssignmentsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+	query := r.URL.Query()
+
+	filter := repository.AssignmentFilter{
+		Marketplace: query.Get("marketplace"),
+		EntityType:  query.Get("entity_type"),
+	}
+	if filter.EntityType != "" && !repository.IsValidEntityType(filter.EntityType) {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   "invalid entity_type: " + filter.EntityType,
+		})
+		return
+	}
+	if raw := query.Get("policy_id"); raw != "" {
+		policyID, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+				Success: false,
+				Error:   "invalid policy_id: " + raw,
+			})
+			return
+		}
+		filter.PolicyID = &policyID
+	}
+
+	assignments, err := ac.service.ListAssignments(r.Context(), userID, filter)
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+			Success: false,
+			Error:   "failed to list assignments",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    assignments,
+	})
+}
+
+// GetAssignmentHandler retrieves a single assignment (REST GET /assignments/{id})
+func (ac *AssignmentController) GetAssignmentHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	assignment, err := ac.service.GetAssignment(r.Context(), userID, id)
+	if writeAssignmentError(w, err, "failed to retrieve assignment") {
+		return
+	}
+	writeAssignment(w, assignment)
+}
+
+// UpdateAssignmentHandler points an assignment at another policy (REST PUT /assignments/{id})
+func (ac *AssignmentController) UpdateAssignmentHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	updateReq := requests.GetRequestBody[UpdateAssignmentRequest](r)
+	if updateReq == nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   "invalid request body",
+		})
+		return
+	}
+
+	assignment, err := ac.service.UpdateAssignment(r.Context(), userID, id, updateReq.PolicyID)
+	if writeAssignmentError(w, err, "failed to update assignment") {
+		return
+	}
+	writeAssignment(w, assignment)
+}
+
+// DeleteAssignmentHandler removes an assignment (REST DELETE /assignments/{id})
+func (ac *AssignmentController) DeleteAssignmentHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	deleted, err := ac.service.DeleteAssignment(r.Context(), userID, id)
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+			Success: false,
+			Error:   "failed to delete assignment",
+		})
+		return
+	}
+	if !deleted {
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{
+			Success: false,
+			Error:   "assignment not found",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+	})
+}
+
+// ResolveAssignmentHandler returns the active policy in effect for an entity, along with any
+// assignments skipped because their policy is gone or not active
+// (GET /internal/assignments/resolve?marketplace=UK&campaign_id=...&ad_group_id=...&target_id=...)
+func (ac *AssignmentController) ResolveAssignmentHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+	query := r.URL.Query()
+
+	marketplace := query.Get("marketplace")
+	if !repository.IsValidMarketplace(marketplace) {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   "invalid marketplace: " + marketplace,
+		})
+		return
+	}
+	entityIDs := map[string]string{
+		repository.EntityCampaign: query.Get("campaign_id"),
+		repository.EntityAdGroup:  query.Get("ad_group_id"),
+		repository.EntityTarget:   query.Get("target_id"),
+	}
+
+	resolved, err := ac.service.ResolveAssignment(r.Context(), userID, marketplace, entityIDs)
+	if err != nil {
+		requests.WriteJSON(w, readErrorStatus(w, err), requests.APIResponse{
+			Success: false,
+			Error:   "failed to resolve assignment",
+		})
+		return
+	}
+	if resolved == nil {
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{
+			Success: false,
+			Error:   "no policy assigned",
+		})
+		return
+	}
+	if resolved.Policy == nil {
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{
+			Success: false,
+			Error:   "no active policy assigned",
+			Data:    resolved,
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    resolved,
+	})
+}
+
+func writeAssignment(w http.ResponseWriter, assignment *repository.PolicyAssignment) {
+	if assignment == nil {
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{
+			Success: false,
+			Error:   "assignment not found",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    assignment,
+	})
+}
+
+// writeAssignmentError writes the response for an assignment error and reports whether there was one.
+func writeAssignmentError(w http.ResponseWriter, err error, failure string) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, service.ErrPolicyNotFound), errors.Is(err, service.ErrMarketplaceMismatch):
+		requests.WriteJSON(w, http.StatusUnprocessableEntity, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	case errors.Is(err, repository.ErrAssignmentExists):
+		requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	default:
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+			Success: false,
+			Error:   failure,
+		})
+	}
+	return true
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/cache"
+	"github.com/LittleAksMax/bids-util/requests"
+)
+
+// FlushCache empties the request cache, removing only this service's keys from a shared Redis
+// (REST POST /internal/cache/flush)
+func FlushCache(requestCache cache.RequestCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flushed, err := requestCache.Flush(r.Context())
+		if err != nil {
+			requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+				Success: false,
+				Error:   "failed to flush cache",
+			})
+			return
+		}
+
+		requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+			Success: true,
+			Data:    map[string]int64{"flushed": flushed},
+		})
+	}
+}
@@ -3,3 +3,5 @@ package api
 const userIDHeader = "X-User-ID"
 const uuidSubjectKey = "uuidSubject"
 const apiKeyHeader = "X-Api-Key"
+const idempotencyKeyHeader = "Idempotency-Key"
+const idempotentReplayedHeader = "Idempotent-Replayed"
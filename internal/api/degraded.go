@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/db"
+	"github.com/LittleAksMax/bids-policy-service/internal/service"
+	"github.com/LittleAksMax/bids-util/requests"
+)
+
+// retryAfterSeconds is suggested to clients whose requests fail while the database is unavailable.
+const retryAfterSeconds = 30
+
+// staleWarning is the RFC 7234 warning for a response served from a stale copy.
+const staleWarning = `110 - "Response is Stale"`
+
+// staleResponseWriter adds Warning and Age headers before the response is written if the
+// handler served stale copies of policies.
+type staleResponseWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	wroteHeader bool
+}
+
+func (sw *staleResponseWriter) WriteHeader(status int) {
+	if !sw.wroteHeader {
+		sw.wroteHeader = true
+		if since, stale := service.StaleSince(sw.r.Context()); stale {
+			sw.Header().Set("Warning", staleWarning)
+			sw.Header().Set("Age", strconv.Itoa(int(time.Since(since).Seconds())))
+		}
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *staleResponseWriter) Write(b []byte) (int, error) {
+	if !sw.wroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush it.
+func (sw *staleResponseWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// StaleWarning marks responses served from stale copies of policies, which happens while the
+// database is unavailable, with a Warning header and an Age header giving their staleness.
+func StaleWarning(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(service.TrackStaleness(r.Context()))
+		next.ServeHTTP(&staleResponseWriter{ResponseWriter: w, r: r}, r)
+	})
+}
+
+// ReadOnlyWhileUnavailable rejects requests that may write with 503 Service Unavailable while
+// the database's circuit breaker is open. Reads are let through to be served from the cache.
+func ReadOnlyWhileUnavailable(breaker *db.Breaker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead && breaker.Open() {
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+				requests.WriteJSON(w, http.StatusServiceUnavailable, requests.APIResponse{
+					Success: false,
+					Error:   "database unavailable, changes are disabled until it recovers",
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// readErrorStatus is the status for a failed read: 503 if the database could not be reached
+// and there was nothing cached to serve instead, in which case a Retry-After header is set on
+// w, otherwise 500.
+func readErrorStatus(w http.ResponseWriter, err error) int {
+	if db.IsUnavailable(err) {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
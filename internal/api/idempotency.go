@@ -0,0 +1,177 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/cache"
+	"github.com/LittleAksMax/bids-util/requests"
+	"github.com/google/uuid"
+)
+
+const (
+	// idempotencyTTL is how long a completed response is kept for replay.
+	idempotencyTTL = 24 * time.Hour
+	// idempotencyLockTTL bounds how long an in-flight request holds its key,
+	// so a crashed request does not block retries for the full TTL.
+	idempotencyLockTTL      = time.Minute
+	maxIdempotencyKeyLength = 255
+)
+
+// idempotencyRecord is the cached outcome of a request made with an Idempotency-Key.
+// A record with a zero Status marks a request that is still being processed.
+type idempotencyRecord struct {
+	RequestHash string `json:"request_hash"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// idempotencyRecorder tees the response to the client while keeping a copy for the cache.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Idempotency replays the first response for a given Idempotency-Key header so that
+// retried requests do not repeat their side effects. Keys are scoped per user, and
+// reusing a key with a different request body is rejected.
+// Requests without the header are passed through untouched.
+func Idempotency(requestCache cache.RequestCache) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(idempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+					Success: false,
+					Error:   "idempotency key must be at most 255 characters",
+				})
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+					Success: false,
+					Error:   "invalid request body",
+				})
+				return
+			}
+			// Restore the body for the validation middleware and handler
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			sum := sha256.Sum256(body)
+			requestHash := hex.EncodeToString(sum[:])
+
+			userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+			cacheKey := userID.String() + ":idempotency:" + key
+
+			// Claim the key atomically while the request is processed, so that concurrent
+			// retries cannot both run the handler
+			lock, _ := json.Marshal(idempotencyRecord{RequestHash: requestHash})
+			claimed, err := requestCache.SetNX(r.Context(), cacheKey, string(lock), idempotencyLockTTL)
+			if err != nil {
+				// The cache is only a safeguard; do not fail the request because it is unavailable.
+				log.Printf("failed to claim idempotency key: %v\n", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !claimed {
+				replayIdempotent(w, r, requestCache, cacheKey, requestHash)
+				return
+			}
+
+			rec := &idempotencyRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			// Server errors are not replayed so that the client can retry them
+			if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+				if err := requestCache.Delete(r.Context(), cacheKey); err != nil {
+					log.Printf("failed to release idempotency key: %v\n", err)
+				}
+				return
+			}
+
+			record, _ := json.Marshal(idempotencyRecord{
+				RequestHash: requestHash,
+				Status:      rec.status,
+				ContentType: rec.Header().Get("Content-Type"),
+				Body:        rec.body.Bytes(),
+			})
+			if err := requestCache.Set(r.Context(), cacheKey, string(record), idempotencyTTL); err != nil {
+				log.Printf("failed to store idempotency record: %v\n", err)
+			}
+		})
+	}
+}
+
+// replayIdempotent answers a request whose Idempotency-Key is already claimed: with the stored
+// response once the first request has completed, or with 409 while it is still in flight.
+func replayIdempotent(w http.ResponseWriter, r *http.Request, requestCache cache.RequestCache, cacheKey, requestHash string) {
+	cached, _, err := requestCache.Get(r.Context(), cacheKey)
+	if err != nil {
+		if !errors.Is(err, cache.ErrNotFound) && !errors.Is(err, cache.ErrExpired) {
+			log.Printf("idempotency cache lookup failed: %v\n", err)
+		}
+		// The claim was released between our attempt and the lookup; the client can retry.
+		writeIdempotencyInProgress(w)
+		return
+	}
+
+	var record idempotencyRecord
+	if err := json.Unmarshal([]byte(cached), &record); err != nil {
+		log.Printf("failed to decode idempotency record: %v\n", err)
+		writeIdempotencyInProgress(w)
+		return
+	}
+	if record.RequestHash != requestHash {
+		requests.WriteJSON(w, http.StatusUnprocessableEntity, requests.APIResponse{
+			Success: false,
+			Error:   "idempotency key has already been used with a different request body",
+		})
+		return
+	}
+	if record.Status == 0 {
+		writeIdempotencyInProgress(w)
+		return
+	}
+	if record.ContentType != "" {
+		w.Header().Set("Content-Type", record.ContentType)
+	}
+	w.Header().Set(idempotentReplayedHeader, "true")
+	w.WriteHeader(record.Status)
+	_, _ = w.Write(record.Body)
+}
+
+func writeIdempotencyInProgress(w http.ResponseWriter) {
+	requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{
+		Success: false,
+		Error:   "a request with this idempotency key is already in progress",
+	})
+}
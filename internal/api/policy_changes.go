@@ -0,0 +1,96 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/service"
+	"github.com/LittleAksMax/bids-util/requests"
+)
+
+const (
+	defaultChangesLimit = 500
+	maxChangesLimit     = 1000
+)
+
+// ListPolicyChanges returns the policies created, updated or deleted after the "since" cursor,
+// deleted ones as tombstones, along with the cursor to pass next time. It covers the user in
+// the X-User-ID header, or every user without it, and reads up to "limit" changes
+// (REST GET /internal/policies/changes).
+// Without since, no changes are returned, only the current cursor: a new replica takes it
+// before loading the full listing, and then follows the feed from there without missing a change.
+// Changes are kept for EVENT_RETENTION after they were handed to webhooks, so a cursor stays
+// usable for about that long. For an older one it responds 410, and the replica starts over.
+// Edits made directly in the database, such as by migrations or support scripts, are listed
+// too, once the policy watcher has picked them up from the change stream.
+func ListPolicyChanges(svc service.PolicyEventServiceInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := optionalUserID(w, r)
+		if !ok {
+			return
+		}
+		query := r.URL.Query()
+
+		limit := defaultChangesLimit
+		if raw := query.Get("limit"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 || parsed > maxChangesLimit {
+				requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+					Success: false,
+					Error:   fmt.Sprintf("limit must be between 1 and %d", maxChangesLimit),
+				})
+				return
+			}
+			limit = parsed
+		}
+
+		raw := query.Get("since")
+		if raw == "" {
+			cursor, err := svc.LastEventSeq(r.Context())
+			if err != nil {
+				requests.WriteJSON(w, readErrorStatus(w, err), requests.APIResponse{
+					Success: false,
+					Error:   "failed to retrieve policy changes",
+				})
+				return
+			}
+			requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+				Success: true,
+				Data:    service.ChangeFeed{Changes: []*service.ChangeFeedEntry{}, NextCursor: cursor},
+			})
+			return
+		}
+
+		since, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || since < 0 {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+				Success: false,
+				Error:   "since must be a cursor returned by this endpoint",
+			})
+			return
+		}
+
+		feed, err := svc.ListChanges(r.Context(), since, userID, limit)
+		if errors.Is(err, service.ErrCursorExpired) {
+			requests.WriteJSON(w, http.StatusGone, requests.APIResponse{
+				Success: false,
+				Error:   "cursor has expired, take a new cursor and reload the full listing",
+			})
+			return
+		}
+		if err != nil {
+			requests.WriteJSON(w, readErrorStatus(w, err), requests.APIResponse{
+				Success: false,
+				Error:   "failed to retrieve policy changes",
+			})
+			return
+		}
+
+		requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+			Success: true,
+			Data:    feed,
+		})
+	}
+}
@@ -1,9 +1,15 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/LittleAksMax/bids-policy-service/internal/convert"
 	"github.com/LittleAksMax/bids-policy-service/internal/repository"
 	"github.com/LittleAksMax/bids-policy-service/internal/service"
 	"github.com/LittleAksMax/bids-util/requests"
@@ -30,7 +36,7 @@ func (pc *PolicyController) GetPolicyHandler(w http.ResponseWriter, r *http.Requ
 
 	policy, err := pc.service.GetPolicy(r.Context(), userID, id)
 	if err != nil {
-		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+		requests.WriteJSON(w, readErrorStatus(w, err), requests.APIResponse{
 			Success: false,
 			Error:   "failed to retrieve policy",
 		})
@@ -51,24 +57,36 @@ func (pc *PolicyController) GetPolicyHandler(w http.ResponseWriter, r *http.Requ
 	})
 }
 
-// ListPoliciesHandler lists all policies for the user's marketplace
+// ListPoliciesHandler lists the user's policies, optionally filtered by marketplace, tags,
+// script contents and status (REST GET /policies)
 func (pc *PolicyController) ListPoliciesHandler(w http.ResponseWriter, r *http.Request) {
+	pc.listPolicies(w, r, nil)
+}
+
+// InternalListPoliciesHandler lists a user's policies for the bidder (GET /internal/policies).
+// Only active policies are returned unless status parameters are given; status=all lists every policy.
+func (pc *PolicyController) InternalListPoliciesHandler(w http.ResponseWriter, r *http.Request) {
+	pc.listPolicies(w, r, []string{repository.StatusActive})
+}
+
+func (pc *PolicyController) listPolicies(w http.ResponseWriter, r *http.Request, defaultStatuses []string) {
 	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
-	// Get marketplace from query parameter
-	marketplace := r.URL.Query().Get("marketplace")
 
-	var policies []*repository.Policy
-	var err error = nil
-	if marketplace != "" {
-		// Filter by marketplace
-		policies, err = pc.service.ListPoliciesByMarketplace(r.Context(), userID, marketplace)
-	} else {
-		// List all policies
-		policies, err = pc.service.ListPolicies(r.Context(), userID)
+	filter, err := parsePolicyFilter(r.URL.Query())
+	if err != nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	if filter.Statuses == nil {
+		filter.Statuses = defaultStatuses
 	}
 
+	policies, err := pc.service.ListPoliciesWithFilter(r.Context(), userID, filter)
 	if err != nil {
-		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+		requests.WriteJSON(w, readErrorStatus(w, err), requests.APIResponse{
 			Success: false,
 			Error:   "failed to retrieve policies",
 		})
@@ -81,6 +99,98 @@ func (pc *PolicyController) ListPoliciesHandler(w http.ResponseWriter, r *http.R
 	})
 }
 
+// parsePolicyFilter reads listing filters from the query string.
+// Repeated tag parameters match policies carrying all of the tags, or any of them with tag_mode=any.
+// Repeated metric and operator parameters match policies whose scripts use all of them, and
+// repeated status parameters match any of the statuses. A status of "all" leaves Statuses
+// empty but non-nil, so that no default status filter is applied.
+func parsePolicyFilter(query url.Values) (repository.PolicyFilter, error) {
+	filter := repository.PolicyFilter{
+		Marketplace: query.Get("marketplace"),
+		Tags:        query["tag"],
+	}
+
+	switch query.Get("tag_mode") {
+	case "", TagModeAll:
+		filter.MatchAllTags = true
+	case TagModeAny:
+		filter.MatchAllTags = false
+	default:
+		return filter, errors.New("tag_mode must be one of: " + TagModeAll + ", " + TagModeAny)
+	}
+
+	for _, metric := range query["metric"] {
+		if !convert.Metric(metric).IsValid() {
+			return filter, fmt.Errorf("unknown metric '%s'", metric)
+		}
+		filter.Metrics = append(filter.Metrics, metric)
+	}
+
+	for _, raw := range query["operator"] {
+		operator, ok := operatorQueryValues[raw]
+		if !ok {
+			return filter, errors.New("operator must be one of: +, -, = (or add, sub, set)")
+		}
+		filter.Operators = append(filter.Operators, operator)
+	}
+
+	for _, status := range query["status"] {
+		if status == statusQueryAll {
+			filter.Statuses = []string{}
+			return filter, nil
+		}
+		if !repository.IsValidStatus(status) {
+			return filter, fmt.Errorf("unknown status '%s'", status)
+		}
+		filter.Statuses = append(filter.Statuses, status)
+	}
+
+	return filter, nil
+}
+
+// SearchPoliciesHandler runs a full-text search over policy names and descriptions
+// (REST GET /policies/search?q=), optionally within a marketplace
+func (pc *PolicyController) SearchPoliciesHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+	query := r.URL.Query()
+
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   "q is required",
+		})
+		return
+	}
+
+	limit := defaultSearchLimit
+	if raw := query.Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxSearchLimit {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+				Success: false,
+				Error:   fmt.Sprintf("limit must be between 1 and %d", maxSearchLimit),
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	results, err := pc.service.SearchPolicies(r.Context(), userID, q, query.Get("marketplace"), limit)
+	if err != nil {
+		requests.WriteJSON(w, readErrorStatus(w, err), requests.APIResponse{
+			Success: false,
+			Error:   "failed to search policies",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    results,
+	})
+}
+
 // CreatePolicyHandler creates a new policy (REST POST /policies)
 func (pc *PolicyController) CreatePolicyHandler(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
@@ -96,7 +206,14 @@ func (pc *PolicyController) CreatePolicyHandler(w http.ResponseWriter, r *http.R
 	}
 
 	// Call service directly with extracted fields
-	policy, err := pc.service.CreatePolicy(r.Context(), userID, createReq.Marketplace, createReq.Name, strings.ToLower(createReq.Script))
+	policy, err := pc.service.CreatePolicy(r.Context(), userID, createReq.Marketplace, createReq.Status, createReq.fields(), createReq.AutoSuffix)
+	if errors.Is(err, repository.ErrDuplicatePolicyName) {
+		requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("a policy named %q already exists in marketplace %s", createReq.Name, createReq.Marketplace),
+		})
+		return
+	}
 	if err != nil {
 		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
 			Success: false,
@@ -127,7 +244,21 @@ func (pc *PolicyController) UpdatePolicyHandler(w http.ResponseWriter, r *http.R
 	}
 
 	// Call service directly with extracted fields
-	policy, err := pc.service.UpdatePolicy(r.Context(), userID, id, updateReq.Name, strings.ToLower(updateReq.Script))
+	policy, err := pc.service.UpdatePolicy(r.Context(), userID, id, updateReq.fields())
+	if errors.Is(err, repository.ErrDuplicatePolicyName) {
+		requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("a policy named %q already exists in this marketplace", updateReq.Name),
+		})
+		return
+	}
+	if errors.Is(err, service.ErrPolicyArchived) || errors.Is(err, service.ErrPolicyChanged) {
+		requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
 			Success: false,
@@ -150,13 +281,20 @@ func (pc *PolicyController) UpdatePolicyHandler(w http.ResponseWriter, r *http.R
 	})
 }
 
-// DeletePolicyHandler deletes a policy (REST DELETE /policies/{id})
+// DeletePolicyHandler moves a policy to the trash (REST DELETE /policies/{id})
 func (pc *PolicyController) DeletePolicyHandler(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
 
 	// Delete policy
 	ok, err := pc.service.DeletePolicy(r.Context(), userID, id)
+	if errors.Is(err, service.ErrPolicyAssigned) {
+		requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
 			Success: false,
@@ -177,3 +315,584 @@ func (pc *PolicyController) DeletePolicyHandler(w http.ResponseWriter, r *http.R
 		Data:    map[string]string{"id": id},
 	})
 }
+
+// TransitionPolicyHandler moves a policy to status to
+// (REST POST /policies/{id}/pause and /policies/{id}/archive)
+func (pc *PolicyController) TransitionPolicyHandler(to string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+		policy, err := pc.service.TransitionPolicy(r.Context(), userID, id, to)
+		if errors.Is(err, service.ErrInvalidTransition) {
+			requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+				Success: false,
+				Error:   "failed to change policy status",
+			})
+			return
+		}
+		if policy == nil {
+			requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{
+				Success: false,
+				Error:   "policy not found",
+			})
+			return
+		}
+
+		requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+			Success: true,
+			Data:    policy,
+		})
+	}
+}
+
+// SaveDraftHandler replaces a policy's draft script (REST PUT /policies/{id}/draft)
+func (pc *PolicyController) SaveDraftHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	draftReq := requests.GetRequestBody[SaveDraftRequest](r)
+	if draftReq == nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   "invalid request body",
+		})
+		return
+	}
+
+	policy, err := pc.service.SaveDraft(r.Context(), userID, id, strings.ToLower(draftReq.Script))
+	pc.writeDraftResult(w, policy, err)
+}
+
+// DiscardDraftHandler drops a policy's draft script (REST DELETE /policies/{id}/draft)
+func (pc *PolicyController) DiscardDraftHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	policy, err := pc.service.DiscardDraft(r.Context(), userID, id)
+	pc.writeDraftResult(w, policy, err)
+}
+
+func (pc *PolicyController) writeDraftResult(w http.ResponseWriter, policy *repository.Policy, err error) {
+	if errors.Is(err, service.ErrPolicyArchived) {
+		requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+			Success: false,
+			Error:   "failed to update draft",
+		})
+		return
+	}
+	if policy == nil {
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{
+			Success: false,
+			Error:   "policy not found",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    policy,
+	})
+}
+
+// PublishPolicyHandler promotes a policy's draft, if any, and activates the policy
+// (REST POST /policies/{id}/publish). When the change needs approval it responds 202 with
+// the change request instead.
+func (pc *PolicyController) PublishPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	policy, changeRequest, err := pc.service.PublishPolicy(r.Context(), userID, id)
+	var draftErr *service.DraftValidationError
+	switch {
+	case errors.As(err, &draftErr):
+		requests.WriteJSON(w, http.StatusUnprocessableEntity, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	case errors.Is(err, service.ErrInvalidTransition), errors.Is(err, service.ErrNothingToPublish), errors.Is(err, repository.ErrPendingChangeExists):
+		requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	case err != nil:
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+			Success: false,
+			Error:   "failed to publish policy",
+		})
+		return
+	}
+	if changeRequest != nil {
+		requests.WriteJSON(w, http.StatusAccepted, requests.APIResponse{
+			Success: true,
+			Data:    changeRequest,
+		})
+		return
+	}
+	if policy == nil {
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{
+			Success: false,
+			Error:   "policy not found",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    policy,
+	})
+}
+
+// ClonePolicyHandler copies a policy into other marketplaces as drafts, converting its amounts
+// into each marketplace's currency (REST POST /policies/{id}/clone)
+func (pc *PolicyController) ClonePolicyHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	cloneReq := requests.GetRequestBody[ClonePolicyRequest](r)
+	if cloneReq == nil || len(cloneReq.Marketplaces) == 0 {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   "invalid request body",
+		})
+		return
+	}
+
+	policies, err := pc.service.ClonePolicy(r.Context(), userID, id, cloneReq.Marketplaces)
+	if errors.Is(err, service.ErrNoExchangeRate) || errors.Is(err, service.ErrInvalidClone) {
+		requests.WriteJSON(w, http.StatusUnprocessableEntity, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+			Success: false,
+			Error:   "failed to clone policy",
+		})
+		return
+	}
+	if policies == nil {
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{
+			Success: false,
+			Error:   "policy not found",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusCreated, requests.APIResponse{
+		Success: true,
+		Data:    policies,
+	})
+}
+
+// SetDefaultPolicyHandler makes a policy its marketplace's default (REST PUT /policies/{id}/default)
+func (pc *PolicyController) SetDefaultPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	policy, err := pc.service.SetDefaultPolicy(r.Context(), userID, id)
+	pc.writeDefaultResult(w, policy, err)
+}
+
+// UnsetDefaultPolicyHandler stops a policy being its marketplace's default (REST DELETE /policies/{id}/default)
+func (pc *PolicyController) UnsetDefaultPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	policy, err := pc.service.UnsetDefaultPolicy(r.Context(), userID, id)
+	pc.writeDefaultResult(w, policy, err)
+}
+
+func (pc *PolicyController) writeDefaultResult(w http.ResponseWriter, policy *repository.Policy, err error) {
+	if errors.Is(err, service.ErrPolicyArchived) || errors.Is(err, repository.ErrDefaultPolicyExists) {
+		requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+			Success: false,
+			Error:   "failed to update default policy",
+		})
+		return
+	}
+	if policy == nil {
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{
+			Success: false,
+			Error:   "policy not found",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    policy,
+	})
+}
+
+// InternalDefaultPolicyHandler returns a user's active default policy for a marketplace, for the
+// bidder to fall back to for entities without an assignment (GET /internal/policies/default?marketplace=UK)
+func (pc *PolicyController) InternalDefaultPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	marketplace := r.URL.Query().Get("marketplace")
+	if !repository.IsValidMarketplace(marketplace) {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   "invalid marketplace: " + marketplace,
+		})
+		return
+	}
+
+	policy, err := pc.service.GetDefaultPolicy(r.Context(), userID, marketplace)
+	if err != nil {
+		requests.WriteJSON(w, readErrorStatus(w, err), requests.APIResponse{
+			Success: false,
+			Error:   "failed to retrieve default policy",
+		})
+		return
+	}
+	if policy == nil || policy.Status != repository.StatusActive {
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{
+			Success: false,
+			Error:   "no active default policy for marketplace",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    policy,
+	})
+}
+
+// SetDaypartsHandler replaces a policy's dayparts (REST PUT /policies/{id}/dayparts). When the
+// change needs approval it responds 202 with the change request instead.
+func (pc *PolicyController) SetDaypartsHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	daypartsReq := requests.GetRequestBody[SetDaypartsRequest](r)
+	if daypartsReq == nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   "invalid request body",
+		})
+		return
+	}
+
+	policy, changeRequest, err := pc.service.SetDayparts(r.Context(), userID, id, daypartsReq.dayparts())
+	var draftErr *service.DraftValidationError
+	switch {
+	case errors.Is(err, service.ErrInvalidDaypart):
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	case errors.As(err, &draftErr):
+		requests.WriteJSON(w, http.StatusUnprocessableEntity, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	case errors.Is(err, service.ErrPolicyArchived), errors.Is(err, service.ErrPolicyChanged), errors.Is(err, repository.ErrPendingChangeExists):
+		requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	case err != nil:
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+			Success: false,
+			Error:   "failed to set dayparts",
+		})
+		return
+	}
+	if changeRequest != nil {
+		requests.WriteJSON(w, http.StatusAccepted, requests.APIResponse{
+			Success: true,
+			Data:    changeRequest,
+		})
+		return
+	}
+	if policy == nil {
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{
+			Success: false,
+			Error:   "policy not found",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    policy,
+	})
+}
+
+// ResolvePolicyHandler returns the script and tree a policy runs at the instant given by the
+// RFC 3339 "at" parameter, or now (REST GET /policies/{id}/resolve, GET /internal/policies/{id}/resolve)
+func (pc *PolicyController) ResolvePolicyHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	at := time.Now()
+	if raw := r.URL.Query().Get("at"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+				Success: false,
+				Error:   "at must be an RFC 3339 timestamp",
+			})
+			return
+		}
+		at = parsed
+	}
+
+	resolved, err := pc.service.ResolvePolicy(r.Context(), userID, id, at)
+	if err != nil {
+		requests.WriteJSON(w, readErrorStatus(w, err), requests.APIResponse{
+			Success: false,
+			Error:   "failed to resolve policy",
+		})
+		return
+	}
+	if resolved == nil {
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{
+			Success: false,
+			Error:   "policy not found",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    resolved,
+	})
+}
+
+// ListTrashHandler lists the policies in the user's trash (REST GET /policies/trash)
+func (pc *PolicyController) ListTrashHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	policies, err := pc.service.ListDeletedPolicies(r.Context(), userID)
+	if err != nil {
+		requests.WriteJSON(w, readErrorStatus(w, err), requests.APIResponse{
+			Success: false,
+			Error:   "failed to retrieve deleted policies",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    policies,
+	})
+}
+
+// RestorePolicyHandler takes a policy out of the trash (REST POST /policies/{id}/restore)
+func (pc *PolicyController) RestorePolicyHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	policy, err := pc.service.RestorePolicy(r.Context(), userID, id)
+	if errors.Is(err, repository.ErrDuplicatePolicyName) {
+		requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{
+			Success: false,
+			Error:   "another policy in the marketplace now has this policy's name; rename it before restoring",
+		})
+		return
+	}
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+			Success: false,
+			Error:   "failed to restore policy",
+		})
+		return
+	}
+	if policy == nil {
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{
+			Success: false,
+			Error:   "policy not found in trash",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    policy,
+	})
+}
+
+// BatchPoliciesHandler applies a list of create, update and delete operations (REST POST /policies:batch)
+func (pc *PolicyController) BatchPoliciesHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	batchReq := requests.GetRequestBody[BatchPoliciesRequest](r)
+	if batchReq == nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   "invalid request body",
+		})
+		return
+	}
+
+	mode := batchReq.Mode
+	if mode == "" {
+		mode = BatchModeAtomic
+	}
+	if mode != BatchModeAtomic && mode != BatchModeBestEffort {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   "mode must be one of: " + BatchModeAtomic + ", " + BatchModeBestEffort,
+		})
+		return
+	}
+	if len(batchReq.Operations) == 0 || len(batchReq.Operations) > maxBatchOperations {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("operations must contain between 1 and %d items", maxBatchOperations),
+		})
+		return
+	}
+	if mode == BatchModeAtomic && len(batchReq.Operations) > maxAtomicBatchOperations {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("atomic batches may contain at most %d operations; split the batch or use mode %s", maxAtomicBatchOperations, BatchModeBestEffort),
+		})
+		return
+	}
+
+	// Validate every operation up front; positions maps executed operations back to the request
+	results := make([]service.BatchResult, len(batchReq.Operations))
+	ops := make([]service.BatchOperation, 0, len(batchReq.Operations))
+	positions := make([]int, 0, len(batchReq.Operations))
+	invalid := false
+	for i, item := range batchReq.Operations {
+		op, err := toBatchOperation(item)
+		if err != nil {
+			results[i] = service.BatchResult{Index: i, Op: service.BatchOperationType(item.Op), ID: item.ID, Error: err.Error()}
+			invalid = true
+			continue
+		}
+		ops = append(ops, op)
+		positions = append(positions, i)
+	}
+
+	if invalid && mode == BatchModeAtomic {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   "one or more operations are invalid",
+			Data:    BatchPoliciesResponse{Mode: mode, Results: results},
+		})
+		return
+	}
+
+	executed, err := pc.service.BatchPolicies(r.Context(), userID, ops, mode == BatchModeAtomic)
+	for j, result := range executed {
+		result.Index = positions[j]
+		results[positions[j]] = result
+	}
+	if err != nil {
+		if errors.Is(err, service.ErrBatchAborted) {
+			statusCode := http.StatusInternalServerError
+			if errors.Is(err, service.ErrPolicyNotFound) || errors.Is(err, service.ErrPolicyArchived) ||
+				errors.Is(err, service.ErrPolicyAssigned) || errors.Is(err, repository.ErrDuplicatePolicyName) ||
+				errors.Is(err, service.ErrPolicyChanged) {
+				statusCode = http.StatusConflict
+			}
+			requests.WriteJSON(w, statusCode, requests.APIResponse{
+				Success: false,
+				Error:   "batch aborted, no changes were applied",
+				Data:    BatchPoliciesResponse{Mode: mode, Results: results},
+			})
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+			Success: false,
+			Error:   "failed to apply batch",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    BatchPoliciesResponse{Mode: mode, Results: results},
+	})
+}
+
+// toBatchOperation validates a batch item with the rules of its single-policy endpoint.
+func toBatchOperation(item BatchPolicyOperation) (service.BatchOperation, error) {
+	op := service.BatchOperation{
+		Type:        service.BatchOperationType(item.Op),
+		ID:          item.ID,
+		Marketplace: item.Marketplace,
+		Status:      item.Status,
+	}
+
+	var target any
+	switch op.Type {
+	case service.BatchOperationCreate:
+		description := ""
+		if item.Description != nil {
+			description = *item.Description
+		}
+		createReq := &CreatePolicyRequest{
+			Marketplace: item.Marketplace,
+			Status:      item.Status,
+			Name:        item.Name,
+			Description: description,
+			Script:      item.Script,
+			Tags:        item.Tags,
+		}
+		op.Fields = createReq.fields()
+		target = createReq
+	case service.BatchOperationUpdate:
+		if item.ID == "" {
+			return op, errors.New("id is required")
+		}
+		updateReq := &UpdatePolicyRequest{
+			Name:        item.Name,
+			Description: item.Description,
+			Script:      item.Script,
+			Tags:        item.Tags,
+		}
+		op.Fields = updateReq.fields()
+		target = updateReq
+	case service.BatchOperationDelete:
+		if item.ID == "" {
+			return op, errors.New("id is required")
+		}
+		return op, nil
+	default:
+		return op, fmt.Errorf("op must be one of: %s, %s, %s",
+			service.BatchOperationCreate, service.BatchOperationUpdate, service.BatchOperationDelete)
+	}
+
+	for _, validate := range policyValidationFuncs {
+		if err := validate(target); err != nil {
+			return op, err
+		}
+	}
+	return op, nil
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/LittleAksMax/bids-policy-service/internal/service"
+	"github.com/LittleAksMax/bids-util/requests"
+	"github.com/google/uuid"
+)
+
+const lastEventIDHeader = "Last-Event-ID"
+
+// streamRetry is the reconnection delay suggested to clients whose stream is cut off.
+const streamRetry = 3 * time.Second
+
+// StreamPolicyEvents streams policy create, update and delete events as server-sent events, for
+// the user in the X-User-ID header or for every user without it (REST GET /internal/policies/stream).
+// Each event's ID is its sequence number, so a client reconnecting with Last-Event-ID resumes
+// after the last event it saw; without it, the stream starts with the next change.
+// Edits made directly in the database are streamed too, once the policy watcher has recorded
+// them from the change stream.
+func StreamPolicyEvents(svc service.PolicyEventServiceInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := optionalUserID(w, r)
+		if !ok {
+			return
+		}
+
+		afterSeq, err := streamStart(r.Context(), svc, r.Header.Get(lastEventIDHeader))
+		if errors.Is(err, strconv.ErrSyntax) {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+				Success: false,
+				Error:   lastEventIDHeader + " must be an event ID from this stream",
+			})
+			return
+		}
+		if err != nil {
+			requests.WriteJSON(w, readErrorStatus(w, err), requests.APIResponse{
+				Success: false,
+				Error:   "failed to open policy stream",
+			})
+			return
+		}
+
+		rc := http.NewResponseController(w)
+		// The stream is open-ended, so it must not be cut off by the server's write timeout
+		_ = rc.SetWriteDeadline(time.Time{})
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		if _, err := fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds()); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			log.Printf("policy stream cannot be flushed: %v\n", err)
+			return
+		}
+
+		send := func(e *repository.OutboxEvent) error {
+			data, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Type, data); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		keepAlive := func() error {
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+
+		err = svc.Follow(r.Context(), afterSeq, userID, send, keepAlive)
+		if err != nil && r.Context().Err() == nil {
+			// The client reconnects with the last event it saw and picks up from there
+			log.Printf("policy stream ended: %v\n", err)
+		}
+	}
+}
+
+// optionalUserID reads the user a feed is narrowed to from the X-User-ID header, returning ""
+// without it. It writes a 400 response and reports false if the header is not a UUID.
+func optionalUserID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	raw := r.Header.Get(userIDHeader)
+	if raw == "" {
+		return "", true
+	}
+	parsed, err := uuid.Parse(raw)
+	if err != nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   "invalid " + userIDHeader + " header",
+		})
+		return "", false
+	}
+	return parsed.String(), true
+}
+
+// streamStart returns the sequence number a stream starts after: the Last-Event-ID the client
+// resumes from, or the latest event when it is not resuming.
+func streamStart(ctx context.Context, svc service.PolicyEventServiceInterface, lastEventID string) (int64, error) {
+	if lastEventID == "" {
+		return svc.LastEventSeq(ctx)
+	}
+	seq, err := strconv.ParseInt(lastEventID, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, strconv.ErrSyntax
+	}
+	return seq, nil
+}
@@ -1,22 +1,210 @@
 package api
 
-import "github.com/LittleAksMax/bids-policy-service/internal/convert"
+import (
+	"strings"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/convert"
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/LittleAksMax/bids-policy-service/internal/service"
+)
 
 // CreatePolicyRequest is the request DTO for creating a policy
-// UserID is not included in the JSON body; Marketplace is added
+// UserID is not included in the JSON body; Marketplace is added.
+// Status may be draft or active, defaulting to active.
+// AutoSuffix numbers the name, e.g. "Name (2)", instead of failing when it is already taken.
 type CreatePolicyRequest struct {
-	Marketplace string `json:"marketplace" validate:"required,marketplace"`
-	Name        string `json:"name" validate:"required"`
-	Script      string `json:"script" validate:"required,script"`
+	Marketplace string   `json:"marketplace" validate:"required,marketplace"`
+	Status      string   `json:"status" validate:"initialstatus"`
+	Name        string   `json:"name" validate:"required"`
+	Description string   `json:"description"`
+	Script      string   `json:"script" validate:"required,script"`
+	Tags        []string `json:"tags" validate:"tags"`
+	AutoSuffix  bool     `json:"auto_suffix"`
+}
+
+func (req *CreatePolicyRequest) fields() service.PolicyFields {
+	return service.PolicyFields{
+		Name:        req.Name,
+		Script:      strings.ToLower(req.Script),
+		Description: &req.Description,
+		Tags:        req.Tags,
+	}
+}
+
+// ClonePolicyRequest is the request DTO for copying a policy into other marketplaces
+type ClonePolicyRequest struct {
+	Marketplaces []string `json:"marketplaces" validate:"required,marketplace"`
 }
 
 // UpdatePolicyRequest is the request DTO for updating a policy
-// Only Name and Script can be updated; UserID, Marketplace are immutable
+// Only Name, Description, Script and Tags can be updated; UserID, Marketplace are immutable.
+// Omitting Description or Tags keeps their existing values.
 type UpdatePolicyRequest struct {
-	Name   string `json:"name" validate:"required"`
+	Name        string   `json:"name" validate:"required"`
+	Description *string  `json:"description"`
+	Script      string   `json:"script" validate:"required,script"`
+	Tags        []string `json:"tags" validate:"tags"`
+}
+
+func (req *UpdatePolicyRequest) fields() service.PolicyFields {
+	return service.PolicyFields{
+		Name:        req.Name,
+		Script:      strings.ToLower(req.Script),
+		Description: req.Description,
+		Tags:        req.Tags,
+	}
+}
+
+// statusQueryAll lists policies of every status on the internal listing, which defaults to active ones
+const statusQueryAll = "all"
+
+const (
+	TagModeAll = "all"
+	TagModeAny = "any"
+)
+
+// SaveDraftRequest is the request DTO for editing a policy's draft script
+type SaveDraftRequest struct {
 	Script string `json:"script" validate:"required,script"`
 }
 
+// DaypartRequest is a single daypart within SetDaypartsRequest
+type DaypartRequest struct {
+	Name   string   `json:"name" validate:"required"`
+	Days   []string `json:"days"`
+	Start  string   `json:"start" validate:"required"`
+	End    string   `json:"end" validate:"required"`
+	Script string   `json:"script" validate:"required"`
+}
+
+// SetDaypartsRequest is the request DTO for replacing a policy's dayparts. An empty list removes them.
+type SetDaypartsRequest struct {
+	Dayparts []DaypartRequest `json:"dayparts"`
+}
+
+func (r *SetDaypartsRequest) dayparts() []repository.Daypart {
+	dayparts := make([]repository.Daypart, 0, len(r.Dayparts))
+	for _, d := range r.Dayparts {
+		days := make([]string, 0, len(d.Days))
+		for _, day := range d.Days {
+			days = append(days, strings.ToLower(strings.TrimSpace(day)))
+		}
+		dayparts = append(dayparts, repository.Daypart{
+			Name:   strings.TrimSpace(d.Name),
+			Days:   days,
+			Start:  d.Start,
+			End:    d.End,
+			Script: strings.ToLower(d.Script),
+		})
+	}
+	return dayparts
+}
+
+// PolicyWindowRequest is the request DTO for setting when a policy is active. Times are RFC 3339
+// or local times in the policy's marketplace, such as "2026-11-27T00:00"; empty bounds are cleared.
+type PolicyWindowRequest struct {
+	EffectiveFrom  string `json:"effective_from"`
+	EffectiveUntil string `json:"effective_until"`
+}
+
+// ScheduleDraftRequest is the request DTO for scheduling a policy's draft to go live for a window
+type ScheduleDraftRequest struct {
+	EffectiveFrom  string `json:"effective_from" validate:"required"`
+	EffectiveUntil string `json:"effective_until"`
+}
+
+// CreateAssignmentRequest is the request DTO for assigning a policy to a campaign, ad group or target
+type CreateAssignmentRequest struct {
+	Marketplace string `json:"marketplace" validate:"required,marketplace"`
+	EntityType  string `json:"entity_type" validate:"required,entitytype"`
+	EntityID    string `json:"entity_id" validate:"required"`
+	PolicyID    string `json:"policy_id" validate:"required"`
+}
+
+// UpdateAssignmentRequest is the request DTO for pointing an assignment at another policy
+type UpdateAssignmentRequest struct {
+	PolicyID string `json:"policy_id" validate:"required"`
+}
+
+// SetApproversRequest is the request DTO for replacing an account's approvers.
+// An empty list turns approvals off.
+type SetApproversRequest struct {
+	ApproverIDs []string `json:"approver_ids"`
+}
+
+// RejectChangeRequestRequest is the request DTO for rejecting a change request, with an optional reason
+type RejectChangeRequestRequest struct {
+	Comment string `json:"comment"`
+}
+
+// ChangeCommentRequest is the request DTO for commenting on a change request
+type ChangeCommentRequest struct {
+	Body string `json:"body" validate:"required"`
+}
+
+// CreateWebhookRequest is the request DTO for subscribing a URL to policy events.
+// Omitting EventTypes subscribes to every type of event, and omitting UserID to every user's policies.
+type CreateWebhookRequest struct {
+	URL        string   `json:"url" validate:"required"`
+	EventTypes []string `json:"event_types"`
+	UserID     string   `json:"user_id"`
+}
+
+const (
+	BatchModeAtomic     = "atomic"
+	BatchModeBestEffort = "best_effort"
+)
+
+// operatorQueryValues maps the accepted spellings of the operator query parameter to the
+// stored operator. Words are accepted because a literal '+' decodes to a space in query strings.
+var operatorQueryValues = map[string]string{
+	"+":   string(rune(convert.OperatorAdd)),
+	"-":   string(rune(convert.OperatorSub)),
+	"=":   string(rune(convert.OperatorSet)),
+	"add": string(rune(convert.OperatorAdd)),
+	"sub": string(rune(convert.OperatorSub)),
+	"set": string(rune(convert.OperatorSet)),
+}
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// maxBatchOperations caps the number of operations accepted in one batch request
+const maxBatchOperations = 500
+
+// maxAtomicBatchOperations caps the operations of an atomic batch, which run in one
+// transaction. Every policy change draws its event number from a single counter, so while the
+// transaction runs, every other user's policy changes wait for it; larger batches must be
+// applied best effort, or split.
+const maxAtomicBatchOperations = 50
+
+// BatchPolicyOperation is one create, update or delete in a batch request.
+// Fields are validated per operation type, using the same rules as the single-policy endpoints.
+type BatchPolicyOperation struct {
+	Op          string   `json:"op"`
+	ID          string   `json:"id,omitempty"`
+	Marketplace string   `json:"marketplace,omitempty"`
+	Status      string   `json:"status,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	Description *string  `json:"description,omitempty"`
+	Script      string   `json:"script,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// BatchPoliciesRequest is the request DTO for bulk policy changes.
+// Mode is either "atomic" (the default) or "best_effort".
+type BatchPoliciesRequest struct {
+	Mode       string                 `json:"mode"`
+	Operations []BatchPolicyOperation `json:"operations" validate:"required"`
+}
+
+type BatchPoliciesResponse struct {
+	Mode    string                `json:"mode"`
+	Results []service.BatchResult `json:"results"`
+}
+
 type ConvertScriptToTreeRequest struct {
 	Script string `json:"script" validate:"required,script"`
 }
@@ -7,45 +7,56 @@ import (
 	"github.com/LittleAksMax/bids-policy-service/internal/config"
 	"github.com/LittleAksMax/bids-policy-service/internal/db"
 	"github.com/LittleAksMax/bids-policy-service/internal/health"
-	"github.com/LittleAksMax/bids-policy-service/internal/repository"
 	"github.com/LittleAksMax/bids-policy-service/internal/service"
 
 	"github.com/LittleAksMax/bids-util/requests"
 	"github.com/go-chi/chi/v5"
 )
 
+// Services are the service layers behind the API. They are built once, alongside the
+// background workers that share them, so that every route sees the same caches and state.
+type Services struct {
+	Convert     service.ConvertServiceInterface
+	Policies    service.PolicyServiceInterface
+	Approvals   service.ApprovalServiceInterface
+	Schedules   service.ScheduleServiceInterface
+	Assignments service.AssignmentServiceInterface
+	Webhooks    service.WebhookServiceInterface
+	Events      service.PolicyEventServiceInterface
+}
+
 // NewRouter constructs the main API router by wiring middleware and routes defined elsewhere.
-func NewRouter(cfg *config.Config, dbCfg *db.Config, cacheCfg cache.RequestCache) http.Handler {
+func NewRouter(cfg *config.Config, dbCfg *db.Config, cacheCfg cache.RequestCache, services Services) http.Handler {
 	r := chi.NewRouter()
 
 	requests.RegisterMiddleware(r)
+	r.Use(StaleWarning)
 
 	requests.ApplyCORS(
 		r,
 		cfg.AllowedOrigins,
 		[]string{"GET", "POST", "PUT", "DELETE"},
-		[]string{"Accept", "Authorization", "Content-Type", cfg.Auth.ClaimsHeader, cfg.Auth.TimestampHeader, cfg.Auth.SignatureHeader},
-		[]string{"Set-Cookie"},
+		[]string{"Accept", "Authorization", "Content-Type", cfg.Auth.ClaimsHeader, cfg.Auth.TimestampHeader, cfg.Auth.SignatureHeader, idempotencyKeyHeader},
+		[]string{"Set-Cookie", idempotentReplayedHeader, "Warning", "Age", "Retry-After"},
 		true,
 		300,
 	)
 
-	// Initialise layers for policies
-	policyRepo := repository.NewMongoPolicyRepository(dbCfg.Database)
-	policyService := service.NewPolicyService(policyRepo, cacheCfg)
-	policyController := NewPolicyController(policyService, cfg.Auth.ClaimsHeader)
-
-	// Initialise layers for converting policy formats
-	convertService := service.NewConvertService()
-	convertController := NewConvertController(convertService)
+	convertController := NewConvertController(services.Convert)
+	policyController := NewPolicyController(services.Policies, cfg.Auth.ClaimsHeader)
+	approvalController := NewApprovalController(services.Approvals)
+	scheduleController := NewScheduleController(services.Schedules)
+	assignmentController := NewAssignmentController(services.Assignments)
+	webhookController := NewWebhookController(services.Webhooks)
 
 	// Create health checkers map
+	// The database is only degraded while reads are served from the cache without it
 	healthCheckers := map[string]health.HealthChecker{
-		"policy_db": dbCfg,
-		"cache":     cacheCfg,
+		"policy_db": health.Fallback{HealthChecker: dbCfg, Backup: cacheCfg, Active: dbCfg.Breaker.Open},
+		"cache":     health.Optional{HealthChecker: cacheCfg},
 	}
 
-	RegisterRoutes(r, policyController, convertController, healthCheckers, cfg.Auth)
+	RegisterRoutes(r, policyController, convertController, approvalController, scheduleController, assignmentController, webhookController, services.Events, healthCheckers, cacheCfg, dbCfg.Breaker, cfg.Auth)
 
 	return r
 }
@@ -3,15 +3,21 @@ package api
 import (
 	"net/http"
 
+	"github.com/LittleAksMax/bids-policy-service/internal/cache"
 	"github.com/LittleAksMax/bids-policy-service/internal/config"
+	"github.com/LittleAksMax/bids-policy-service/internal/db"
 	"github.com/LittleAksMax/bids-policy-service/internal/health"
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/LittleAksMax/bids-policy-service/internal/service"
 	"github.com/LittleAksMax/bids-policy-service/internal/validation"
 	"github.com/LittleAksMax/bids-util/requests"
 	utilsvalidation "github.com/LittleAksMax/bids-util/validation"
 	"github.com/go-chi/chi/v5"
 )
 
-// Health handler implementation that checks all registered services.
+// Health handler implementation that checks all registered services. A failing
+// health.Optional checker, or health.Fallback checker whose failure is covered, is reported
+// as degraded without making the service unhealthy.
 func Health(checkers map[string]health.HealthChecker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		statuses := make(map[string]interface{})
@@ -19,6 +25,15 @@ func Health(checkers map[string]health.HealthChecker) http.HandlerFunc {
 
 		for name, checker := range checkers {
 			if err := checker.HealthCheck(r.Context()); err != nil {
+				_, optional := checker.(health.Optional)
+				fallback, hasFallback := checker.(health.Fallback)
+				if optional || hasFallback && fallback.Covers(r.Context()) {
+					statuses[name] = map[string]interface{}{
+						"status": "degraded",
+						"error":  err.Error(),
+					}
+					continue
+				}
 				statuses[name] = map[string]interface{}{
 					"status": "unhealthy",
 					"error":  err.Error(),
@@ -44,26 +59,44 @@ func Health(checkers map[string]health.HealthChecker) http.HandlerFunc {
 	}
 }
 
+// policyValidationFuncs validates policy request bodies, including the items of a batch.
+var policyValidationFuncs = []func(T any) error{
+	utilsvalidation.ValidateRequiredFields,
+	validation.ValidateMarketplace,
+	utilsvalidation.ValidateUUIDs,
+	validation.ValidateScript,
+	validation.ValidateTags,
+	validation.ValidateInitialStatus,
+}
+
+// authMiddleware validates the signed access token and extracts the subject's UUID.
+func authMiddleware(authCfg *config.AuthConfig) []func(http.Handler) http.Handler {
+	return []func(http.Handler) http.Handler{
+		requests.ValidateAccessToken(
+			authCfg.SharedSecret,
+			authCfg.AccessTokenSecret,
+			authCfg.MaxSkew,
+			authCfg.ClaimsHeader,
+			authCfg.TimestampHeader,
+			authCfg.SignatureHeader,
+		),
+		requests.EnsureValidSubject(
+			authCfg.ClaimsHeader,
+			uuidSubjectKey,
+		),
+	}
+}
+
 // RegisterRoutes registers all endpoint handlers using the controller methods.
-func RegisterRoutes(r chi.Router, pc *PolicyController, cc *ConvertController, healthCheckers map[string]health.HealthChecker, authCfg *config.AuthConfig) {
+func RegisterRoutes(r chi.Router, pc *PolicyController, cc *ConvertController, ac *ApprovalController, sc *ScheduleController, asc *AssignmentController, wc *WebhookController, eventService service.PolicyEventServiceInterface, healthCheckers map[string]health.HealthChecker, requestCache cache.RequestCache, breaker *db.Breaker, authCfg *config.AuthConfig) {
+	// Changes are rejected outright while the database is unavailable
+	readOnly := ReadOnlyWhileUnavailable(breaker)
+
 	// Health
 	r.Get("/health", Health(healthCheckers))
 
 	r.Route("/convert", func(r chi.Router) {
-		r.Use(
-			requests.ValidateAccessToken(
-				authCfg.SharedSecret,
-				authCfg.AccessTokenSecret,
-				authCfg.MaxSkew,
-				authCfg.ClaimsHeader,
-				authCfg.TimestampHeader,
-				authCfg.SignatureHeader,
-			),
-			requests.EnsureValidSubject(
-				authCfg.ClaimsHeader,
-				uuidSubjectKey,
-			),
-		)
+		r.Use(authMiddleware(authCfg)...)
 
 		treeValidationFuncs := []func(T any) error{
 			utilsvalidation.ValidateRequiredFields,
@@ -80,39 +113,108 @@ func RegisterRoutes(r chi.Router, pc *PolicyController, cc *ConvertController, h
 
 	// Register policy routes with AuthMiddleware
 	r.Route("/policies", func(r chi.Router) {
-		r.Use(
-			requests.ValidateAccessToken(
-				authCfg.SharedSecret,
-				authCfg.AccessTokenSecret,
-				authCfg.MaxSkew,
-				authCfg.ClaimsHeader,
-				authCfg.TimestampHeader,
-				authCfg.SignatureHeader,
-			),
-			requests.EnsureValidSubject(
-				authCfg.ClaimsHeader,
-				uuidSubjectKey,
-			),
-		)
-		validationFuncs := []func(T any) error{
+		r.Use(authMiddleware(authCfg)...)
+		r.Use(readOnly)
+		r.Get("/", pc.ListPoliciesHandler)
+		r.With(Idempotency(requestCache), requests.ValidateRequest[CreatePolicyRequest](policyValidationFuncs)).Post("/", pc.CreatePolicyHandler)
+		r.Get("/search", pc.SearchPoliciesHandler)
+		r.Get("/trash", pc.ListTrashHandler)
+		r.Get("/{id}", pc.GetPolicyHandler)
+		r.With(requests.ValidateRequest[UpdatePolicyRequest](policyValidationFuncs)).Put("/{id}", pc.UpdatePolicyHandler)
+		r.Delete("/{id}", pc.DeletePolicyHandler)
+		r.Post("/{id}/restore", pc.RestorePolicyHandler)
+		r.With(requests.ValidateRequest[SaveDraftRequest](policyValidationFuncs)).Put("/{id}/draft", pc.SaveDraftHandler)
+		r.Delete("/{id}/draft", pc.DiscardDraftHandler)
+		r.Post("/{id}/publish", pc.PublishPolicyHandler)
+		r.Post("/{id}/pause", pc.TransitionPolicyHandler(repository.StatusPaused))
+		r.Post("/{id}/archive", pc.TransitionPolicyHandler(repository.StatusArchived))
+		r.With(requests.ValidateRequest[ClonePolicyRequest](policyValidationFuncs)).Post("/{id}/clone", pc.ClonePolicyHandler)
+		r.Put("/{id}/default", pc.SetDefaultPolicyHandler)
+		r.Delete("/{id}/default", pc.UnsetDefaultPolicyHandler)
+		r.With(requests.ValidateRequest[SetDaypartsRequest](policyValidationFuncs)).Put("/{id}/dayparts", pc.SetDaypartsHandler)
+		r.Get("/{id}/resolve", pc.ResolvePolicyHandler)
+		r.With(requests.ValidateRequest[PolicyWindowRequest](policyValidationFuncs)).Put("/{id}/window", sc.SetPolicyWindowHandler)
+		r.Get("/{id}/schedule", sc.ListScheduledChangesHandler)
+		r.With(requests.ValidateRequest[ScheduleDraftRequest](policyValidationFuncs)).Post("/{id}/schedule", sc.ScheduleDraftHandler)
+		r.Delete("/{id}/schedule/{changeID}", sc.CancelScheduledChangeHandler)
+	})
+
+	// Bulk changes live beside the /policies subtree, as chi cannot mount "/policies:batch" under it
+	r.Group(func(r chi.Router) {
+		r.Use(authMiddleware(authCfg)...)
+		r.Use(readOnly)
+		batchValidationFuncs := []func(T any) error{
+			utilsvalidation.ValidateRequiredFields,
+		}
+		r.With(Idempotency(requestCache), requests.ValidateRequest[BatchPoliciesRequest](batchValidationFuncs)).Post("/policies:batch", pc.BatchPoliciesHandler)
+	})
+
+	r.Route("/assignments", func(r chi.Router) {
+		r.Use(authMiddleware(authCfg)...)
+		r.Use(readOnly)
+		assignmentValidationFuncs := []func(T any) error{
 			utilsvalidation.ValidateRequiredFields,
 			validation.ValidateMarketplace,
-			utilsvalidation.ValidateUUIDs,
-			validation.ValidateScript,
+			validation.ValidateEntityType,
 		}
+		r.Get("/", asc.ListAssignmentsHandler)
+		r.With(requests.ValidateRequest[CreateAssignmentRequest](assignmentValidationFuncs)).Post("/", asc.CreateAssignmentHandler)
+		r.Get("/{id}", asc.GetAssignmentHandler)
+		r.With(requests.ValidateRequest[UpdateAssignmentRequest](assignmentValidationFuncs)).Put("/{id}", asc.UpdateAssignmentHandler)
+		r.Delete("/{id}", asc.DeleteAssignmentHandler)
+	})
 
-		r.Get("/", pc.ListPoliciesHandler)
-		r.With(requests.ValidateRequest[CreatePolicyRequest](validationFuncs)).Post("/", pc.CreatePolicyHandler)
-		r.Get("/{id}", pc.GetPolicyHandler)
-		r.With(requests.ValidateRequest[UpdatePolicyRequest](validationFuncs)).Put("/{id}", pc.UpdatePolicyHandler)
-		r.Delete("/{id}", pc.DeletePolicyHandler)
+	r.Route("/approvers", func(r chi.Router) {
+		r.Use(authMiddleware(authCfg)...)
+		r.Use(readOnly)
+		approverValidationFuncs := []func(T any) error{
+			utilsvalidation.ValidateRequiredFields,
+		}
+		r.Get("/", ac.GetApproversHandler)
+		r.With(requests.ValidateRequest[SetApproversRequest](approverValidationFuncs)).Put("/", ac.SetApproversHandler)
+		r.With(requests.ValidateRequest[SetApproversRequest](approverValidationFuncs)).Put("/{ownerID}", ac.SetApproversHandler)
+	})
+
+	r.Route("/change-requests", func(r chi.Router) {
+		r.Use(authMiddleware(authCfg)...)
+		r.Use(readOnly)
+		changeRequestValidationFuncs := []func(T any) error{
+			utilsvalidation.ValidateRequiredFields,
+		}
+		r.Get("/", ac.ListChangeRequestsHandler)
+		r.Get("/{id}", ac.GetChangeRequestHandler)
+		r.Post("/{id}/approve", ac.ApproveChangeRequestHandler)
+		r.With(requests.ValidateRequest[RejectChangeRequestRequest](changeRequestValidationFuncs)).Post("/{id}/reject", ac.RejectChangeRequestHandler)
+		r.With(requests.ValidateRequest[ChangeCommentRequest](changeRequestValidationFuncs)).Post("/{id}/comments", ac.CommentOnChangeRequestHandler)
 	})
 
 	r.Route("/internal", func(r chi.Router) {
-		r.Use(
-			requests.RequireAPIKey(authCfg.APIKey, apiKeyHeader),
-			requests.InjectUUIDSubjectFromHeader(userIDHeader, uuidSubjectKey),
-		)
-		r.Get("/policies", pc.ListPoliciesHandler)
+		r.Use(requests.RequireAPIKey(authCfg.APIKey, apiKeyHeader))
+
+		// Cache administration and webhooks do not act on behalf of a user
+		r.Post("/cache/flush", FlushCache(requestCache))
+
+		// The stream and change feed cover every user unless the user header narrows them down
+		r.Get("/policies/stream", StreamPolicyEvents(eventService))
+		r.Get("/policies/changes", ListPolicyChanges(eventService))
+
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Use(readOnly)
+			webhookValidationFuncs := []func(T any) error{
+				utilsvalidation.ValidateRequiredFields,
+			}
+			r.Get("/", wc.ListWebhooksHandler)
+			r.With(requests.ValidateRequest[CreateWebhookRequest](webhookValidationFuncs)).Post("/", wc.CreateWebhookHandler)
+			r.Delete("/{id}", wc.DeleteWebhookHandler)
+			r.Get("/{id}/deliveries", wc.ListDeliveriesHandler)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(requests.InjectUUIDSubjectFromHeader(userIDHeader, uuidSubjectKey))
+			r.Get("/policies", pc.InternalListPoliciesHandler)
+			r.Get("/policies/default", pc.InternalDefaultPolicyHandler)
+			r.Get("/policies/{id}/resolve", pc.ResolvePolicyHandler)
+			r.Get("/assignments/resolve", asc.ResolveAssignmentHandler)
+		})
 	})
 }
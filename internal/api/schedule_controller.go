@@ -0,0 +1,173 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/LittleAksMax/bids-policy-service/internal/service"
+	"github.com/LittleAksMax/bids-util/requests"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type ScheduleController struct {
+	service service.ScheduleServiceInterface
+}
+
+func NewScheduleController(service service.ScheduleServiceInterface) *ScheduleController {
+	return &ScheduleController{service: service}
+}
+
+// SetPolicyWindowHandler sets when a policy is active (REST PUT /policies/{id}/window)
+func (sc *ScheduleController) SetPolicyWindowHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	windowReq := requests.GetRequestBody[PolicyWindowRequest](r)
+	if windowReq == nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   "invalid request body",
+		})
+		return
+	}
+
+	policy, err := sc.service.SetPolicyWindow(r.Context(), userID, id, windowReq.EffectiveFrom, windowReq.EffectiveUntil)
+	if writeScheduleError(w, err, "failed to set policy window") {
+		return
+	}
+	if policy == nil {
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{
+			Success: false,
+			Error:   "policy not found",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    policy,
+	})
+}
+
+// ScheduleDraftHandler schedules a policy's draft to go live for a window
+// (REST POST /policies/{id}/schedule). When the change needs approval it responds 202 with
+// the change request instead.
+func (sc *ScheduleController) ScheduleDraftHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	scheduleReq := requests.GetRequestBody[ScheduleDraftRequest](r)
+	if scheduleReq == nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   "invalid request body",
+		})
+		return
+	}
+
+	change, changeRequest, err := sc.service.ScheduleDraft(r.Context(), userID, id, scheduleReq.EffectiveFrom, scheduleReq.EffectiveUntil)
+	if writeScheduleError(w, err, "failed to schedule draft") {
+		return
+	}
+	if changeRequest != nil {
+		requests.WriteJSON(w, http.StatusAccepted, requests.APIResponse{
+			Success: true,
+			Data:    changeRequest,
+		})
+		return
+	}
+	if change == nil {
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{
+			Success: false,
+			Error:   "policy not found",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusCreated, requests.APIResponse{
+		Success: true,
+		Data:    change,
+	})
+}
+
+// ListScheduledChangesHandler lists the changes scheduled for a policy (REST GET /policies/{id}/schedule)
+func (sc *ScheduleController) ListScheduledChangesHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	changes, err := sc.service.ListScheduledChanges(r.Context(), userID, id)
+	if writeScheduleError(w, err, "failed to list scheduled changes") {
+		return
+	}
+	if changes == nil {
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{
+			Success: false,
+			Error:   "policy not found",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    changes,
+	})
+}
+
+// CancelScheduledChangeHandler cancels a change that has not been applied yet
+// (REST DELETE /policies/{id}/schedule/{changeID})
+func (sc *ScheduleController) CancelScheduledChangeHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	changeID := chi.URLParam(r, "changeID")
+	userID := r.Context().Value(uuidSubjectKey).(uuid.UUID)
+
+	change, err := sc.service.CancelScheduledChange(r.Context(), userID, id, changeID)
+	if writeScheduleError(w, err, "failed to cancel scheduled change") {
+		return
+	}
+	if change == nil {
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{
+			Success: false,
+			Error:   "scheduled change not found or already applied",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    change,
+	})
+}
+
+// writeScheduleError writes the response for a scheduling error and reports whether there was one.
+func writeScheduleError(w http.ResponseWriter, err error, failure string) bool {
+	var draftErr *service.DraftValidationError
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, service.ErrInvalidWindow):
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	case errors.As(err, &draftErr):
+		requests.WriteJSON(w, http.StatusUnprocessableEntity, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	case errors.Is(err, service.ErrPolicyArchived), errors.Is(err, service.ErrNoDraftToSchedule),
+		errors.Is(err, service.ErrScheduleConflict), errors.Is(err, service.ErrInvalidTransition),
+		errors.Is(err, repository.ErrPendingChangeExists):
+		requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	default:
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+			Success: false,
+			Error:   failure,
+		})
+	}
+	return true
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/service"
+	"github.com/LittleAksMax/bids-util/requests"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+type WebhookController struct {
+	service service.WebhookServiceInterface
+}
+
+func NewWebhookController(service service.WebhookServiceInterface) *WebhookController {
+	return &WebhookController{service: service}
+}
+
+// CreateWebhookHandler subscribes a URL to policy events, returning the subscription with its
+// signing secret (REST POST /internal/webhooks)
+func (wc *WebhookController) CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	createReq := requests.GetRequestBody[CreateWebhookRequest](r)
+	if createReq == nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   "invalid request body",
+		})
+		return
+	}
+	if createReq.UserID != "" {
+		if _, err := uuid.Parse(createReq.UserID); err != nil {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+				Success: false,
+				Error:   "invalid user_id: " + createReq.UserID,
+			})
+			return
+		}
+	}
+
+	sub, err := wc.service.CreateSubscription(r.Context(), createReq.URL, createReq.EventTypes, createReq.UserID)
+	switch {
+	case errors.Is(err, service.ErrInvalidWebhookURL), errors.Is(err, service.ErrUnknownEventType):
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	case err != nil:
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+			Success: false,
+			Error:   "failed to create webhook",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusCreated, requests.APIResponse{
+		Success: true,
+		Data:    sub,
+	})
+}
+
+// ListWebhooksHandler lists the webhook subscriptions, without their secrets (REST GET /internal/webhooks)
+func (wc *WebhookController) ListWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	subs, err := wc.service.ListSubscriptions(r.Context())
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+			Success: false,
+			Error:   "failed to list webhooks",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    subs,
+	})
+}
+
+// DeleteWebhookHandler removes a webhook subscription (REST DELETE /internal/webhooks/{id})
+func (wc *WebhookController) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	deleted, err := wc.service.DeleteSubscription(r.Context(), id)
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+			Success: false,
+			Error:   "failed to delete webhook",
+		})
+		return
+	}
+	if !deleted {
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{
+			Success: false,
+			Error:   "webhook not found",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    map[string]string{"id": id},
+	})
+}
+
+// ListDeliveriesHandler lists a subscription's most recent deliveries and the attempts made at
+// each, up to the "limit" parameter (REST GET /internal/webhooks/{id}/deliveries)
+func (wc *WebhookController) ListDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	limit := defaultDeliveryLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxDeliveryLimit {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+				Success: false,
+				Error:   fmt.Sprintf("limit must be between 1 and %d", maxDeliveryLimit),
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	deliveries, err := wc.service.ListDeliveries(r.Context(), chi.URLParam(r, "id"), limit)
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{
+			Success: false,
+			Error:   "failed to list deliveries",
+		})
+		return
+	}
+	if deliveries == nil {
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{
+			Success: false,
+			Error:   "webhook not found",
+		})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    deliveries,
+	})
+}
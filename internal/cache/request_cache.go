@@ -2,15 +2,50 @@ package cache
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"github.com/LittleAksMax/bids-policy-service/internal/health"
 )
 
 // RequestCache is a key-value store for caching web requests for policies.
+// Get returns ErrNotFound or ErrExpired when there is no live value at the key.
 type RequestCache interface {
 	health.HealthChecker
 	Set(ctx context.Context, key string, value string, expiresIn time.Duration) error
+	// SetNX atomically stores the value only if there is no live value at key, reporting
+	// whether it did.
+	SetNX(ctx context.Context, key string, value string, expiresIn time.Duration) (bool, error)
 	Get(ctx context.Context, key string) (value string, expiresAt time.Time, err error)
+	// MGet returns the values of the keys that hold one, keyed by key.
+	MGet(ctx context.Context, keys ...string) (map[string]string, error)
 	Delete(ctx context.Context, key string) error
+	DeleteMany(ctx context.Context, keys ...string) error
+	// Incr atomically increments the integer at key, which starts from 0 when missing,
+	// and resets its TTL.
+	Incr(ctx context.Context, key string, expiresIn time.Duration) (int64, error)
+	// Flush removes every key in the cache, returning how many it removed.
+	Flush(ctx context.Context) (int64, error)
+}
+
+// GetJSON reads the value at key and decodes it from JSON.
+func GetJSON[T any](ctx context.Context, c RequestCache, key string) (*T, time.Time, error) {
+	cached, expiresAt, err := c.Get(ctx, key)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var value T
+	if err := json.Unmarshal([]byte(cached), &value); err != nil {
+		return nil, time.Time{}, err
+	}
+	return &value, expiresAt, nil
+}
+
+// SetJSON encodes value as JSON and stores it at key.
+func SetJSON(ctx context.Context, c RequestCache, key string, value any, expiresIn time.Duration) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.Set(ctx, key, string(b), expiresIn)
 }
@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MemoryCache is an in-process RequestCache that holds at most maxEntries keys, evicting the
+// least recently used one to make room. Expired keys are dropped when they are next read.
+// It is not shared between instances, so it suits local development and single-instance runs.
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List // front is the most recently used
+}
+
+type memoryEntry struct {
+	key       string
+	value     string
+	expiresAt time.Time
+}
+
+// NewMemoryCache creates a MemoryCache bounded to maxEntries keys.
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: max(maxEntries, 1),
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Set stores the value with TTL, replacing any existing value.
+func (c *MemoryCache) Set(_ context.Context, key string, value string, expiresIn time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &memoryEntry{key: key, value: value, expiresAt: time.Now().Add(expiresIn)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return nil
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+// SetNX stores the value with TTL unless the key already holds a live value.
+func (c *MemoryCache) SetNX(_ context.Context, key string, value string, expiresIn time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		if time.Now().Before(el.Value.(*memoryEntry).expiresAt) {
+			return false, nil
+		}
+		c.remove(el)
+	}
+
+	c.entries[key] = c.order.PushFront(&memoryEntry{key: key, value: value, expiresAt: time.Now().Add(expiresIn)})
+	for c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+	}
+	return true, nil
+}
+
+// Get retrieves the value and when it expires.
+func (c *MemoryCache) Get(_ context.Context, key string) (string, time.Time, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return "", time.Time{}, ErrNotFound
+	}
+	entry := el.Value.(*memoryEntry)
+	if !time.Now().Before(entry.expiresAt) {
+		c.remove(el)
+		return "", time.Time{}, ErrExpired
+	}
+	c.order.MoveToFront(el)
+	return entry.value, entry.expiresAt, nil
+}
+
+// MGet retrieves several values. Missing and expired keys are left out of the result.
+func (c *MemoryCache) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if value, _, err := c.Get(ctx, key); err == nil {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// Delete removes the key.
+func (c *MemoryCache) Delete(ctx context.Context, key string) error {
+	return c.DeleteMany(ctx, key)
+}
+
+// DeleteMany removes several keys.
+func (c *MemoryCache) DeleteMany(_ context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := c.entries[key]; ok {
+			c.remove(el)
+		}
+	}
+	return nil
+}
+
+// Flush removes every key.
+func (c *MemoryCache) Flush(context.Context) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	flushed := int64(len(c.entries))
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+	return flushed, nil
+}
+
+// Incr increments the integer at key and resets its TTL.
+func (c *MemoryCache) Incr(_ context.Context, key string, expiresIn time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var n int64
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*memoryEntry)
+		if time.Now().Before(entry.expiresAt) {
+			current, err := strconv.ParseInt(entry.value, 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("value at %q is not an integer", key)
+			}
+			n = current
+		}
+		c.remove(el)
+	}
+	n++
+
+	c.entries[key] = c.order.PushFront(&memoryEntry{key: key, value: strconv.FormatInt(n, 10), expiresAt: time.Now().Add(expiresIn)})
+	for c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+	}
+	return n, nil
+}
+
+// HealthCheck always succeeds, as the cache lives in the process.
+func (c *MemoryCache) HealthCheck(context.Context) error {
+	return nil
+}
+
+func (c *MemoryCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*memoryEntry).key)
+}
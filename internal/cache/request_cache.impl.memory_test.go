@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(2)
+
+	_ = c.Set(ctx, "a", "1", time.Minute)
+	_ = c.Set(ctx, "b", "2", time.Minute)
+	if _, _, err := c.Get(ctx, "a"); err != nil {
+		t.Fatalf("expected a to be cached, got %v", err)
+	}
+	_ = c.Set(ctx, "c", "3", time.Minute)
+
+	if _, _, err := c.Get(ctx, "b"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected b to be evicted, got %v", err)
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, _, err := c.Get(ctx, key); err != nil {
+			t.Fatalf("expected %s to be cached, got %v", key, err)
+		}
+	}
+}
+
+func TestMemoryCacheExpiry(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(10)
+
+	_ = c.Set(ctx, "a", "1", -time.Second)
+	if _, _, err := c.Get(ctx, "a"); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected a to have expired, got %v", err)
+	}
+	if _, _, err := c.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the expired key to be dropped, got %v", err)
+	}
+}
+
+func TestMemoryCacheSetReplacesValue(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(10)
+
+	_ = c.Set(ctx, "a", "1", time.Minute)
+	_ = c.Set(ctx, "a", "2", time.Minute)
+	value, _, err := c.Get(ctx, "a")
+	if err != nil || value != "2" {
+		t.Fatalf("expected 2, got %q (%v)", value, err)
+	}
+
+	_ = c.Delete(ctx, "a")
+	if _, _, err := c.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected a to be deleted, got %v", err)
+	}
+}
+
+func TestMemoryCacheIncr(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(10)
+
+	for want := int64(1); want <= 3; want++ {
+		n, err := c.Incr(ctx, "n", time.Minute)
+		if err != nil || n != want {
+			t.Fatalf("expected %d, got %d (%v)", want, n, err)
+		}
+	}
+	if value, _, err := c.Get(ctx, "n"); err != nil || value != "3" {
+		t.Fatalf("expected 3, got %q (%v)", value, err)
+	}
+
+	_ = c.Set(ctx, "n", "7", -time.Second)
+	if n, _ := c.Incr(ctx, "n", time.Minute); n != 1 {
+		t.Fatalf("expected an expired counter to restart at 1, got %d", n)
+	}
+}
+
+func TestMemoryCacheSetNX(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(10)
+
+	if ok, err := c.SetNX(ctx, "a", "1", time.Minute); !ok || err != nil {
+		t.Fatalf("expected the first SetNX to win, got %v (%v)", ok, err)
+	}
+	if ok, _ := c.SetNX(ctx, "a", "2", time.Minute); ok {
+		t.Fatal("expected SetNX on a live key to lose")
+	}
+	if value, _, _ := c.Get(ctx, "a"); value != "1" {
+		t.Fatalf("expected the first value to be kept, got %q", value)
+	}
+
+	_ = c.Set(ctx, "b", "1", -time.Second)
+	if ok, _ := c.SetNX(ctx, "b", "2", time.Minute); !ok {
+		t.Fatal("expected SetNX on an expired key to win")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -21,18 +22,24 @@ func (cfg *RedisConnectionConfig) DSN() string {
 }
 
 var (
-	ErrNotFound = errors.New("refresh token not found")
-	ErrExpired  = errors.New("refresh token expired")
+	ErrNotFound = errors.New("cache key not found")
+	ErrExpired  = errors.New("cache key expired")
 )
 
-type RedisRefreshStore struct {
-	Client *redis.Client
-	keyNS  string // namespace prefix e.g. "refresh"
+// flushBatchSize is how many keys Flush scans for and unlinks at a time.
+const flushBatchSize = 1000
+
+// RedisCache is a RequestCache in Redis. Every key is prefixed with a namespace, e.g.
+// "policy-service:prod", so that several services and environments can share one Redis.
+type RedisCache struct {
+	Client    *redis.Client
+	namespace string
 }
 
-// NewRedisRefreshStore creates a Redis-backed RefreshTokenStore using values from Config.
-// Returns an error if required Redis connection details are missing.
-func NewRedisRefreshStore(ctx context.Context, cfg *RedisConnectionConfig) (*RedisRefreshStore, error) {
+// NewRedisCache creates a Redis-backed RequestCache whose keys live under namespace.
+// The cache is only an optimization, so an unreachable Redis is logged rather than returned:
+// the client reconnects by itself, and until then cache calls fail and HealthCheck reports it.
+func NewRedisCache(ctx context.Context, cfg *RedisConnectionConfig, namespace string) *RedisCache {
 	client := redis.NewClient(&redis.Options{
 		Addr:     cfg.DSN(),
 		Password: cfg.RedisPassword,
@@ -40,43 +47,53 @@ func NewRedisRefreshStore(ctx context.Context, cfg *RedisConnectionConfig) (*Red
 	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 	if err := client.Ping(ctx).Err(); err != nil {
-		err := client.Close()
-		if err != nil {
-			return nil, err
-		}
-		return nil, fmt.Errorf("redis ping failed: %w", err)
+		log.Printf("redis ping failed, running with the cache degraded: %v", err)
+	} else {
+		log.Println("Pinged your deployment. You successfully connected to Redis!")
 	}
 
-	log.Println("Pinged your deployment. You successfully connected to Redis!")
+	return &RedisCache{Client: client, namespace: namespace}
+}
+
+func (c *RedisCache) buildKey(key string) string {
+	return c.namespace + ":" + key
+}
 
-	return &RedisRefreshStore{Client: client, keyNS: "refresh"}, nil
+func (c *RedisCache) buildKeys(keys []string) []string {
+	built := make([]string, len(keys))
+	for i, key := range keys {
+		built[i] = c.buildKey(key)
+	}
+	return built
 }
 
-func (s *RedisRefreshStore) buildKey(token string) string {
-	return s.keyNS + ":" + token
+// Set stores the value with TTL.
+func (c *RedisCache) Set(ctx context.Context, key string, value string, expiresIn time.Duration) error {
+	if key == "" {
+		return errors.New("key required")
+	}
+	return c.Client.Set(ctx, c.buildKey(key), value, expiresIn).Err()
 }
 
-// Set stores the refresh token with TTL.
-func (s *RedisRefreshStore) Set(ctx context.Context, token string, userID string, expiresIn time.Duration) error {
-	if token == "" || userID == "" {
-		return errors.New("token and userID required")
+// SetNX stores the value with TTL unless the key already exists.
+func (c *RedisCache) SetNX(ctx context.Context, key string, value string, expiresIn time.Duration) (bool, error) {
+	if key == "" {
+		return false, errors.New("key required")
 	}
-	key := s.buildKey(token)
-	// Store userID as value; expiration managed by Redis.
-	return s.Client.Set(ctx, key, userID, expiresIn).Err()
+	return c.Client.SetNX(ctx, c.buildKey(key), value, expiresIn).Result()
 }
 
-// Get retrieves the userID and calculates expiresAt using TTL.
-func (s *RedisRefreshStore) Get(ctx context.Context, token string) (string, time.Time, error) {
-	key := s.buildKey(token)
-	val, err := s.Client.Get(ctx, key).Result()
+// Get retrieves the value and calculates expiresAt using TTL.
+func (c *RedisCache) Get(ctx context.Context, key string) (string, time.Time, error) {
+	key = c.buildKey(key)
+	val, err := c.Client.Get(ctx, key).Result()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			return "", time.Time{}, ErrNotFound
 		}
 		return "", time.Time{}, err
 	}
-	ttl, err := s.Client.TTL(ctx, key).Result()
+	ttl, err := c.Client.TTL(ctx, key).Result()
 	if err != nil {
 		return "", time.Time{}, err
 	}
@@ -87,13 +104,121 @@ func (s *RedisRefreshStore) Get(ctx context.Context, token string) (string, time
 	return val, expiresAt, nil
 }
 
-// Delete removes the refresh token key.
-func (s *RedisRefreshStore) Delete(ctx context.Context, token string) error {
-	key := s.buildKey(token)
-	return s.Client.Del(ctx, key).Err()
+// MGet retrieves several values in one round trip. Missing keys are left out of the result.
+func (c *RedisCache) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	values := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	results, err := c.Client.MGet(ctx, c.buildKeys(keys)...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range results {
+		if value, ok := result.(string); ok {
+			values[keys[i]] = value
+		}
+	}
+	return values, nil
+}
+
+// Delete removes the key.
+func (c *RedisCache) Delete(ctx context.Context, key string) error {
+	return c.Client.Del(ctx, c.buildKey(key)).Err()
 }
 
+// DeleteMany removes several keys in one round trip.
+func (c *RedisCache) DeleteMany(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return c.Client.Del(ctx, c.buildKeys(keys)...).Err()
+}
+
+// Incr increments the integer at key and resets its TTL in a single transaction.
+func (c *RedisCache) Incr(ctx context.Context, key string, expiresIn time.Duration) (int64, error) {
+	key = c.buildKey(key)
+	var incr *redis.IntCmd
+	_, err := c.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, expiresIn)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// Flush removes every key in the namespace, leaving other namespaces alone, and returns how
+// many it removed. Keys are scanned for in batches so that Redis is never blocked for long.
+func (c *RedisCache) Flush(ctx context.Context) (int64, error) {
+	pattern := redisGlobEscaper.Replace(c.namespace) + ":*"
+
+	var flushed int64
+	var cursor uint64
+	for {
+		keys, next, err := c.Client.Scan(ctx, cursor, pattern, flushBatchSize).Result()
+		if err != nil {
+			return flushed, err
+		}
+		if len(keys) > 0 {
+			n, err := c.Client.Unlink(ctx, keys...).Result()
+			if err != nil {
+				return flushed, err
+			}
+			flushed += n
+		}
+		if next == 0 {
+			return flushed, nil
+		}
+		cursor = next
+	}
+}
+
+// redisGlobEscaper escapes the characters SCAN MATCH patterns treat specially.
+var redisGlobEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
 // HealthCheck checks if the Redis connection is healthy.
-func (s *RedisRefreshStore) HealthCheck(ctx context.Context) error {
-	return s.Client.Ping(ctx).Err()
+func (c *RedisCache) HealthCheck(ctx context.Context) error {
+	return c.Client.Ping(ctx).Err()
+}
+
+// Publish sends payload to the subscribers of channel within the namespace.
+func (c *RedisCache) Publish(ctx context.Context, channel string, payload string) error {
+	return c.Client.Publish(ctx, c.buildKey(channel), payload).Err()
+}
+
+// Subscribe delivers the payloads published to channel within the namespace until ctx is
+// cancelled, when the returned channel is closed.
+func (c *RedisCache) Subscribe(ctx context.Context, channel string) <-chan string {
+	sub := c.Client.Subscribe(ctx, c.buildKey(channel))
+	payloads := make(chan string)
+	go func() {
+		defer close(payloads)
+		defer func() {
+			if err := sub.Close(); err != nil {
+				log.Printf("failed to close redis subscription: %v\n", err)
+			}
+		}()
+
+		messages := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				select {
+				case payloads <- msg.Payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return payloads
 }
@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// invalidationChannel is the Redis pub/sub channel, within the namespace, that tiered caches
+// announce changed keys on.
+const invalidationChannel = "cache:invalidate"
+
+// invalidation is a message telling other replicas to drop keys from their L1, or all of it.
+type invalidation struct {
+	Instance string   `json:"instance"`
+	Keys     []string `json:"keys,omitempty"`
+	Flush    bool     `json:"flush,omitempty"`
+}
+
+// generationStripes is how many generation counters a TieredCache spreads its keys over.
+const generationStripes = 256
+
+// sharedCache is the tier that a TieredCache shares with other replicas, which also carries the
+// invalidations they send each other. RedisCache implements it.
+type sharedCache interface {
+	RequestCache
+	Publish(ctx context.Context, channel string, payload string) error
+	Subscribe(ctx context.Context, channel string) <-chan string
+}
+
+// TieredCache is a RequestCache with a per-process L1 in front of a Redis L2 shared by every
+// replica. Writes and deletes go to L2, then evict the L1 copy and are broadcast over Redis
+// pub/sub so that other replicas evict theirs. L1 is only filled by reads of L2.
+//
+// A read of L2 can return a value that is replaced before the read refills L1 with it. Every
+// eviction therefore bumps a generation counter for the key, and a read only refills L1 if the
+// generation it saw before reading L2 is unchanged. L1 entries live for at most l1TTL, which
+// bounds how stale a replica can be if it misses a broadcast, e.g. while reconnecting to Redis.
+type TieredCache struct {
+	l1       *MemoryCache
+	l2       sharedCache
+	l1TTL    time.Duration
+	instance string // tags broadcasts so a replica ignores its own, having already evicted
+
+	mu          sync.Mutex
+	generations [generationStripes]uint64
+}
+
+// NewTieredCache creates a TieredCache. Run must be started for it to receive invalidations.
+func NewTieredCache(l1 *MemoryCache, l2 *RedisCache, l1TTL time.Duration) *TieredCache {
+	return newTieredCache(l1, l2, l1TTL)
+}
+
+func newTieredCache(l1 *MemoryCache, l2 sharedCache, l1TTL time.Duration) *TieredCache {
+	return &TieredCache{l1: l1, l2: l2, l1TTL: l1TTL, instance: uuid.NewString()}
+}
+
+// Run evicts the L1 entries that other replicas announce as changed until ctx is cancelled.
+func (c *TieredCache) Run(ctx context.Context) {
+	for payload := range c.l2.Subscribe(ctx, invalidationChannel) {
+		var inv invalidation
+		if err := json.Unmarshal([]byte(payload), &inv); err != nil || inv.Instance == c.instance {
+			continue
+		}
+		if inv.Flush {
+			c.flushL1(ctx)
+		} else {
+			c.evict(ctx, inv.Keys...)
+		}
+	}
+}
+
+// Set stores the value in L2 and has every replica drop its L1 copy.
+func (c *TieredCache) Set(ctx context.Context, key string, value string, expiresIn time.Duration) error {
+	err := c.l2.Set(ctx, key, value, expiresIn)
+	c.invalidate(ctx, key)
+	return err
+}
+
+// SetNX stores the value in L2 unless the key already exists there, so that replicas race on
+// the shared tier, and has every replica drop its L1 copy when it wins.
+func (c *TieredCache) SetNX(ctx context.Context, key string, value string, expiresIn time.Duration) (bool, error) {
+	ok, err := c.l2.SetNX(ctx, key, value, expiresIn)
+	if err != nil || !ok {
+		return false, err
+	}
+	c.invalidate(ctx, key)
+	return true, nil
+}
+
+// Get reads from L1, falling back to L2 and keeping what it finds in L1.
+func (c *TieredCache) Get(ctx context.Context, key string) (string, time.Time, error) {
+	if value, expiresAt, err := c.l1.Get(ctx, key); err == nil {
+		return value, expiresAt, nil
+	}
+
+	generation := c.generation(key)
+	value, expiresAt, err := c.l2.Get(ctx, key)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	c.fill(ctx, key, value, min(time.Until(expiresAt), c.l1TTL), generation)
+	return value, expiresAt, nil
+}
+
+// MGet reads what it can from L1 and the rest from L2 in one round trip, keeping those in L1.
+func (c *TieredCache) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	values, _ := c.l1.MGet(ctx, keys...)
+	missing := make([]string, 0, len(keys)-len(values))
+	generations := make(map[string]uint64, len(keys)-len(values))
+	for _, key := range keys {
+		if _, ok := values[key]; !ok {
+			missing = append(missing, key)
+			generations[key] = c.generation(key)
+		}
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	fetched, err := c.l2.MGet(ctx, missing...)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range fetched {
+		// MGET does not report TTLs, so these are kept for the full L1 lifetime
+		c.fill(ctx, key, value, c.l1TTL, generations[key])
+		values[key] = value
+	}
+	return values, nil
+}
+
+// Delete removes the key from L2 and has every replica drop its L1 copy.
+func (c *TieredCache) Delete(ctx context.Context, key string) error {
+	return c.DeleteMany(ctx, key)
+}
+
+// DeleteMany removes the keys from L2 and has every replica drop its L1 copies.
+func (c *TieredCache) DeleteMany(ctx context.Context, keys ...string) error {
+	err := c.l2.DeleteMany(ctx, keys...)
+	c.invalidate(ctx, keys...)
+	return err
+}
+
+// Incr increments the integer at key in the shared tier, so every replica counts together,
+// and has every replica drop its L1 copy.
+func (c *TieredCache) Incr(ctx context.Context, key string, expiresIn time.Duration) (int64, error) {
+	n, err := c.l2.Incr(ctx, key, expiresIn)
+	c.invalidate(ctx, key)
+	return n, err
+}
+
+// Flush removes every key in the namespace from L2 and tells every replica to empty its L1.
+func (c *TieredCache) Flush(ctx context.Context) (int64, error) {
+	flushed, err := c.l2.Flush(ctx)
+	c.flushL1(ctx)
+	c.broadcast(ctx, invalidation{Flush: true})
+	return flushed, err
+}
+
+// HealthCheck reports the health of the shared Redis tier.
+func (c *TieredCache) HealthCheck(ctx context.Context) error {
+	return c.l2.HealthCheck(ctx)
+}
+
+// invalidate evicts keys from L1 after a write to L2 and tells other replicas to do the same.
+// It runs even if the write failed, since L2 may have applied it anyway.
+func (c *TieredCache) invalidate(ctx context.Context, keys ...string) {
+	c.evict(ctx, keys...)
+	c.broadcast(ctx, invalidation{Keys: keys})
+}
+
+// evict drops keys from L1 and bumps their generations, so that reads of L2 already in flight
+// do not put the values they read back.
+func (c *TieredCache) evict(ctx context.Context, keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		c.generations[generationStripe(key)]++
+	}
+	_ = c.l1.DeleteMany(ctx, keys...)
+}
+
+// flushL1 empties L1 and bumps every generation.
+func (c *TieredCache) flushL1(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.generations {
+		c.generations[i]++
+	}
+	_, _ = c.l1.Flush(ctx)
+}
+
+// generation returns the generation of key, to be passed to fill once L2 has been read.
+func (c *TieredCache) generation(key string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generations[generationStripe(key)]
+}
+
+// fill keeps a value read from L2 in L1, unless key was evicted since generation was taken.
+func (c *TieredCache) fill(ctx context.Context, key string, value string, expiresIn time.Duration, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generations[generationStripe(key)] == generation {
+		_ = c.l1.Set(ctx, key, value, expiresIn)
+	}
+}
+
+func (c *TieredCache) broadcast(ctx context.Context, inv invalidation) {
+	inv.Instance = c.instance
+	payload, _ := json.Marshal(inv)
+	if err := c.l2.Publish(ctx, invalidationChannel, string(payload)); err != nil {
+		log.Printf("failed to broadcast cache invalidation: %v\n", err)
+	}
+}
+
+// generationStripe picks the generation counter of key.
+func generationStripe(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % generationStripes)
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryShared is a sharedCache in memory, standing in for Redis. Its subscribers receive
+// every payload published after they subscribed, and afterGet, if set, runs between reading a
+// value and returning it.
+type memoryShared struct {
+	*MemoryCache
+	afterGet func()
+
+	mu          sync.Mutex
+	subscribers []chan string
+}
+
+func newMemoryShared() *memoryShared {
+	return &memoryShared{MemoryCache: NewMemoryCache(100)}
+}
+
+func (m *memoryShared) Get(ctx context.Context, key string) (string, time.Time, error) {
+	value, expiresAt, err := m.MemoryCache.Get(ctx, key)
+	if m.afterGet != nil {
+		m.afterGet()
+	}
+	return value, expiresAt, err
+}
+
+func (m *memoryShared) Publish(_ context.Context, _ string, payload string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, subscriber := range m.subscribers {
+		subscriber <- payload
+	}
+	return nil
+}
+
+func (m *memoryShared) Subscribe(context.Context, string) <-chan string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subscriber := make(chan string, 100)
+	m.subscribers = append(m.subscribers, subscriber)
+	return subscriber
+}
+
+func TestTieredCacheInvalidatesOtherReplicas(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shared := newMemoryShared()
+	writer := newTieredCache(NewMemoryCache(10), shared, time.Hour)
+	reader := newTieredCache(NewMemoryCache(10), shared, time.Hour)
+	go reader.Run(ctx)
+	for {
+		shared.mu.Lock()
+		subscribed := len(shared.subscribers) == 1
+		shared.mu.Unlock()
+		if subscribed {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	_ = writer.Set(ctx, "k", "1", time.Hour)
+	if value, _, err := reader.Get(ctx, "k"); err != nil || value != "1" {
+		t.Fatalf("expected 1, got %q (%v)", value, err)
+	}
+
+	// The reader now holds 1 in its L1 for an hour unless the broadcast evicts it
+	_ = writer.Set(ctx, "k", "2", time.Hour)
+	deadline := time.Now().Add(time.Second)
+	for {
+		value, _, err := reader.Get(ctx, "k")
+		if err == nil && value == "2" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the reader to see 2, got %q (%v)", value, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTieredCacheReadDoesNotUndoLocalWrite(t *testing.T) {
+	ctx := context.Background()
+	shared := newMemoryShared()
+	c := newTieredCache(NewMemoryCache(10), shared, time.Hour)
+	_ = shared.Set(ctx, "k", "1", time.Hour)
+
+	read := make(chan struct{})
+	resume := make(chan struct{})
+	shared.afterGet = func() {
+		shared.afterGet = nil
+		close(read)
+		<-resume
+	}
+
+	done := make(chan string)
+	go func() {
+		value, _, _ := c.Get(ctx, "k")
+		done <- value
+	}()
+
+	// Increment the key after the read has seen 1 but before it fills L1
+	<-read
+	if n, err := c.Incr(ctx, "k", time.Hour); err != nil || n != 2 {
+		t.Fatalf("expected the increment to return 2, got %d (%v)", n, err)
+	}
+	close(resume)
+	if value := <-done; value != "1" {
+		t.Fatalf("expected the racing read to return 1, got %q", value)
+	}
+
+	if value, _, err := c.Get(ctx, "k"); err != nil || value != "2" {
+		t.Fatalf("expected 2 after the increment, got %q (%v)", value, err)
+	}
+}
@@ -2,6 +2,10 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/LittleAksMax/bids-policy-service/internal/cache"
@@ -19,12 +23,76 @@ type AuthConfig struct {
 	APIKey            string
 }
 
+// TrashConfig controls how long soft-deleted policies are kept before being purged.
+type TrashConfig struct {
+	Retention     time.Duration
+	SweepInterval time.Duration
+}
+
+const (
+	CacheDriverRedis  = "redis"
+	CacheDriverMemory = "memory"
+	CacheDriverTiered = "tiered"
+)
+
+// CacheConfig selects the request cache, Redis unless CACHE_DRIVER says otherwise. The memory
+// driver keeps up to MaxEntries keys in process and needs no Redis settings. The tiered driver
+// puts such an in-process cache, whose entries live for at most L1TTL, in front of Redis.
+// Redis keys are prefixed with Namespace, e.g. "policy-service:prod", so that several services
+// and environments can share one Redis.
+// PolicyTTL and NotFoundTTL set how long policy lookups, and lookups of policies that do not
+// exist, stay cached with any driver, and ListTTL how long policy listings do. StaleTTL sets
+// how long last-known copies of both are kept to serve while the database is unavailable.
+type CacheConfig struct {
+	Driver      string
+	MaxEntries  int
+	L1TTL       time.Duration
+	PolicyTTL   time.Duration
+	NotFoundTTL time.Duration
+	ListTTL     time.Duration
+	StaleTTL    time.Duration
+	Namespace   string
+	Redis       *cache.RedisConnectionConfig
+}
+
+// SchedulerConfig controls how often scheduled policy changes are checked for being due.
+type SchedulerConfig struct {
+	Interval time.Duration
+}
+
+// CurrencyConfig holds exchange rates used to convert amounts between marketplaces, as units
+// of each currency per unit of a common base currency, e.g. GBP=1, EUR=1.17.
+type CurrencyConfig struct {
+	ExchangeRates map[string]float64
+}
+
+// WebhookConfig controls webhook delivery. Deliveries are attempted every DispatchInterval and
+// time out after Timeout. A failed delivery is retried up to MaxAttempts attempts in all,
+// waiting about Backoff before the first retry and twice as long before each one after that.
+type WebhookConfig struct {
+	DispatchInterval time.Duration
+	Timeout          time.Duration
+	MaxAttempts      int
+	Backoff          time.Duration
+}
+
+// EventConfig controls the policy event log. Events that were handed to webhooks, and webhook
+// deliveries that succeeded or gave up, are deleted once they are older than Retention.
+type EventConfig struct {
+	Retention time.Duration
+}
+
 type Config struct {
 	Port           int
 	AllowedOrigins []string
 	Auth           *AuthConfig
 	PolicyDB       *db.MongoConnectionConfig
-	PolicyCache    *cache.RedisConnectionConfig
+	PolicyCache    *CacheConfig
+	Trash          *TrashConfig
+	Scheduler      *SchedulerConfig
+	Currency       *CurrencyConfig
+	Webhooks       *WebhookConfig
+	Events         *EventConfig
 }
 
 func Load() (cfg *Config, err error) {
@@ -46,16 +114,108 @@ func Load() (cfg *Config, err error) {
 			APIKey:            env.GetStrFromEnv("API_KEY"),
 		},
 		PolicyDB: &db.MongoConnectionConfig{
-			Host:     env.GetStrFromEnv("MONGO_HOST"),
-			Port:     env.ReadPort("MONGO_PORT"),
-			User:     env.GetStrFromEnv("MONGO_USERNAME"),
-			Passwd:   env.GetStrFromEnv("MONGO_PASSWORD"),
-			Database: env.GetStrFromEnv("MONGO_DATABASE"),
+			Host:             env.GetStrFromEnv("MONGO_HOST"),
+			Port:             env.ReadPort("MONGO_PORT"),
+			User:             env.GetStrFromEnv("MONGO_USERNAME"),
+			Passwd:           env.GetStrFromEnv("MONGO_PASSWORD"),
+			Database:         env.GetStrFromEnv("MONGO_DATABASE"),
+			BreakerThreshold: parsePositiveInt("MONGO_BREAKER_THRESHOLD"),
+			RetryInterval:    env.ParseDurationEnv("MONGO_RETRY_INTERVAL"),
+		},
+		PolicyCache: loadCacheConfig(),
+		Trash: &TrashConfig{
+			Retention:     env.ParseDurationEnv("TRASH_RETENTION"),
+			SweepInterval: parsePositiveDuration("TRASH_SWEEP_INTERVAL"),
+		},
+		Scheduler: &SchedulerConfig{
+			Interval: parsePositiveDuration("SCHEDULER_INTERVAL"),
+		},
+		Currency: &CurrencyConfig{
+			ExchangeRates: parseExchangeRates(env.GetStrListFromEnv("EXCHANGE_RATES")),
+		},
+		Webhooks: &WebhookConfig{
+			DispatchInterval: parsePositiveDuration("WEBHOOK_DISPATCH_INTERVAL"),
+			Timeout:          env.ParseDurationEnv("WEBHOOK_TIMEOUT"),
+			MaxAttempts:      parsePositiveInt("WEBHOOK_MAX_ATTEMPTS"),
+			Backoff:          env.ParseDurationEnv("WEBHOOK_BACKOFF"),
 		},
-		PolicyCache: &cache.RedisConnectionConfig{
+		Events: &EventConfig{
+			Retention: parsePositiveDuration("EVENT_RETENTION"),
+		},
+	}, nil
+}
+
+// loadCacheConfig reads the settings of the configured cache driver, panicking like the env
+// helpers on an unknown driver.
+func loadCacheConfig() *CacheConfig {
+	cfg := &CacheConfig{
+		Driver:      cacheDriver(),
+		PolicyTTL:   env.ParseDurationEnv("CACHE_POLICY_TTL"),
+		NotFoundTTL: env.ParseDurationEnv("CACHE_NOT_FOUND_TTL"),
+		ListTTL:     env.ParseDurationEnv("CACHE_LIST_TTL"),
+		StaleTTL:    env.ParseDurationEnv("CACHE_STALE_TTL"),
+	}
+	switch cfg.Driver {
+	case CacheDriverMemory, CacheDriverRedis, CacheDriverTiered:
+	default:
+		panic(fmt.Sprintf("unknown cache driver %q", cfg.Driver))
+	}
+
+	if cfg.Driver != CacheDriverRedis {
+		cfg.MaxEntries = parsePositiveInt("CACHE_MAX_ENTRIES")
+	}
+	if cfg.Driver == CacheDriverTiered {
+		cfg.L1TTL = env.ParseDurationEnv("CACHE_L1_TTL")
+	}
+	if cfg.Driver != CacheDriverMemory {
+		cfg.Namespace = env.GetStrFromEnv("CACHE_NAMESPACE")
+		cfg.Redis = &cache.RedisConnectionConfig{
 			RedisHost:     env.GetStrFromEnv("REDIS_HOST"),
 			RedisPort:     env.ReadPort("REDIS_PORT"),
 			RedisPassword: env.GetStrFromEnv("REDIS_PASSWORD"),
-		},
-	}, nil
+		}
+	}
+	return cfg
+}
+
+// cacheDriver reads CACHE_DRIVER, defaulting to Redis, the only driver before it was configurable.
+func cacheDriver() string {
+	if driver, ok := os.LookupEnv("CACHE_DRIVER"); ok && driver != "" {
+		return driver
+	}
+	return CacheDriverRedis
+}
+
+// parsePositiveInt reads a positive integer from the environment, panicking like the env
+// helpers if it is missing or invalid.
+func parsePositiveInt(key string) int {
+	n, err := strconv.Atoi(env.GetStrFromEnv(key))
+	if err != nil || n <= 0 {
+		panic("invalid " + key)
+	}
+	return n
+}
+
+// parsePositiveDuration reads a positive duration from the environment, panicking like the env
+// helpers if it is missing or invalid.
+func parsePositiveDuration(key string) time.Duration {
+	d := env.ParseDurationEnv(key)
+	if d <= 0 {
+		panic("invalid " + key)
+	}
+	return d
+}
+
+// parseExchangeRates parses "CODE=rate" entries, panicking like the env helpers on a malformed one.
+func parseExchangeRates(entries []string) map[string]float64 {
+	rates := make(map[string]float64, len(entries))
+	for _, entry := range entries {
+		code, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		rate, err := strconv.ParseFloat(value, 64)
+		if !ok || err != nil || rate <= 0 {
+			panic(fmt.Sprintf("invalid exchange rate %q", entry))
+		}
+		rates[strings.ToUpper(strings.TrimSpace(code))] = rate
+	}
+	return rates
 }
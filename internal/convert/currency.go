@@ -0,0 +1,98 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrLossyConversion is returned when rounding converted bounds to two decimals would change
+// which branch of a condition a value falls into.
+var ErrLossyConversion = errors.New("converted amounts are too small to keep the script's intervals apart")
+
+// IsCurrency reports whether the metric is an amount of money in the marketplace's currency.
+func (m Metric) IsCurrency() bool {
+	switch m {
+	case MetricCPC, MetricSales, MetricSpend:
+		return true
+	}
+	return false
+}
+
+// ConvertCurrency returns a copy of the tree rooted at root with every absolute amount
+// multiplied by rate: the amounts of terminals that are not percentages, and the interval
+// bounds of conditions on currency metrics. Converted values are rounded to two decimals.
+// Percentages and bounds on other metrics are copied unchanged. It returns ErrLossyConversion
+// if rounding empties an interval or makes branches overlap that did not before.
+func ConvertCurrency(root *Node, rate float64) (*Node, error) {
+	if root == nil {
+		return nil, nil
+	}
+
+	node := &Node{}
+	if root.Terminal != nil {
+		terminal := *root.Terminal
+		if !terminal.Percentage {
+			terminal.Amount = roundAmount(terminal.Amount * rate)
+		}
+		node.Terminal = &terminal
+	}
+
+	if root.Condition != nil {
+		condition := *root.Condition
+		condition.Branches = make([]BranchNode, len(root.Condition.Branches))
+		for i, branch := range root.Condition.Branches {
+			converted := BranchNode{Lower: branch.Lower, Upper: branch.Upper}
+			if condition.Metric.IsCurrency() {
+				converted.Lower = convertBound(branch.Lower, rate)
+				converted.Upper = convertBound(branch.Upper, rate)
+			}
+			child, err := ConvertCurrency(&branch.Node, rate)
+			if err != nil {
+				return nil, err
+			}
+			converted.Node = *child
+			condition.Branches[i] = converted
+		}
+		if err := checkConvertedBranches(condition.Metric, root.Condition.Branches, condition.Branches); err != nil {
+			return nil, err
+		}
+
+		var err error
+		if condition.Default, err = ConvertCurrency(root.Condition.Default, rate); err != nil {
+			return nil, err
+		}
+		node.Condition = &condition
+	}
+
+	return node, nil
+}
+
+// checkConvertedBranches compares the intervals of a condition's branches before and after
+// conversion, reporting any that rounding emptied, inverted or made overlap.
+func checkConvertedBranches(metric Metric, before, after []BranchNode) error {
+	for i := range after {
+		if lowerBound(before[i]) < upperBound(before[i]) && lowerBound(after[i]) >= upperBound(after[i]) {
+			return fmt.Errorf("%w: condition on '%s': branch %d collapses to %s", ErrLossyConversion, metric, i+1, describeInterval(after[i]))
+		}
+		for j := 0; j < i; j++ {
+			if !intervalsOverlap(before[j], before[i]) && intervalsOverlap(after[j], after[i]) {
+				return fmt.Errorf("%w: condition on '%s': branch %d overlaps branch %d", ErrLossyConversion, metric, i+1, j+1)
+			}
+		}
+	}
+	return nil
+}
+
+func convertBound(bound *float64, rate float64) *float64 {
+	if bound == nil {
+		return nil
+	}
+	converted := roundAmount(*bound * rate)
+	return &converted
+}
+
+// roundAmount rounds to the two decimals scripts write amounts with.
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
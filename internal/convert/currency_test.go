@@ -0,0 +1,90 @@
+package convert
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConvertCurrency(t *testing.T) {
+	// cpc
+	// [_, 0.40] (=1.25)
+	// [0.41, _] (
+	//   clicks
+	//   [0, 10] (+5.00%)
+	//   default (+0.60)
+	// )
+	// default (-0.20)
+	root := &Node{Condition: &ConditionNode{
+		Metric: MetricCPC,
+		Branches: []BranchNode{
+			{Upper: float64Ptr(0.40), Node: Node{Terminal: &TerminalNode{Operator: OperatorSet, Amount: 1.25}}},
+			{Lower: float64Ptr(0.41), Node: Node{Condition: &ConditionNode{
+				Metric: MetricClicks,
+				Branches: []BranchNode{
+					{Lower: float64Ptr(0), Upper: float64Ptr(10), Node: Node{Terminal: &TerminalNode{Operator: OperatorAdd, Amount: 5, Percentage: true}}},
+				},
+				Default: &Node{Terminal: &TerminalNode{Operator: OperatorAdd, Amount: 0.60}},
+			}}},
+		},
+		Default: &Node{Terminal: &TerminalNode{Operator: OperatorSub, Amount: 0.20}},
+	}}
+
+	converted, err := ConvertCurrency(root, 1.17)
+	if err != nil {
+		t.Fatalf("expected conversion to succeed, got %v", err)
+	}
+
+	cpc := converted.Condition
+	if cpc.Branches[0].Lower != nil || *cpc.Branches[0].Upper != 0.47 {
+		t.Fatalf("expected cpc interval [_, 0.47], got %s", describeInterval(cpc.Branches[0]))
+	}
+	if amount := cpc.Branches[0].Node.Terminal.Amount; amount != 1.46 {
+		t.Fatalf("expected =1.46, got %.2f", amount)
+	}
+	if *cpc.Branches[1].Lower != 0.48 {
+		t.Fatalf("expected cpc lower bound 0.48, got %.2f", *cpc.Branches[1].Lower)
+	}
+	if amount := cpc.Default.Terminal.Amount; amount != 0.23 {
+		t.Fatalf("expected -0.23, got %.2f", amount)
+	}
+
+	clicks := cpc.Branches[1].Node.Condition
+	if *clicks.Branches[0].Lower != 0 || *clicks.Branches[0].Upper != 10 {
+		t.Fatalf("expected clicks interval [0, 10] to be unchanged, got %s", describeInterval(clicks.Branches[0]))
+	}
+	if amount := clicks.Branches[0].Node.Terminal.Amount; amount != 5 {
+		t.Fatalf("expected percentage to be unchanged, got %.2f", amount)
+	}
+	if amount := clicks.Default.Terminal.Amount; amount != 0.70 {
+		t.Fatalf("expected +0.70, got %.2f", amount)
+	}
+
+	if *root.Condition.Branches[0].Upper != 0.40 || root.Condition.Default.Terminal.Amount != 0.20 {
+		t.Fatal("expected the original tree to be left unchanged")
+	}
+}
+
+func TestConvertCurrencyRejectsLossyBounds(t *testing.T) {
+	// cpc
+	// [_, 0.40] (=1.25)
+	// [0.41, 0.44] (=1.50)
+	// default (-0.20)
+	root := &Node{Condition: &ConditionNode{
+		Metric: MetricCPC,
+		Branches: []BranchNode{
+			{Upper: float64Ptr(0.40), Node: Node{Terminal: &TerminalNode{Operator: OperatorSet, Amount: 1.25}}},
+			{Lower: float64Ptr(0.41), Upper: float64Ptr(0.44), Node: Node{Terminal: &TerminalNode{Operator: OperatorSet, Amount: 1.50}}},
+		},
+		Default: &Node{Terminal: &TerminalNode{Operator: OperatorSub, Amount: 0.20}},
+	}}
+
+	// 0.40 and 0.41 both round to 0.12, so the branches would overlap
+	if _, err := ConvertCurrency(root, 0.3); !errors.Is(err, ErrLossyConversion) {
+		t.Fatalf("expected ErrLossyConversion for overlapping branches, got %v", err)
+	}
+
+	// 0.41 and 0.44 both round to 0.01, so the second interval would hold a single value
+	if _, err := ConvertCurrency(root, 0.025); !errors.Is(err, ErrLossyConversion) {
+		t.Fatalf("expected ErrLossyConversion for a collapsed interval, got %v", err)
+	}
+}
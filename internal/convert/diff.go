@@ -0,0 +1,110 @@
+package convert
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type TreeChangeKind string
+
+const (
+	TreeChangeAdded   TreeChangeKind = "added"
+	TreeChangeRemoved TreeChangeKind = "removed"
+	TreeChangeChanged TreeChangeKind = "changed"
+)
+
+// TreeChange is one difference between two trees. Path locates the node, e.g.
+// "root.branches[1].default", and Before/After describe it on each side.
+type TreeChange struct {
+	Path   string         `json:"path"`
+	Kind   TreeChangeKind `json:"kind"`
+	Before string         `json:"before,omitempty"`
+	After  string         `json:"after,omitempty"`
+}
+
+// DiffTrees lists the changes that turn before into after. Branches are compared by
+// position, and a condition that switches metric is reported as a single change.
+func DiffTrees(before, after *Node) []TreeChange {
+	changes := make([]TreeChange, 0)
+	diffNodes("root", before, after, &changes)
+	return changes
+}
+
+func diffNodes(path string, before, after *Node, changes *[]TreeChange) {
+	switch {
+	case before == nil && after == nil:
+		return
+	case before == nil:
+		*changes = append(*changes, TreeChange{Path: path, Kind: TreeChangeAdded, After: describeNode(after)})
+		return
+	case after == nil:
+		*changes = append(*changes, TreeChange{Path: path, Kind: TreeChangeRemoved, Before: describeNode(before)})
+		return
+	}
+
+	if before.Terminal != nil && after.Terminal != nil {
+		if *before.Terminal != *after.Terminal {
+			*changes = append(*changes, changed(path, before, after))
+		}
+		return
+	}
+
+	if before.Condition == nil || after.Condition == nil || before.Condition.Metric != after.Condition.Metric {
+		*changes = append(*changes, changed(path, before, after))
+		return
+	}
+
+	beforeBranches, afterBranches := before.Condition.Branches, after.Condition.Branches
+	for i := 0; i < max(len(beforeBranches), len(afterBranches)); i++ {
+		branchPath := fmt.Sprintf("%s.branches[%d]", path, i)
+		switch {
+		case i >= len(beforeBranches):
+			*changes = append(*changes, TreeChange{Path: branchPath, Kind: TreeChangeAdded, After: describeBranch(afterBranches[i])})
+		case i >= len(afterBranches):
+			*changes = append(*changes, TreeChange{Path: branchPath, Kind: TreeChangeRemoved, Before: describeBranch(beforeBranches[i])})
+		default:
+			beforeInterval, afterInterval := describeInterval(beforeBranches[i]), describeInterval(afterBranches[i])
+			if beforeInterval != afterInterval {
+				*changes = append(*changes, TreeChange{Path: branchPath + ".interval", Kind: TreeChangeChanged, Before: beforeInterval, After: afterInterval})
+			}
+			diffNodes(branchPath, &beforeBranches[i].Node, &afterBranches[i].Node, changes)
+		}
+	}
+
+	diffNodes(path+".default", before.Condition.Default, after.Condition.Default, changes)
+}
+
+func changed(path string, before, after *Node) TreeChange {
+	return TreeChange{Path: path, Kind: TreeChangeChanged, Before: describeNode(before), After: describeNode(after)}
+}
+
+// describeNode renders a node briefly: terminals in script syntax, conditions by their metric.
+func describeNode(node *Node) string {
+	switch {
+	case node.Terminal != nil:
+		description := string(rune(node.Terminal.Operator)) + strconv.FormatFloat(node.Terminal.Amount, 'f', 2, 64)
+		if node.Terminal.Percentage {
+			description += "%"
+		}
+		return description
+	case node.Condition != nil:
+		return fmt.Sprintf("condition on '%s'", node.Condition.Metric)
+	default:
+		return "empty node"
+	}
+}
+
+func describeBranch(branch BranchNode) string {
+	return describeInterval(branch) + " " + describeNode(&branch.Node)
+}
+
+func describeInterval(branch BranchNode) string {
+	return "[" + describeBound(branch.Lower) + ", " + describeBound(branch.Upper) + "]"
+}
+
+func describeBound(bound *float64) string {
+	if bound == nil {
+		return "_"
+	}
+	return strconv.FormatFloat(*bound, 'f', -1, 64)
+}
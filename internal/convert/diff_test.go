@@ -0,0 +1,72 @@
+package convert
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiffTreesIdentical(t *testing.T) {
+	tree := func() *Node {
+		return &Node{Condition: &ConditionNode{
+			Metric: MetricACoS,
+			Branches: []BranchNode{
+				{Upper: float64Ptr(0.3), Node: Node{Terminal: &TerminalNode{Operator: OperatorAdd, Amount: 5, Percentage: true}}},
+			},
+			Default: &Node{Terminal: &TerminalNode{Operator: OperatorSet, Amount: 1}},
+		}}
+	}
+
+	if changes := DiffTrees(tree(), tree()); len(changes) != 0 {
+		t.Fatalf("expected no changes, got %+v", changes)
+	}
+}
+
+func TestDiffTreesReportsChanges(t *testing.T) {
+	before := &Node{Condition: &ConditionNode{
+		Metric: MetricACoS,
+		Branches: []BranchNode{
+			{Upper: float64Ptr(0.3), Node: Node{Terminal: &TerminalNode{Operator: OperatorAdd, Amount: 5, Percentage: true}}},
+			{Lower: float64Ptr(0.5), Node: Node{Terminal: &TerminalNode{Operator: OperatorSub, Amount: 0.25}}},
+		},
+		Default: &Node{Terminal: &TerminalNode{Operator: OperatorSet, Amount: 1}},
+	}}
+	after := &Node{Condition: &ConditionNode{
+		Metric: MetricACoS,
+		Branches: []BranchNode{
+			{Upper: float64Ptr(0.25), Node: Node{Terminal: &TerminalNode{Operator: OperatorAdd, Amount: 10, Percentage: true}}},
+		},
+		Default: &Node{Condition: &ConditionNode{
+			Metric: MetricClicks,
+			Branches: []BranchNode{
+				{Upper: float64Ptr(10), Node: Node{Terminal: &TerminalNode{Operator: OperatorSet, Amount: 0.5}}},
+			},
+		}},
+	}}
+
+	expected := []TreeChange{
+		{Path: "root.branches[0].interval", Kind: TreeChangeChanged, Before: "[_, 0.3]", After: "[_, 0.25]"},
+		{Path: "root.branches[0]", Kind: TreeChangeChanged, Before: "+5.00%", After: "+10.00%"},
+		{Path: "root.branches[1]", Kind: TreeChangeRemoved, Before: "[0.5, _] -0.25"},
+		{Path: "root.default", Kind: TreeChangeChanged, Before: "=1.00", After: "condition on 'clicks'"},
+	}
+
+	if changes := DiffTrees(before, after); !slices.Equal(changes, expected) {
+		t.Fatalf("unexpected changes:\n got: %+v\nwant: %+v", changes, expected)
+	}
+}
+
+func TestDiffTreesReportsMetricSwitchAsOneChange(t *testing.T) {
+	before := &Node{Condition: &ConditionNode{
+		Metric:   MetricACoS,
+		Branches: []BranchNode{{Node: Node{Terminal: &TerminalNode{Operator: OperatorSet, Amount: 1}}}},
+	}}
+	after := &Node{Condition: &ConditionNode{
+		Metric:   MetricRoaS,
+		Branches: []BranchNode{{Node: Node{Terminal: &TerminalNode{Operator: OperatorSet, Amount: 2}}}},
+	}}
+
+	changes := DiffTrees(before, after)
+	if len(changes) != 1 || changes[0].Path != "root" || changes[0].Kind != TreeChangeChanged {
+		t.Fatalf("expected a single change at root, got %+v", changes)
+	}
+}
@@ -0,0 +1,56 @@
+package convert
+
+import (
+	"fmt"
+	"math"
+)
+
+// LintTree reports structurally valid trees that are still likely mistakes: conditions
+// whose branch intervals overlap, making the later branch partly unreachable, and
+// conditions without any branches.
+func LintTree(root *Node) []error {
+	errs := make([]error, 0)
+	lintNode(root, &errs)
+	return errs
+}
+
+func lintNode(node *Node, errs *[]error) {
+	if node == nil || node.Condition == nil {
+		return
+	}
+
+	condition := node.Condition
+	if len(condition.Branches) == 0 {
+		*errs = append(*errs, fmt.Errorf("condition on '%s' has no branches", condition.Metric))
+	}
+
+	for i := range condition.Branches {
+		for j := 0; j < i; j++ {
+			if intervalsOverlap(condition.Branches[j], condition.Branches[i]) {
+				*errs = append(*errs, fmt.Errorf("condition on '%s': branch %d overlaps branch %d", condition.Metric, i+1, j+1))
+			}
+		}
+		lintNode(&condition.Branches[i].Node, errs)
+	}
+
+	lintNode(condition.Default, errs)
+}
+
+// intervalsOverlap reports whether two closed intervals share a value; nil bounds are unbounded.
+func intervalsOverlap(a, b BranchNode) bool {
+	return lowerBound(a) <= upperBound(b) && lowerBound(b) <= upperBound(a)
+}
+
+func lowerBound(branch BranchNode) float64 {
+	if branch.Lower == nil {
+		return math.Inf(-1)
+	}
+	return *branch.Lower
+}
+
+func upperBound(branch BranchNode) float64 {
+	if branch.Upper == nil {
+		return math.Inf(1)
+	}
+	return *branch.Upper
+}
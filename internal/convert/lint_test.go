@@ -0,0 +1,81 @@
+package convert
+
+import "testing"
+
+func TestLintTreeAcceptsDisjointBranches(t *testing.T) {
+	root := &Node{
+		Condition: &ConditionNode{
+			Metric: MetricClicks,
+			Branches: []BranchNode{
+				{Upper: float64Ptr(10), Node: Node{Terminal: &TerminalNode{Operator: OperatorAdd, Amount: 1}}},
+				{Lower: float64Ptr(11), Upper: float64Ptr(20), Node: Node{Terminal: &TerminalNode{Operator: OperatorSub, Amount: 1}}},
+				{Lower: float64Ptr(21), Node: Node{Terminal: &TerminalNode{Operator: OperatorSet, Amount: 1}}},
+			},
+		},
+	}
+
+	if errs := LintTree(root); len(errs) != 0 {
+		t.Fatalf("expected no lint errors, got %v", errs)
+	}
+}
+
+func TestLintTreeReportsOverlappingBranches(t *testing.T) {
+	tests := []struct {
+		name     string
+		branches []BranchNode
+	}{
+		{
+			name: "shared bound",
+			branches: []BranchNode{
+				{Lower: float64Ptr(0), Upper: float64Ptr(10)},
+				{Lower: float64Ptr(10), Upper: float64Ptr(20)},
+			},
+		},
+		{
+			name: "unbounded lower",
+			branches: []BranchNode{
+				{Upper: float64Ptr(50)},
+				{Lower: float64Ptr(5), Upper: float64Ptr(6)},
+			},
+		},
+		{
+			name: "both unbounded",
+			branches: []BranchNode{
+				{},
+				{Lower: float64Ptr(100)},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i := range test.branches {
+				test.branches[i].Node = Node{Terminal: &TerminalNode{Operator: OperatorSet, Amount: 1}}
+			}
+			root := &Node{Condition: &ConditionNode{Metric: MetricClicks, Branches: test.branches}}
+
+			if errs := LintTree(root); len(errs) != 1 {
+				t.Fatalf("expected one lint error, got %v", errs)
+			}
+		})
+	}
+}
+
+func TestLintTreeReportsNestedConditionWithoutBranches(t *testing.T) {
+	root := &Node{
+		Condition: &ConditionNode{
+			Metric: MetricACoS,
+			Branches: []BranchNode{
+				{Upper: float64Ptr(0.3), Node: Node{Terminal: &TerminalNode{Operator: OperatorAdd, Amount: 1}}},
+			},
+			Default: &Node{Condition: &ConditionNode{
+				Metric:  MetricCPC,
+				Default: &Node{Terminal: &TerminalNode{Operator: OperatorSet, Amount: 1}},
+			}},
+		},
+	}
+
+	if errs := LintTree(root); len(errs) != 1 {
+		t.Fatalf("expected one lint error, got %v", errs)
+	}
+}
@@ -0,0 +1,96 @@
+package convert
+
+import "slices"
+
+// TreeSummary is a flat description of what a policy tree depends on and does,
+// suitable for storing alongside the policy and querying on.
+type TreeSummary struct {
+	// Metrics lists each metric the tree branches on, in first-seen order.
+	Metrics []Metric
+	// MaxDepth is the number of nested conditions on the deepest path; a lone terminal has depth 0.
+	MaxDepth int
+	// BranchCount is the number of interval branches across all conditions, excluding defaults.
+	BranchCount int
+	// Operators lists each terminal operator used, in first-seen order.
+	Operators []Operator
+	// MinBidChange and MaxBidChange bound the signed absolute amounts of '+' and '-'
+	// terminals. They are nil when no such terminal changes bids by an absolute amount.
+	MinBidChange *float64
+	MaxBidChange *float64
+	// MinPercentBidChange and MaxPercentBidChange do the same for percentage terminals.
+	MinPercentBidChange *float64
+	MaxPercentBidChange *float64
+}
+
+// Summarize walks the trees rooted at roots and collects one TreeSummary covering them all, so
+// that a policy can be summarized along with the trees that run in its place. MaxDepth is that
+// of the deepest tree, and BranchCount the total across them.
+func Summarize(roots ...*Node) TreeSummary {
+	summary := TreeSummary{
+		Metrics:   make([]Metric, 0),
+		Operators: make([]Operator, 0),
+	}
+	for _, root := range roots {
+		summarizeNode(root, 0, &summary)
+	}
+	return summary
+}
+
+func summarizeNode(node *Node, depth int, summary *TreeSummary) {
+	if node == nil {
+		return
+	}
+
+	if node.Terminal != nil {
+		summarizeTerminal(node.Terminal, summary)
+	}
+
+	if node.Condition != nil {
+		condition := node.Condition
+		depth++
+		summary.MaxDepth = max(summary.MaxDepth, depth)
+		if !slices.Contains(summary.Metrics, condition.Metric) {
+			summary.Metrics = append(summary.Metrics, condition.Metric)
+		}
+
+		summary.BranchCount += len(condition.Branches)
+		for i := range condition.Branches {
+			summarizeNode(&condition.Branches[i].Node, depth, summary)
+		}
+		summarizeNode(condition.Default, depth, summary)
+	}
+}
+
+func summarizeTerminal(terminal *TerminalNode, summary *TreeSummary) {
+	if !slices.Contains(summary.Operators, terminal.Operator) {
+		summary.Operators = append(summary.Operators, terminal.Operator)
+	}
+
+	var change float64
+	switch terminal.Operator {
+	case OperatorAdd:
+		change = terminal.Amount
+	case OperatorSub:
+		change = -terminal.Amount
+	default:
+		// Setting a bid is not a change relative to the current bid
+		return
+	}
+
+	// Percentages and absolute amounts are not comparable, so each unit is bounded separately
+	if terminal.Percentage {
+		widen(&summary.MinPercentBidChange, &summary.MaxPercentBidChange, change)
+	} else {
+		widen(&summary.MinBidChange, &summary.MaxBidChange, change)
+	}
+}
+
+// widen extends the range [*lo, *hi] to include value, starting it if it is empty.
+func widen(lo, hi **float64, value float64) {
+	if *lo == nil || value < **lo {
+		*lo = &value
+	}
+	if *hi == nil || value > **hi {
+		*hi = &value
+	}
+}
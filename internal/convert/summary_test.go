@@ -0,0 +1,95 @@
+package convert
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSummarizeTerminalOnly(t *testing.T) {
+	summary := Summarize(&Node{Terminal: &TerminalNode{Operator: OperatorSet, Amount: 1.25}})
+
+	if summary.MaxDepth != 0 {
+		t.Fatalf("expected depth 0, got %d", summary.MaxDepth)
+	}
+	if summary.BranchCount != 0 {
+		t.Fatalf("expected 0 branches, got %d", summary.BranchCount)
+	}
+	if len(summary.Metrics) != 0 {
+		t.Fatalf("expected no metrics, got %v", summary.Metrics)
+	}
+	if !slices.Equal(summary.Operators, []Operator{OperatorSet}) {
+		t.Fatalf("expected operators [=], got %q", summary.Operators)
+	}
+	if summary.MinBidChange != nil || summary.MaxBidChange != nil || summary.MinPercentBidChange != nil || summary.MaxPercentBidChange != nil {
+		t.Fatal("expected no bid change bounds for a set-only tree")
+	}
+}
+
+func TestSummarizeNestedConditions(t *testing.T) {
+	// acos
+	// [_, 0.20] (
+	//   clicks
+	//   [0, 10] (+5.00%)
+	//   [11, _] (+0.50)
+	// )
+	// default (
+	//   acos
+	//   [0.50, _] (-0.25)
+	//   default (=1.00)
+	// )
+	root := &Node{
+		Condition: &ConditionNode{
+			Metric: MetricACoS,
+			Branches: []BranchNode{
+				{
+					Upper: float64Ptr(0.20),
+					Node: Node{Condition: &ConditionNode{
+						Metric: MetricClicks,
+						Branches: []BranchNode{
+							{Lower: float64Ptr(0), Upper: float64Ptr(10), Node: Node{Terminal: &TerminalNode{Operator: OperatorAdd, Amount: 5, Percentage: true}}},
+							{Lower: float64Ptr(11), Node: Node{Terminal: &TerminalNode{Operator: OperatorAdd, Amount: 0.50}}},
+						},
+					}},
+				},
+			},
+			Default: &Node{Condition: &ConditionNode{
+				Metric: MetricACoS,
+				Branches: []BranchNode{
+					{Lower: float64Ptr(0.50), Node: Node{Terminal: &TerminalNode{Operator: OperatorSub, Amount: 0.25}}},
+				},
+				Default: &Node{Terminal: &TerminalNode{Operator: OperatorSet, Amount: 1}},
+			}},
+		},
+	}
+
+	summary := Summarize(root)
+
+	if !slices.Equal(summary.Metrics, []Metric{MetricACoS, MetricClicks}) {
+		t.Fatalf("expected metrics [acos clicks], got %v", summary.Metrics)
+	}
+	if summary.MaxDepth != 2 {
+		t.Fatalf("expected depth 2, got %d", summary.MaxDepth)
+	}
+	if summary.BranchCount != 4 {
+		t.Fatalf("expected 4 branches, got %d", summary.BranchCount)
+	}
+	if !slices.Equal(summary.Operators, []Operator{OperatorAdd, OperatorSub, OperatorSet}) {
+		t.Fatalf("expected operators [+ - =], got %q", summary.Operators)
+	}
+	if summary.MinBidChange == nil || *summary.MinBidChange != -0.25 {
+		t.Fatalf("expected min bid change -0.25, got %v", summary.MinBidChange)
+	}
+	if summary.MaxBidChange == nil || *summary.MaxBidChange != 0.50 {
+		t.Fatalf("expected max bid change 0.50, got %v", summary.MaxBidChange)
+	}
+	if summary.MinPercentBidChange == nil || *summary.MinPercentBidChange != 5 {
+		t.Fatalf("expected min percent bid change 5, got %v", summary.MinPercentBidChange)
+	}
+	if summary.MaxPercentBidChange == nil || *summary.MaxPercentBidChange != 5 {
+		t.Fatalf("expected max percent bid change 5, got %v", summary.MaxPercentBidChange)
+	}
+}
+
+func float64Ptr(value float64) *float64 {
+	return &value
+}
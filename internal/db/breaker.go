@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// ErrUnavailable is returned instead of calling the database while the circuit breaker is open.
+var ErrUnavailable = errors.New("database unavailable")
+
+// IsUnavailable reports whether err means the database could not be reached, as opposed to
+// the database rejecting the operation: no server could be selected, or the connection failed.
+// Errors caused by the caller's context being cancelled or running out of time, including
+// while selecting a server, say nothing about the database and are not counted.
+func IsUnavailable(err error) bool {
+	if errors.Is(err, ErrUnavailable) || errors.Is(err, mongo.ErrClientDisconnected) {
+		return true
+	}
+	if isContextError(err) {
+		return false
+	}
+	var selection topology.ServerSelectionError
+	return errors.As(err, &selection) || mongo.IsNetworkError(err)
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Breaker is a circuit breaker for the database. After threshold consecutive calls fail to
+// reach it, the breaker opens and calls fail fast with ErrUnavailable rather than waiting on
+// timeouts. Run probes the database in the background and closes the breaker once it answers.
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	interval  time.Duration
+	failures  int
+	openedAt  time.Time // zero while closed
+}
+
+// NewBreaker creates a closed Breaker that opens after threshold consecutive failures and,
+// while open, probes the database every interval.
+func NewBreaker(threshold int, interval time.Duration) *Breaker {
+	return &Breaker{threshold: max(threshold, 1), interval: interval}
+}
+
+// Open reports whether calls are currently being rejected.
+func (b *Breaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.openedAt.IsZero()
+}
+
+// Do runs fn unless the breaker is open, counting failures to reach the database.
+func (b *Breaker) Do(fn func() error) error {
+	if b.Open() {
+		return ErrUnavailable
+	}
+	err := fn()
+	b.record(err)
+	return err
+}
+
+func (b *Breaker) record(err error) {
+	if isContextError(err) {
+		// The caller gave up before the database answered either way
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !IsUnavailable(err) {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold && b.openedAt.IsZero() {
+		b.openedAt = time.Now()
+		log.Printf("database circuit breaker opened after %d failures: %v\n", b.failures, err)
+	}
+}
+
+// trip opens the breaker straight away.
+func (b *Breaker) trip(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openedAt.IsZero() {
+		b.openedAt = time.Now()
+		log.Printf("database circuit breaker opened: %v\n", err)
+	}
+}
+
+// Run probes the database with ping while the breaker is open, closing it once a probe
+// succeeds, until ctx is cancelled.
+func (b *Breaker) Run(ctx context.Context, ping func(ctx context.Context) error) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !b.Open() {
+				continue
+			}
+			probeCtx, cancel := context.WithTimeout(ctx, b.interval)
+			err := ping(probeCtx)
+			cancel()
+			if err != nil {
+				log.Printf("database still unavailable: %v\n", err)
+				continue
+			}
+			b.close()
+		}
+	}
+}
+
+func (b *Breaker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	log.Printf("database circuit breaker closed after %s\n", time.Since(b.openedAt).Round(time.Second))
+	b.failures = 0
+	b.openedAt = time.Time{}
+}
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	b := NewBreaker(2, time.Minute)
+	unreachable := func() error { return ErrUnavailable }
+	rejected := func() error { return errors.New("duplicate key") }
+
+	_ = b.Do(unreachable)
+	_ = b.Do(rejected)
+	_ = b.Do(unreachable)
+	if b.Open() {
+		t.Fatal("expected an error from a reachable database to reset the failure count")
+	}
+
+	_ = b.Do(unreachable)
+	if !b.Open() {
+		t.Fatal("expected the breaker to open after 2 consecutive failures")
+	}
+
+	called := false
+	err := b.Do(func() error { called = true; return nil })
+	if called || !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected an open breaker to fail fast, got %v (called=%v)", err, called)
+	}
+}
+
+func TestBreakerClosesWhenProbeSucceeds(t *testing.T) {
+	b := NewBreaker(1, 10*time.Millisecond)
+	_ = b.Do(func() error { return ErrUnavailable })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Run(ctx, func(context.Context) error { return nil })
+
+	deadline := time.Now().Add(time.Second)
+	for b.Open() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected a successful probe to close the breaker")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestIsUnavailableIgnoresCallerContext(t *testing.T) {
+	if !IsUnavailable(topology.ServerSelectionError{Wrapped: topology.ErrServerSelectionTimeout}) {
+		t.Fatal("expected a server selection timeout to mean the database is unavailable")
+	}
+	if IsUnavailable(topology.ServerSelectionError{Wrapped: context.DeadlineExceeded}) {
+		t.Fatal("expected the caller's deadline passing during server selection not to count")
+	}
+	if IsUnavailable(fmt.Errorf("find: %w", context.Canceled)) {
+		t.Fatal("expected a cancelled request not to count")
+	}
+
+	b := NewBreaker(2, time.Minute)
+	unreachable := func() error { return ErrUnavailable }
+	_ = b.Do(unreachable)
+	_ = b.Do(func() error { return context.DeadlineExceeded })
+	_ = b.Do(unreachable)
+	if !b.Open() {
+		t.Fatal("expected a request running out of time neither to count nor to reset the failures")
+	}
+}
+
+func TestSetupRetriesUntilReachable(t *testing.T) {
+	b := NewBreaker(5, time.Millisecond)
+	attempts := 0
+	optional := false
+	err := b.Setup(context.Background(),
+		SetupStep{Name: "indexes", Run: func(context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return ErrUnavailable
+			}
+			return nil
+		}},
+		SetupStep{Name: "pre-images", Optional: true, Run: func(context.Context) error {
+			optional = true
+			return errors.New("not supported")
+		}},
+	)
+	if err != nil || attempts != 3 || !optional {
+		t.Fatalf("expected the step to be retried and the optional failure ignored, got %v after %d attempts", err, attempts)
+	}
+
+	err = b.Setup(context.Background(), SetupStep{Name: "names", Run: func(context.Context) error {
+		return errors.New("duplicate names")
+	}})
+	if err == nil {
+		t.Fatal("expected a required step rejected by the database to fail setup")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/LittleAksMax/bids-policy-service/internal/health"
 	"go.mongodb.org/mongo-driver/bson"
@@ -11,12 +12,17 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// MongoConnectionConfig locates the database. BreakerThreshold is how many consecutive calls
+// may fail to reach it before the circuit breaker opens, and RetryInterval how often the
+// database is probed while the breaker is open.
 type MongoConnectionConfig struct {
-	Host     string
-	Port     int
-	User     string
-	Passwd   string
-	Database string
+	Host             string
+	Port             int
+	User             string
+	Passwd           string
+	Database         string
+	BreakerThreshold int
+	RetryInterval    time.Duration
 }
 
 // DSN builds a MongoDB connection string from component parts.
@@ -32,6 +38,7 @@ func (connCfg *MongoConnectionConfig) DSN() string {
 type Config struct {
 	Client   *mongo.Client
 	Database *mongo.Database
+	Breaker  *Breaker
 	health.HealthChecker
 }
 
@@ -54,17 +61,22 @@ func Connect(ctx context.Context, connCfg *MongoConnectionConfig) (*Config, erro
 	// database
 	db := client.Database(connCfg.Database)
 
-	// Send a ping to confirm a successful connection
-	var result bson.M
-	if err := db.RunCommand(ctx, bson.D{{"ping", 1}}).Decode(&result); err != nil {
-		panic(err)
+	cfg := &Config{
+		Client:   client,
+		Database: db,
+		Breaker:  NewBreaker(connCfg.BreakerThreshold, connCfg.RetryInterval),
+	}
+
+	// Send a ping to confirm a successful connection. The service can start without the
+	// database, serving what it has cached, so a failure opens the breaker instead.
+	if err := cfg.HealthCheck(ctx); err != nil {
+		log.Printf("failed to ping MongoDB, starting in read-only mode: %v\n", err)
+		cfg.Breaker.trip(err)
+		return cfg, nil
 	}
 	log.Println("Pinged your deployment. You successfully connected to MongoDB!")
 
-	return &Config{
-		Client:   client,
-		Database: db,
-	}, nil
+	return cfg, nil
 }
 
 func (cfg *Config) HealthCheck(ctx context.Context) error {
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// SetupStep is an idempotent step that prepares the database, such as building indexes.
+// A failed Optional step only disables a feature, so it is logged rather than returned.
+type SetupStep struct {
+	Name     string
+	Run      func(ctx context.Context) error
+	Optional bool
+}
+
+// Setup runs steps in order through the breaker, so that the service can start while the
+// database is unavailable. A step that cannot reach the database is retried every probe
+// interval, failing fast while the breaker is open. Setup blocks until every step has run or
+// ctx is cancelled, and returns the first error the database gave for a required step.
+func (b *Breaker) Setup(ctx context.Context, steps ...SetupStep) error {
+	for _, step := range steps {
+		for {
+			err := b.Do(func() error { return step.Run(ctx) })
+			if err == nil {
+				break
+			}
+			if !IsUnavailable(err) {
+				if !step.Optional {
+					return fmt.Errorf("%s: %w", step.Name, err)
+				}
+				log.Printf("database setup step %q failed: %v\n", step.Name, err)
+				break
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(b.interval):
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+)
+
+const (
+	PolicyCreated = repository.EventPolicyCreated
+	PolicyUpdated = repository.EventPolicyUpdated
+	PolicyDeleted = repository.EventPolicyDeleted
+	PolicyPurged  = repository.EventPolicyPurged
+)
+
+// PolicyEvent is a change to a stored policy, however it was made. Deleted means moved to
+// the trash and Purged removed for good, in which case Policy is nil. UserID and Marketplace
+// are empty when a purged policy could not be identified.
+type PolicyEvent struct {
+	Type        string             `json:"type"`
+	PolicyID    string             `json:"policy_id"`
+	UserID      string             `json:"user_id,omitempty"`
+	Marketplace string             `json:"marketplace,omitempty"`
+	Policy      *repository.Policy `json:"policy,omitempty"`
+	OccurredAt  time.Time          `json:"occurred_at"`
+}
+
+// Bus fans policy events out to in-process subscribers.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[chan PolicyEvent]struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[chan PolicyEvent]struct{})}
+}
+
+// Subscribe returns a channel receiving every event published from now on, buffering up to
+// buffer of them, and a function that unsubscribes and closes the channel.
+func (b *Bus) Subscribe(buffer int) (<-chan PolicyEvent, func()) {
+	ch := make(chan PolicyEvent, buffer)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish delivers e to every subscriber without blocking. A subscriber whose buffer is full
+// misses the event.
+func (b *Bus) Publish(e PolicyEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			log.Printf("dropped %s event for policy %s: subscriber is not keeping up\n", e.Type, e.PolicyID)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package events
+
+import "testing"
+
+func TestBusDeliversToSubscribers(t *testing.T) {
+	b := NewBus()
+	first, unsubscribeFirst := b.Subscribe(1)
+	second, unsubscribeSecond := b.Subscribe(1)
+	defer unsubscribeSecond()
+
+	b.Publish(PolicyEvent{Type: PolicyCreated, PolicyID: "1"})
+	if e := <-first; e.PolicyID != "1" {
+		t.Fatalf("expected the first subscriber to receive the event, got %+v", e)
+	}
+	if e := <-second; e.PolicyID != "1" {
+		t.Fatalf("expected the second subscriber to receive the event, got %+v", e)
+	}
+
+	unsubscribeFirst()
+	if _, open := <-first; open {
+		t.Fatal("expected unsubscribing to close the channel")
+	}
+
+	// A full buffer drops the event rather than blocking the publisher
+	b.Publish(PolicyEvent{Type: PolicyUpdated, PolicyID: "1"})
+	b.Publish(PolicyEvent{Type: PolicyUpdated, PolicyID: "2"})
+	if e := <-second; e.PolicyID != "1" {
+		t.Fatalf("expected the buffered event, got %+v", e)
+	}
+	select {
+	case e := <-second:
+		t.Fatalf("expected the event that did not fit to be dropped, got %+v", e)
+	default:
+	}
+}
@@ -6,3 +6,24 @@ import "context"
 type HealthChecker interface {
 	HealthCheck(ctx context.Context) error
 }
+
+// Optional wraps a HealthChecker for a dependency the service can run without, such as a
+// cache. Its failures are reported as degraded rather than making the service unhealthy.
+type Optional struct {
+	HealthChecker
+}
+
+// Fallback wraps a HealthChecker for a dependency whose outages the service rides out on
+// another, such as the database behind the cache. Its failures are reported as degraded
+// rather than unhealthy while Active reports that the service is running without it and
+// Backup is healthy, so that instances stay in rotation for the outage.
+type Fallback struct {
+	HealthChecker
+	Backup HealthChecker
+	Active func() bool
+}
+
+// Covers reports whether the service is riding out a failure of the dependency.
+func (f Fallback) Covers(ctx context.Context) bool {
+	return f.Active() && f.Backup.HealthCheck(ctx) == nil
+}
@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrPendingChangeExists is returned when a policy already has a pending change request.
+var ErrPendingChangeExists = errors.New("policy already has a pending change request")
+
+type ApprovalRepository interface {
+	GetApprovers(ctx context.Context, ownerID string) ([]string, error)
+	SetApprovers(ctx context.Context, ownerID string, approverIDs []string) error
+	ListOwnersForApprover(ctx context.Context, approverID string) ([]string, error)
+	CreateChangeRequest(ctx context.Context, cr *ChangeRequest) error
+	GetChangeRequest(ctx context.Context, id primitive.ObjectID) (*ChangeRequest, error)
+	ListChangeRequests(ctx context.Context, ownerIDs []string, status string) ([]*ChangeRequest, error)
+	DecideChangeRequest(ctx context.Context, id primitive.ObjectID, status, decidedBy string, comment *ChangeComment) (*ChangeRequest, error)
+	AddChangeComment(ctx context.Context, id primitive.ObjectID, comment ChangeComment) (*ChangeRequest, error)
+}
+
+// MongoApprovalRepository stores each account's approvers, keyed by the owner's user ID,
+// alongside the change requests waiting on them.
+type MongoApprovalRepository struct {
+	approvers      *mongo.Collection
+	changeRequests *mongo.Collection
+}
+
+func NewMongoApprovalRepository(db *mongo.Database) *MongoApprovalRepository {
+	return &MongoApprovalRepository{
+		approvers:      db.Collection("approvers"),
+		changeRequests: db.Collection("change_requests"),
+	}
+}
+
+// EnsureIndexes creates the indexes that approval queries rely on, including the one that
+// allows at most one pending change request per policy.
+func (r *MongoApprovalRepository) EnsureIndexes(ctx context.Context) error {
+	if _, err := r.approvers.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "approver_ids", Value: 1}},
+		Options: options.Index().SetName("approver_ids"),
+	}); err != nil {
+		return err
+	}
+
+	_, err := r.changeRequests.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "owner_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("owner_status_created"),
+		},
+		{
+			Keys: bson.D{{Key: "policy_id", Value: 1}},
+			Options: options.Index().
+				SetName("policy_pending").
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": ChangeRequestPending}),
+		},
+	})
+	return err
+}
+
+type approversDoc struct {
+	OwnerID     string    `bson:"_id"`
+	ApproverIDs []string  `bson:"approver_ids"`
+	UpdatedAt   time.Time `bson:"updated_at"`
+}
+
+// GetApprovers returns the users allowed to approve changes to ownerID's policies. An empty
+// list means the account has not opted in to approvals.
+func (r *MongoApprovalRepository) GetApprovers(ctx context.Context, ownerID string) ([]string, error) {
+	var doc approversDoc
+	err := r.approvers.FindOne(ctx, bson.M{"_id": ownerID}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if doc.ApproverIDs == nil {
+		return []string{}, nil
+	}
+	return doc.ApproverIDs, nil
+}
+
+// SetApprovers replaces ownerID's approvers.
+func (r *MongoApprovalRepository) SetApprovers(ctx context.Context, ownerID string, approverIDs []string) error {
+	update := bson.M{"$set": bson.M{"approver_ids": approverIDs, "updated_at": time.Now().UTC()}}
+	_, err := r.approvers.UpdateOne(ctx, bson.M{"_id": ownerID}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// ListOwnersForApprover returns the accounts that list approverID as an approver.
+func (r *MongoApprovalRepository) ListOwnersForApprover(ctx context.Context, approverID string) ([]string, error) {
+	cur, err := r.approvers.Find(ctx, bson.M{"approver_ids": approverID}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer func(cur *mongo.Cursor, ctx context.Context) {
+		if err := cur.Close(ctx); err != nil {
+			log.Printf("failed to close cursor: %v\n", err)
+		}
+	}(cur, ctx)
+
+	var docs []approversDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	owners := make([]string, 0, len(docs))
+	for _, d := range docs {
+		owners = append(owners, d.OwnerID)
+	}
+	return owners, nil
+}
+
+// CreateChangeRequest stores a new change request, returning ErrPendingChangeExists if the
+// policy already has one pending.
+func (r *MongoApprovalRepository) CreateChangeRequest(ctx context.Context, cr *ChangeRequest) error {
+	res, err := r.changeRequests.InsertOne(ctx, cr)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrPendingChangeExists
+	}
+	if err != nil {
+		return err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		cr.ID = oid
+	}
+	return nil
+}
+
+func (r *MongoApprovalRepository) GetChangeRequest(ctx context.Context, id primitive.ObjectID) (*ChangeRequest, error) {
+	var cr ChangeRequest
+	err := r.changeRequests.FindOne(ctx, bson.M{"_id": id}).Decode(&cr)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cr, nil
+}
+
+// ListChangeRequests lists the change requests on the given owners' policies, newest first.
+// An empty status matches every status.
+func (r *MongoApprovalRepository) ListChangeRequests(ctx context.Context, ownerIDs []string, status string) ([]*ChangeRequest, error) {
+	filter := bson.M{"owner_id": bson.M{"$in": ownerIDs}}
+	if status != "" {
+		filter["status"] = status
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cur, err := r.changeRequests.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func(cur *mongo.Cursor, ctx context.Context) {
+		if err := cur.Close(ctx); err != nil {
+			log.Printf("failed to close cursor: %v\n", err)
+		}
+	}(cur, ctx)
+
+	changeRequests := make([]*ChangeRequest, 0)
+	if err := cur.All(ctx, &changeRequests); err != nil {
+		return nil, err
+	}
+	return changeRequests, nil
+}
+
+// DecideChangeRequest moves a pending change request to status, recording who decided and,
+// if comment is non-nil, their comment. It returns nil if the request is no longer pending.
+func (r *MongoApprovalRepository) DecideChangeRequest(ctx context.Context, id primitive.ObjectID, status, decidedBy string, comment *ChangeComment) (*ChangeRequest, error) {
+	filter := bson.M{"_id": id, "status": ChangeRequestPending}
+	update := bson.M{"$set": bson.M{"status": status, "decided_by": decidedBy, "decided_at": time.Now().UTC()}}
+	if comment != nil {
+		update["$push"] = bson.M{"comments": comment}
+	}
+	return r.findOneAndUpdate(ctx, filter, update)
+}
+
+// AddChangeComment appends a comment to a change request, returning nil if it does not exist.
+func (r *MongoApprovalRepository) AddChangeComment(ctx context.Context, id primitive.ObjectID, comment ChangeComment) (*ChangeRequest, error) {
+	return r.findOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$push": bson.M{"comments": comment}})
+}
+
+func (r *MongoApprovalRepository) findOneAndUpdate(ctx context.Context, filter bson.M, update bson.M) (*ChangeRequest, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var cr ChangeRequest
+	if err := r.changeRequests.FindOneAndUpdate(ctx, filter, update, opts).Decode(&cr); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &cr, nil
+}
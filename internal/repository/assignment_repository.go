@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrAssignmentExists is returned when an entity already has a policy assigned.
+var ErrAssignmentExists = errors.New("entity already has a policy assigned")
+
+type AssignmentRepository interface {
+	CreateAssignment(ctx context.Context, a *PolicyAssignment) error
+	GetAssignment(ctx context.Context, userID uuid.UUID, id primitive.ObjectID) (*PolicyAssignment, error)
+	ListAssignments(ctx context.Context, userID uuid.UUID, filter AssignmentFilter) ([]*PolicyAssignment, error)
+	FindAssignments(ctx context.Context, userID uuid.UUID, marketplace string, entities []AssignmentEntity) ([]*PolicyAssignment, error)
+	UpdateAssignmentPolicy(ctx context.Context, userID uuid.UUID, id, policyID primitive.ObjectID) (*PolicyAssignment, error)
+	DeleteAssignment(ctx context.Context, userID uuid.UUID, id primitive.ObjectID) (bool, error)
+	CountPolicyAssignments(ctx context.Context, userID uuid.UUID, policyID primitive.ObjectID) (int64, error)
+}
+
+type MongoAssignmentRepository struct {
+	coll *mongo.Collection
+}
+
+func NewMongoAssignmentRepository(db *mongo.Database) *MongoAssignmentRepository {
+	return &MongoAssignmentRepository{coll: db.Collection("policy_assignments")}
+}
+
+// EnsureIndexes creates the indexes that assignment lookups rely on, including the one that
+// allows a single assignment per entity.
+func (r *MongoAssignmentRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1},
+				{Key: "marketplace", Value: 1},
+				{Key: "entity_type", Value: 1},
+				{Key: "entity_id", Value: 1},
+			},
+			Options: options.Index().SetName("user_entity").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "policy_id", Value: 1}},
+			Options: options.Index().SetName("user_policy"),
+		},
+	})
+	return err
+}
+
+// CreateAssignment stores a new assignment, returning ErrAssignmentExists if the entity is already assigned.
+func (r *MongoAssignmentRepository) CreateAssignment(ctx context.Context, a *PolicyAssignment) error {
+	res, err := r.coll.InsertOne(ctx, a)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAssignmentExists
+	}
+	if err != nil {
+		return err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		a.ID = oid
+	}
+	return nil
+}
+
+func (r *MongoAssignmentRepository) GetAssignment(ctx context.Context, userID uuid.UUID, id primitive.ObjectID) (*PolicyAssignment, error) {
+	var a PolicyAssignment
+	err := r.coll.FindOne(ctx, bson.M{"_id": id, "user_id": userID.String()}).Decode(&a)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ListAssignments lists a user's assignments matching every non-empty field of filter.
+func (r *MongoAssignmentRepository) ListAssignments(ctx context.Context, userID uuid.UUID, filter AssignmentFilter) ([]*PolicyAssignment, error) {
+	query := bson.M{"user_id": userID.String()}
+	if filter.Marketplace != "" {
+		query["marketplace"] = filter.Marketplace
+	}
+	if filter.EntityType != "" {
+		query["entity_type"] = filter.EntityType
+	}
+	if filter.PolicyID != nil {
+		query["policy_id"] = *filter.PolicyID
+	}
+	return r.find(ctx, query)
+}
+
+// FindAssignments returns the assignments of any of the given entities in a marketplace.
+func (r *MongoAssignmentRepository) FindAssignments(ctx context.Context, userID uuid.UUID, marketplace string, entities []AssignmentEntity) ([]*PolicyAssignment, error) {
+	if len(entities) == 0 {
+		return []*PolicyAssignment{}, nil
+	}
+
+	or := make(bson.A, 0, len(entities))
+	for _, entity := range entities {
+		or = append(or, bson.M{"entity_type": entity.Type, "entity_id": entity.ID})
+	}
+	return r.find(ctx, bson.M{"user_id": userID.String(), "marketplace": marketplace, "$or": or})
+}
+
+// UpdateAssignmentPolicy points an assignment at another policy, returning nil if it does not exist.
+func (r *MongoAssignmentRepository) UpdateAssignmentPolicy(ctx context.Context, userID uuid.UUID, id, policyID primitive.ObjectID) (*PolicyAssignment, error) {
+	filter := bson.M{"_id": id, "user_id": userID.String()}
+	update := bson.M{"$set": bson.M{"policy_id": policyID, "updated_at": time.Now().UTC()}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var a PolicyAssignment
+	if err := r.coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&a); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *MongoAssignmentRepository) DeleteAssignment(ctx context.Context, userID uuid.UUID, id primitive.ObjectID) (bool, error) {
+	res, err := r.coll.DeleteOne(ctx, bson.M{"_id": id, "user_id": userID.String()})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
+
+// CountPolicyAssignments counts the entities a policy is assigned to.
+func (r *MongoAssignmentRepository) CountPolicyAssignments(ctx context.Context, userID uuid.UUID, policyID primitive.ObjectID) (int64, error) {
+	return r.coll.CountDocuments(ctx, bson.M{"user_id": userID.String(), "policy_id": policyID})
+}
+
+func (r *MongoAssignmentRepository) find(ctx context.Context, filter bson.M) ([]*PolicyAssignment, error) {
+	cur, err := r.coll.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer func(cur *mongo.Cursor, ctx context.Context) {
+		if err := cur.Close(ctx); err != nil {
+			log.Printf("failed to close cursor: %v\n", err)
+		}
+	}(cur, ctx)
+
+	assignments := make([]*PolicyAssignment, 0)
+	if err := cur.All(ctx, &assignments); err != nil {
+		return nil, err
+	}
+	return assignments, nil
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/db"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BreakerAssignmentRepository guards an AssignmentRepository with the same circuit breaker as
+// the policies, so that assignment lookups also fail fast while the database is unreachable.
+type BreakerAssignmentRepository struct {
+	repo    AssignmentRepository
+	breaker *db.Breaker
+}
+
+func NewBreakerAssignmentRepository(repo AssignmentRepository, breaker *db.Breaker) *BreakerAssignmentRepository {
+	return &BreakerAssignmentRepository{repo: repo, breaker: breaker}
+}
+
+func (r *BreakerAssignmentRepository) CreateAssignment(ctx context.Context, a *PolicyAssignment) error {
+	return r.breaker.Do(func() error { return r.repo.CreateAssignment(ctx, a) })
+}
+
+func (r *BreakerAssignmentRepository) GetAssignment(ctx context.Context, userID uuid.UUID, id primitive.ObjectID) (*PolicyAssignment, error) {
+	return guard(r.breaker, func() (*PolicyAssignment, error) { return r.repo.GetAssignment(ctx, userID, id) })
+}
+
+func (r *BreakerAssignmentRepository) ListAssignments(ctx context.Context, userID uuid.UUID, filter AssignmentFilter) ([]*PolicyAssignment, error) {
+	return guard(r.breaker, func() ([]*PolicyAssignment, error) { return r.repo.ListAssignments(ctx, userID, filter) })
+}
+
+func (r *BreakerAssignmentRepository) FindAssignments(ctx context.Context, userID uuid.UUID, marketplace string, entities []AssignmentEntity) ([]*PolicyAssignment, error) {
+	return guard(r.breaker, func() ([]*PolicyAssignment, error) { return r.repo.FindAssignments(ctx, userID, marketplace, entities) })
+}
+
+func (r *BreakerAssignmentRepository) UpdateAssignmentPolicy(ctx context.Context, userID uuid.UUID, id, policyID primitive.ObjectID) (*PolicyAssignment, error) {
+	return guard(r.breaker, func() (*PolicyAssignment, error) { return r.repo.UpdateAssignmentPolicy(ctx, userID, id, policyID) })
+}
+
+func (r *BreakerAssignmentRepository) DeleteAssignment(ctx context.Context, userID uuid.UUID, id primitive.ObjectID) (bool, error) {
+	return guard(r.breaker, func() (bool, error) { return r.repo.DeleteAssignment(ctx, userID, id) })
+}
+
+func (r *BreakerAssignmentRepository) CountPolicyAssignments(ctx context.Context, userID uuid.UUID, policyID primitive.ObjectID) (int64, error) {
+	return guard(r.breaker, func() (int64, error) { return r.repo.CountPolicyAssignments(ctx, userID, policyID) })
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	ChangeInsert  = "insert"
+	ChangeUpdate  = "update"
+	ChangeReplace = "replace"
+	ChangeDelete  = "delete"
+)
+
+// ErrResumeTokenLost is returned when a change stream can no longer be resumed from the
+// saved token, typically because the oplog has moved past it.
+var ErrResumeTokenLost = errors.New("change stream cannot be resumed from token")
+
+// resumeTokenLostCodes are the server errors for a token that cannot be resumed from:
+// ChangeStreamFatalError, InvalidResumeToken and ChangeStreamHistoryLost.
+var resumeTokenLostCodes = []int{280, 260, 286}
+
+// PolicyChange is a write to the policies collection seen on its change stream. Policy is the
+// policy after the change, or before it for deletes. Previous is the policy before the change.
+// Both come from images the collection only records with pre-images enabled, except Policy on
+// inserts, updates and replaces, where it is nil only if the policy was purged before the
+// change could be read back. ID identifies the change, and is the same on every stream that
+// sees it. Transactional is set for changes made in a transaction, which is how the service
+// makes every change along with its event, so a change without it was made outside the service
+// and has no event.
+type PolicyChange struct {
+	ID            string
+	Operation     string
+	PolicyID      primitive.ObjectID
+	Policy        *Policy
+	Previous      *Policy
+	At            time.Time
+	Transactional bool
+}
+
+type ChangeStreamRepository interface {
+	WatchPolicies(ctx context.Context, resumeToken bson.Raw, fn func(change *PolicyChange, token bson.Raw) error) error
+	GetResumeToken(ctx context.Context, name string) (bson.Raw, error)
+	SaveResumeToken(ctx context.Context, name, owner string, token bson.Raw, lease time.Duration) (bool, error)
+	ClearResumeToken(ctx context.Context, name string) error
+}
+
+type MongoChangeStreamRepository struct {
+	policies *mongo.Collection
+	tokens   *mongo.Collection
+}
+
+func NewMongoChangeStreamRepository(db *mongo.Database) *MongoChangeStreamRepository {
+	return &MongoChangeStreamRepository{
+		policies: db.Collection("policies"),
+		tokens:   db.Collection("resume_tokens"),
+	}
+}
+
+// EnablePreImages makes the policies collection record documents as they were before each
+// change, so that purged policies can be identified. It needs MongoDB 6.0 or later.
+func (r *MongoChangeStreamRepository) EnablePreImages(ctx context.Context) error {
+	return r.policies.Database().RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: r.policies.Name()},
+		{Key: "changeStreamPreAndPostImages", Value: bson.M{"enabled": true}},
+	}).Err()
+}
+
+// WatchPolicies calls fn with every change to the policies collection after resumeToken, or
+// from now if it is nil, along with the token to resume after that change. It blocks until
+// ctx is cancelled or the stream fails, returning ErrResumeTokenLost if resumeToken is too old.
+func (r *MongoChangeStreamRepository) WatchPolicies(ctx context.Context, resumeToken bson.Raw, fn func(change *PolicyChange, token bson.Raw) error) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"operationType": bson.M{"$in": bson.A{ChangeInsert, ChangeUpdate, ChangeReplace, ChangeDelete}}}}},
+	}
+	opts := options.ChangeStream().
+		SetFullDocument(options.UpdateLookup).
+		SetFullDocumentBeforeChange(options.WhenAvailable)
+	if resumeToken != nil {
+		opts.SetStartAfter(resumeToken)
+	}
+
+	stream, err := r.policies.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return resumeError(err)
+	}
+	defer stream.Close(context.WithoutCancel(ctx))
+
+	for stream.Next(ctx) {
+		var event struct {
+			ID struct {
+				Data string `bson:"_data"`
+			} `bson:"_id"`
+			OperationType string `bson:"operationType"`
+			DocumentKey   struct {
+				ID primitive.ObjectID `bson:"_id"`
+			} `bson:"documentKey"`
+			FullDocument             *policyDoc          `bson:"fullDocument"`
+			FullDocumentBeforeChange *policyDoc          `bson:"fullDocumentBeforeChange"`
+			ClusterTime              primitive.Timestamp `bson:"clusterTime"`
+			TxnNumber                *int64              `bson:"txnNumber"`
+		}
+		if err := stream.Decode(&event); err != nil {
+			return err
+		}
+
+		change := &PolicyChange{
+			ID:            event.ID.Data,
+			Operation:     event.OperationType,
+			PolicyID:      event.DocumentKey.ID,
+			At:            time.Unix(int64(event.ClusterTime.T), 0).UTC(),
+			Transactional: event.TxnNumber != nil,
+		}
+		if event.FullDocument != nil {
+			change.Policy = event.FullDocument.toPolicy()
+		}
+		if event.FullDocumentBeforeChange != nil {
+			change.Previous = event.FullDocumentBeforeChange.toPolicy()
+		}
+		if change.Operation == ChangeDelete {
+			change.Policy = change.Previous
+		}
+		if err := fn(change, stream.ResumeToken()); err != nil {
+			return err
+		}
+	}
+	return resumeError(stream.Err())
+}
+
+func resumeError(err error) error {
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		for _, code := range resumeTokenLostCodes {
+			if serverErr.HasErrorCode(code) {
+				return errors.Join(ErrResumeTokenLost, err)
+			}
+		}
+	}
+	return err
+}
+
+// GetResumeToken returns the token saved under name, or nil if there is none.
+func (r *MongoChangeStreamRepository) GetResumeToken(ctx context.Context, name string) (bson.Raw, error) {
+	var doc struct {
+		Token bson.Raw `bson:"token"`
+	}
+	err := r.tokens.FindOne(ctx, bson.M{"_id": name}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.Token, nil
+}
+
+// SaveResumeToken saves token under name, replacing the previous one, if owner holds the
+// token's lease or it has lapsed, and renews the lease for owner. It reports whether it saved,
+// so that one of several instances following the same stream writes the token at a time.
+func (r *MongoChangeStreamRepository) SaveResumeToken(ctx context.Context, name, owner string, token bson.Raw, lease time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	filter := bson.M{
+		"_id": name,
+		"$or": bson.A{
+			bson.M{"owner": owner},
+			bson.M{"lease_until": bson.M{"$not": bson.M{"$gt": now}}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"token": token, "owner": owner, "lease_until": now.Add(lease), "updated_at": now}}
+	_, err := r.tokens.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// Another instance holds the lease, so the upsert tried to insert a second token
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ClearResumeToken deletes the token saved under name, along with its lease.
+func (r *MongoChangeStreamRepository) ClearResumeToken(ctx context.Context, name string) error {
+	_, err := r.tokens.DeleteOne(ctx, bson.M{"_id": name})
+	return err
+}
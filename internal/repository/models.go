@@ -1,13 +1,92 @@
 package repository
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"fmt"
+	"slices"
+	"time"
+	_ "time/tzdata" // marketplace time zones must resolve even where the host has no zoneinfo
 
+	"github.com/LittleAksMax/bids-policy-service/internal/convert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Policy is a user's bidding script for a marketplace.
+// Script is the published script the bidder runs, while DraftScript holds work in progress
+// that only replaces it when the policy is published.
+// Only active policies are picked up by the bidder. Policies stored before statuses existed
+// have no status and are read back as active.
+// DeletedAt is set while the policy is in the trash; it is stored as null otherwise.
+// EffectiveFrom and EffectiveUntil bound the window in which the policy is active: the scheduler
+// activates it at EffectiveFrom and pauses it at EffectiveUntil, clearing each once acted on.
 type Policy struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
 	UserID      string             `bson:"user_id" json:"user_id"`
 	Marketplace string             `bson:"marketplace" json:"marketplace"`
 	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description" json:"description"`
 	Script      string             `bson:"script" json:"script"`
+	DraftScript *string            `bson:"draft_script" json:"draft_script,omitempty"`
+	Status      string             `bson:"status" json:"status"`
+	Tags        []string           `bson:"tags" json:"tags"`
+	Summary     *PolicySummary     `bson:"summary,omitempty" json:"summary,omitempty"`
+	DeletedAt   *time.Time         `bson:"deleted_at" json:"deleted_at,omitempty"`
+
+	EffectiveFrom  *time.Time `bson:"effective_from,omitempty" json:"effective_from,omitempty"`
+	EffectiveUntil *time.Time `bson:"effective_until,omitempty" json:"effective_until,omitempty"`
+
+	Dayparts []Daypart `bson:"dayparts,omitempty" json:"dayparts,omitempty"`
+
+	// IsDefault marks the policy the bidder falls back to for entities in the marketplace
+	// without an assignment. A user has at most one default per marketplace.
+	IsDefault bool `bson:"is_default" json:"is_default"`
+}
+
+// Copy returns a deep copy of the policy, sharing no slices or pointers with it, so that
+// callers can change the copy without affecting other holders of the policy.
+func (p *Policy) Copy() *Policy {
+	c := *p
+	c.DraftScript = copyPtr(p.DraftScript)
+	c.Tags = slices.Clone(p.Tags)
+	c.DeletedAt = copyPtr(p.DeletedAt)
+	c.EffectiveFrom = copyPtr(p.EffectiveFrom)
+	c.EffectiveUntil = copyPtr(p.EffectiveUntil)
+	if p.Summary != nil {
+		summary := *p.Summary
+		summary.Metrics = slices.Clone(p.Summary.Metrics)
+		summary.Operators = slices.Clone(p.Summary.Operators)
+		summary.MinBidChange = copyPtr(p.Summary.MinBidChange)
+		summary.MaxBidChange = copyPtr(p.Summary.MaxBidChange)
+		summary.MinPercentBidChange = copyPtr(p.Summary.MinPercentBidChange)
+		summary.MaxPercentBidChange = copyPtr(p.Summary.MaxPercentBidChange)
+		c.Summary = &summary
+	}
+	if p.Dayparts != nil {
+		c.Dayparts = make([]Daypart, len(p.Dayparts))
+		for i, daypart := range p.Dayparts {
+			daypart.Days = slices.Clone(daypart.Days)
+			c.Dayparts[i] = daypart
+		}
+	}
+	return &c
+}
+
+func copyPtr[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+// Daypart runs Script in place of the policy's script on Days from Start until End, both
+// "15:04" in the marketplace's local time. An End at or before Start runs past midnight into
+// the next day. Days are lower-case three-letter names such as "mon".
+type Daypart struct {
+	Name   string   `bson:"name" json:"name"`
+	Days   []string `bson:"days" json:"days"`
+	Start  string   `bson:"start" json:"start"`
+	End    string   `bson:"end" json:"end"`
+	Script string   `bson:"script" json:"script"`
 }
 
 const (
@@ -36,6 +115,58 @@ const (
 	MpSG = "SG"
 )
 
+const (
+	StatusDraft    = "draft"
+	StatusActive   = "active"
+	StatusPaused   = "paused"
+	StatusArchived = "archived"
+)
+
+func IsValidStatus(status string) bool {
+	switch status {
+	case StatusDraft, StatusActive, StatusPaused, StatusArchived:
+		return true
+	default:
+		return false
+	}
+}
+
+// PolicySummary is a denormalized description of a policy's script and the scripts of its
+// dayparts, kept in step with them so that policies can be queried by the metrics and
+// operators they use at any time of day.
+type PolicySummary struct {
+	Metrics      []string `bson:"metrics" json:"metrics"`
+	MaxDepth     int      `bson:"max_depth" json:"max_depth"`
+	BranchCount  int      `bson:"branch_count" json:"branch_count"`
+	Operators    []string `bson:"operators" json:"operators"`
+	MinBidChange *float64 `bson:"min_bid_change" json:"min_bid_change"`
+	MaxBidChange *float64 `bson:"max_bid_change" json:"max_bid_change"`
+	// The percentage bounds are kept apart from the absolute ones above
+	MinPercentBidChange *float64 `bson:"min_percent_bid_change" json:"min_percent_bid_change"`
+	MaxPercentBidChange *float64 `bson:"max_percent_bid_change" json:"max_percent_bid_change"`
+	// DaypartCount is how many daypart scripts are summarized along with the script
+	DaypartCount int `bson:"daypart_count" json:"daypart_count"`
+}
+
+// PolicyFilter narrows a policy listing. Empty fields do not filter.
+// With MatchAllTags a policy must carry every tag in Tags, otherwise any one of them.
+// A policy must reference every metric in Metrics and use every operator in Operators,
+// and have one of Statuses.
+type PolicyFilter struct {
+	Marketplace  string
+	Tags         []string
+	MatchAllTags bool
+	Metrics      []string
+	Operators    []string
+	Statuses     []string
+}
+
+// PolicySearchResult is a full-text search match with its relevance score.
+type PolicySearchResult struct {
+	Policy *Policy `json:"policy"`
+	Score  float64 `json:"score"`
+}
+
 func IsValidMarketplace(marketplace string) bool {
 	switch marketplace {
 	case MpUK, MpDE, MpFR, MpIT, MpES, MpUS, MpCA, MpMX,
@@ -45,3 +176,278 @@ func IsValidMarketplace(marketplace string) bool {
 		return false
 	}
 }
+
+// marketplaceTimeZones holds the time zone each marketplace reports and runs its day in.
+var marketplaceTimeZones = map[string]string{
+	MpUK: "Europe/London",
+	MpDE: "Europe/Berlin",
+	MpFR: "Europe/Paris",
+	MpIT: "Europe/Rome",
+	MpES: "Europe/Madrid",
+	MpUS: "America/Los_Angeles",
+	MpCA: "America/Los_Angeles",
+	MpMX: "America/Los_Angeles",
+	MpBR: "America/Sao_Paulo",
+	MpAE: "Asia/Dubai",
+	MpBE: "Europe/Brussels",
+	MpEG: "Africa/Cairo",
+	MpIE: "Europe/Dublin",
+	MpIN: "Asia/Kolkata",
+	MpNL: "Europe/Amsterdam",
+	MpPL: "Europe/Warsaw",
+	MpSA: "Asia/Riyadh",
+	MpSE: "Europe/Stockholm",
+	MpTR: "Europe/Istanbul",
+	MpZA: "Africa/Johannesburg",
+	MpAU: "Australia/Sydney",
+	MpJP: "Asia/Tokyo",
+	MpSG: "Asia/Singapore",
+}
+
+// marketplaceCurrencies holds the ISO 4217 currency each marketplace bids and reports in.
+var marketplaceCurrencies = map[string]string{
+	MpUK: "GBP",
+	MpDE: "EUR",
+	MpFR: "EUR",
+	MpIT: "EUR",
+	MpES: "EUR",
+	MpUS: "USD",
+	MpCA: "CAD",
+	MpMX: "MXN",
+	MpBR: "BRL",
+	MpAE: "AED",
+	MpBE: "EUR",
+	MpEG: "EGP",
+	MpIE: "EUR",
+	MpIN: "INR",
+	MpNL: "EUR",
+	MpPL: "PLN",
+	MpSA: "SAR",
+	MpSE: "SEK",
+	MpTR: "TRY",
+	MpZA: "ZAR",
+	MpAU: "AUD",
+	MpJP: "JPY",
+	MpSG: "SGD",
+}
+
+// MarketplaceCurrency returns the currency of a marketplace, or "" for an unknown one.
+func MarketplaceCurrency(marketplace string) string {
+	return marketplaceCurrencies[marketplace]
+}
+
+// MarketplaceLocation returns the time zone of a marketplace, or UTC for an unknown one.
+func MarketplaceLocation(marketplace string) *time.Location {
+	name, ok := marketplaceTimeZones[marketplace]
+	if !ok {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// marketplaceTimeLayouts are the local wall-clock layouts accepted by ParseMarketplaceTime.
+var marketplaceTimeLayouts = []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// ParseMarketplaceTime parses an RFC 3339 timestamp, or a wall-clock time without an offset,
+// which is read in the marketplace's time zone so that "2026-11-27" means midnight there.
+func ParseMarketplaceTime(marketplace, value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	loc := MarketplaceLocation(marketplace)
+	for _, layout := range marketplaceTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: expected RFC 3339 or a local time such as 2006-01-02T15:04", value)
+}
+
+const (
+	ChangeRequestPending  = "pending"
+	ChangeRequestApproved = "approved"
+	ChangeRequestRejected = "rejected"
+)
+
+// ChangeRequest is a proposed change to the published script of a live policy that waits
+// for a second person to approve it. BaseScript is the published script the change was made
+// against, and the request can only be applied while the policy still has that script.
+// A request with ProposedDayparts changes the policy's dayparts instead, leaving the script
+// as it is, and can only be applied while the policy still has BaseDayparts.
+type ChangeRequest struct {
+	ID             primitive.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`
+	PolicyID       primitive.ObjectID   `bson:"policy_id" json:"policy_id"`
+	OwnerID        string               `bson:"owner_id" json:"owner_id"`
+	RequestedBy    string               `bson:"requested_by" json:"requested_by"`
+	BaseScript     string               `bson:"base_script" json:"base_script"`
+	ProposedScript string               `bson:"proposed_script" json:"proposed_script"`
+	Diff           []convert.TreeChange `bson:"diff" json:"diff"`
+	Status         string               `bson:"status" json:"status"`
+	Comments       []ChangeComment      `bson:"comments" json:"comments"`
+	DecidedBy      string               `bson:"decided_by,omitempty" json:"decided_by,omitempty"`
+	DecidedAt      *time.Time           `bson:"decided_at,omitempty" json:"decided_at,omitempty"`
+	CreatedAt      time.Time            `bson:"created_at" json:"created_at"`
+
+	// EffectiveFrom and EffectiveUntil are set when the change was scheduled rather than
+	// published; once approved it becomes a ScheduledChange with the same window.
+	EffectiveFrom  *time.Time `bson:"effective_from,omitempty" json:"effective_from,omitempty"`
+	EffectiveUntil *time.Time `bson:"effective_until,omitempty" json:"effective_until,omitempty"`
+
+	BaseDayparts     []Daypart  `bson:"base_dayparts,omitempty" json:"base_dayparts,omitempty"`
+	ProposedDayparts *[]Daypart `bson:"proposed_dayparts,omitempty" json:"proposed_dayparts,omitempty"`
+}
+
+const (
+	ScheduledChangeScheduled = "scheduled"
+	ScheduledChangeApplied   = "applied"
+	ScheduledChangeReverted  = "reverted"
+	ScheduledChangeCancelled = "cancelled"
+	ScheduledChangeFailed    = "failed"
+)
+
+// ScheduledChange replaces a policy's published script with Script at EffectiveFrom and, if
+// EffectiveUntil is set, puts PreviousScript back at EffectiveUntil. PreviousScript is the
+// script that was published when the change was applied.
+type ScheduledChange struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	PolicyID       primitive.ObjectID `bson:"policy_id" json:"policy_id"`
+	OwnerID        string             `bson:"owner_id" json:"owner_id"`
+	Script         string             `bson:"script" json:"script"`
+	PreviousScript *string            `bson:"previous_script,omitempty" json:"previous_script,omitempty"`
+	EffectiveFrom  time.Time          `bson:"effective_from" json:"effective_from"`
+	EffectiveUntil *time.Time         `bson:"effective_until,omitempty" json:"effective_until,omitempty"`
+	Status         string             `bson:"status" json:"status"`
+	Error          string             `bson:"error,omitempty" json:"error,omitempty"`
+	AppliedAt      *time.Time         `bson:"applied_at,omitempty" json:"applied_at,omitempty"`
+	RevertedAt     *time.Time         `bson:"reverted_at,omitempty" json:"reverted_at,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// ChangeComment is a note left on a change request by its owner or one of their approvers.
+type ChangeComment struct {
+	AuthorID  string    `bson:"author_id" json:"author_id"`
+	Body      string    `bson:"body" json:"body"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// Entities a policy can be assigned to. Targets cover keywords and product targets alike.
+const (
+	EntityCampaign = "campaign"
+	EntityAdGroup  = "ad_group"
+	EntityTarget   = "target"
+)
+
+func IsValidEntityType(entityType string) bool {
+	switch entityType {
+	case EntityCampaign, EntityAdGroup, EntityTarget:
+		return true
+	default:
+		return false
+	}
+}
+
+// PolicyAssignment links an advertising entity in a marketplace to the policy that bids on it.
+// Each entity has at most one assignment per user.
+type PolicyAssignment struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID      string             `bson:"user_id" json:"user_id"`
+	Marketplace string             `bson:"marketplace" json:"marketplace"`
+	EntityType  string             `bson:"entity_type" json:"entity_type"`
+	EntityID    string             `bson:"entity_id" json:"entity_id"`
+	PolicyID    primitive.ObjectID `bson:"policy_id" json:"policy_id"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// AssignmentFilter narrows an assignment listing. Empty fields do not filter.
+type AssignmentFilter struct {
+	Marketplace string
+	EntityType  string
+	PolicyID    *primitive.ObjectID
+}
+
+// AssignmentEntity identifies an entity when looking up its assignment.
+type AssignmentEntity struct {
+	Type string
+	ID   string
+}
+
+// Types of policy events. Deleted means moved to the trash, and purged removed for good.
+const (
+	EventPolicyCreated = "policy.created"
+	EventPolicyUpdated = "policy.updated"
+	EventPolicyDeleted = "policy.deleted"
+	EventPolicyPurged  = "policy.purged"
+)
+
+// OutboxEvent is a change to a policy, recorded in the same transaction as the change itself.
+// Seq numbers events in the order their transactions committed. Policy is the policy after the
+// change, and nil for purges. DispatchedAt is set once the event was handed to webhooks.
+type OutboxEvent struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	Seq          int64              `bson:"seq" json:"seq"`
+	Type         string             `bson:"type" json:"type"`
+	PolicyID     primitive.ObjectID `bson:"policy_id" json:"policy_id"`
+	UserID       string             `bson:"user_id" json:"user_id"`
+	Marketplace  string             `bson:"marketplace" json:"marketplace"`
+	Policy       *Policy            `bson:"policy,omitempty" json:"policy,omitempty"`
+	OccurredAt   time.Time          `bson:"occurred_at" json:"occurred_at"`
+	DispatchedAt *time.Time         `bson:"dispatched_at" json:"-"`
+	// ChangeID identifies the change stream change that an event of a change made outside the
+	// service was recorded from, so that it is recorded once however many instances see it
+	ChangeID string `bson:"change_id,omitempty" json:"-"`
+}
+
+// WebhookSubscription delivers policy events to URL, signed with Secret. Only events of
+// EventTypes are delivered, or all of them if it is empty, and only events of UserID's
+// policies if it is set.
+type WebhookSubscription struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	URL        string             `bson:"url" json:"url"`
+	Secret     string             `bson:"secret" json:"secret,omitempty"`
+	EventTypes []string           `bson:"event_types" json:"event_types"`
+	UserID     string             `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Wants reports whether e should be delivered to the subscription.
+func (s *WebhookSubscription) Wants(e *OutboxEvent) bool {
+	if s.UserID != "" && s.UserID != e.UserID {
+		return false
+	}
+	return len(s.EventTypes) == 0 || slices.Contains(s.EventTypes, e.Type)
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is the delivery of one event to one subscription. Payload is the exact body
+// sent on every attempt. A pending delivery is next attempted at NextAttemptAt. FinishedAt is
+// set once the delivery succeeded or ran out of attempts.
+type WebhookDelivery struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	SubscriptionID primitive.ObjectID `bson:"subscription_id" json:"subscription_id"`
+	EventSeq       int64              `bson:"event_seq" json:"event_seq"`
+	EventType      string             `bson:"event_type" json:"event_type"`
+	Payload        []byte             `bson:"payload" json:"-"`
+	Status         string             `bson:"status" json:"status"`
+	Attempts       []DeliveryAttempt  `bson:"attempts" json:"attempts"`
+	NextAttemptAt  *time.Time         `bson:"next_attempt_at,omitempty" json:"next_attempt_at,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	FinishedAt     *time.Time         `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
+
+// DeliveryAttempt is one try at delivering a webhook. StatusCode is 0 if no response arrived.
+type DeliveryAttempt struct {
+	At         time.Time     `bson:"at" json:"at"`
+	StatusCode int           `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	Error      string        `bson:"error,omitempty" json:"error,omitempty"`
+	Duration   time.Duration `bson:"duration" json:"duration"`
+}
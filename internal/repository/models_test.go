@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseMarketplaceTime(t *testing.T) {
+	tests := []struct {
+		name        string
+		marketplace string
+		value       string
+		expected    time.Time
+	}{
+		{
+			name:        "local midnight in winter",
+			marketplace: MpUS,
+			value:       "2026-11-27",
+			expected:    time.Date(2026, 11, 27, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "local time in summer",
+			marketplace: MpDE,
+			value:       "2026-07-01T09:30",
+			expected:    time.Date(2026, 7, 1, 7, 30, 0, 0, time.UTC),
+		},
+		{
+			name:        "explicit offset wins",
+			marketplace: MpJP,
+			value:       "2026-11-27T00:00:00Z",
+			expected:    time.Date(2026, 11, 27, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "unknown marketplace is UTC",
+			marketplace: "XX",
+			value:       "2026-11-27T00:00:00",
+			expected:    time.Date(2026, 11, 27, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parsed, err := ParseMarketplaceTime(test.marketplace, test.value)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !parsed.Equal(test.expected) {
+				t.Fatalf("expected %s, got %s", test.expected, parsed)
+			}
+		})
+	}
+}
+
+func TestParseMarketplaceTimeRejectsGarbage(t *testing.T) {
+	if _, err := ParseMarketplaceTime(MpUK, "next friday"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestWebhookSubscriptionWants(t *testing.T) {
+	created := &OutboxEvent{Type: EventPolicyCreated, UserID: "a"}
+	purged := &OutboxEvent{Type: EventPolicyPurged, UserID: "b"}
+
+	all := &WebhookSubscription{}
+	if !all.Wants(created) || !all.Wants(purged) {
+		t.Fatal("expected a subscription without filters to want every event")
+	}
+
+	byType := &WebhookSubscription{EventTypes: []string{EventPolicyCreated}}
+	if !byType.Wants(created) || byType.Wants(purged) {
+		t.Fatal("expected a subscription to want only its event types")
+	}
+
+	byUser := &WebhookSubscription{UserID: "b"}
+	if byUser.Wants(created) || !byUser.Wants(purged) {
+		t.Fatal("expected a subscription to want only its user's events")
+	}
+}
+
+func TestPolicyCopySharesNothing(t *testing.T) {
+	draft := "=1.00"
+	change := 0.5
+	p := &Policy{
+		DraftScript: &draft,
+		Tags:        []string{"a"},
+		Summary:     &PolicySummary{Metrics: []string{"cpc"}, MaxBidChange: &change},
+		Dayparts:    []Daypart{{Name: "night", Days: []string{"mon"}}},
+	}
+
+	c := p.Copy()
+	*c.DraftScript = "=2.00"
+	c.Tags[0] = "b"
+	c.Summary.Metrics[0] = "clicks"
+	*c.Summary.MaxBidChange = 1
+	c.Dayparts[0].Days[0] = "tue"
+
+	if *p.DraftScript != "=1.00" || p.Tags[0] != "a" || p.Summary.Metrics[0] != "cpc" || *p.Summary.MaxBidChange != 0.5 || p.Dayparts[0].Days[0] != "mon" {
+		t.Fatalf("expected changes to the copy to leave the policy alone, got %+v", p)
+	}
+}
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// policyEventsCounter names the counter that policy event sequence numbers are drawn from.
+const policyEventsCounter = "policy_events"
+
+// indexOptionsConflict is the server error for creating an index that exists with other options.
+const indexOptionsConflict = 85
+
+// changeIndex is the unique index on the change stream changes that events were recorded from.
+const changeIndex = "change_id"
+
+// OutboxRepository reads the policy events that MongoPolicyRepository records alongside
+// every change it makes.
+type OutboxRepository interface {
+	ListEventsAfter(ctx context.Context, afterSeq int64, userID string, limit int) ([]*OutboxEvent, error)
+	LastEventSeq(ctx context.Context) (int64, error)
+	FirstEventSeq(ctx context.Context) (int64, error)
+	ListUndispatchedEvents(ctx context.Context, limit int) ([]*OutboxEvent, error)
+	MarkEventDispatched(ctx context.Context, id primitive.ObjectID, at time.Time) error
+}
+
+type MongoOutboxRepository struct {
+	coll     *mongo.Collection
+	counters *mongo.Collection
+}
+
+func NewMongoOutboxRepository(db *mongo.Database) *MongoOutboxRepository {
+	return &MongoOutboxRepository{coll: db.Collection("policy_events"), counters: db.Collection("counters")}
+}
+
+// EnsureIndexes creates the indexes that event readers and the dispatcher rely on.
+func (r *MongoOutboxRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "seq", Value: 1}},
+			Options: options.Index().SetName("seq").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "seq", Value: 1}},
+			Options: options.Index().SetName("user_seq"),
+		},
+		{
+			Keys:    bson.D{{Key: "dispatched_at", Value: 1}, {Key: "seq", Value: 1}},
+			Options: options.Index().SetName("dispatched_seq"),
+		},
+		{
+			Keys: bson.D{{Key: "change_id", Value: 1}},
+			Options: options.Index().SetName(changeIndex).SetUnique(true).
+				SetPartialFilterExpression(bson.M{"change_id": bson.M{"$exists": true}}),
+		},
+	})
+	return err
+}
+
+// EnsureRetention makes the database delete events once they were handed to webhooks longer
+// than retention ago. Events that were not handed over yet are kept.
+func (r *MongoOutboxRepository) EnsureRetention(ctx context.Context, retention time.Duration) error {
+	return ensureTTLIndex(ctx, r.coll, "dispatched_ttl", "dispatched_at", retention)
+}
+
+// ListEventsAfter lists up to limit events with a sequence number above afterSeq, in order,
+// only for userID's policies if it is set.
+func (r *MongoOutboxRepository) ListEventsAfter(ctx context.Context, afterSeq int64, userID string, limit int) ([]*OutboxEvent, error) {
+	filter := bson.M{"seq": bson.M{"$gt": afterSeq}}
+	if userID != "" {
+		filter["user_id"] = userID
+	}
+	return r.find(ctx, filter, limit)
+}
+
+// LastEventSeq returns the sequence number of the latest event, or 0 if there are none. It is
+// read from the counter events are numbered from, so it holds after old events are deleted.
+func (r *MongoOutboxRepository) LastEventSeq(ctx context.Context) (int64, error) {
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := r.counters.FindOne(ctx, bson.M{"_id": policyEventsCounter}).Decode(&counter)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return counter.Seq, nil
+}
+
+// FirstEventSeq returns the sequence number of the oldest event still kept, or the one the
+// next event will get if there are none.
+func (r *MongoOutboxRepository) FirstEventSeq(ctx context.Context) (int64, error) {
+	var e OutboxEvent
+	opts := options.FindOne().SetSort(bson.D{{Key: "seq", Value: 1}}).SetProjection(bson.M{"seq": 1})
+	err := r.coll.FindOne(ctx, bson.M{}, opts).Decode(&e)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		last, err := r.LastEventSeq(ctx)
+		return last + 1, err
+	}
+	if err != nil {
+		return 0, err
+	}
+	return e.Seq, nil
+}
+
+// ListUndispatchedEvents lists up to limit events that have not been handed to webhooks yet, in order.
+func (r *MongoOutboxRepository) ListUndispatchedEvents(ctx context.Context, limit int) ([]*OutboxEvent, error) {
+	return r.find(ctx, bson.M{"dispatched_at": nil}, limit)
+}
+
+func (r *MongoOutboxRepository) MarkEventDispatched(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	_, err := r.coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"dispatched_at": at}})
+	return err
+}
+
+func (r *MongoOutboxRepository) find(ctx context.Context, filter bson.M, limit int) ([]*OutboxEvent, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}).SetLimit(int64(limit))
+	cur, err := r.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func(cur *mongo.Cursor, ctx context.Context) {
+		if err := cur.Close(ctx); err != nil {
+			log.Printf("failed to close cursor: %v\n", err)
+		}
+	}(cur, ctx)
+
+	events := make([]*OutboxEvent, 0)
+	if err := cur.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ensureTTLIndex creates an index that makes the database delete documents once field is
+// older than ttl, changing the expiry of the index if it already exists with another.
+func ensureTTLIndex(ctx context.Context, coll *mongo.Collection, name, field string, ttl time.Duration) error {
+	seconds := int32(ttl / time.Second)
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: field, Value: 1}},
+		Options: options.Index().SetName(name).SetExpireAfterSeconds(seconds),
+	})
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Code != indexOptionsConflict {
+		return err
+	}
+	return coll.Database().RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: coll.Name()},
+		{Key: "index", Value: bson.M{"name": name, "expireAfterSeconds": seconds}},
+	}).Err()
+}
+
+// inTransaction runs fn in a transaction, or as part of the caller's if ctx already carries one.
+func (r *MongoPolicyRepository) inTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+	return r.WithTransaction(ctx, fn)
+}
+
+// recordEvent adds a policy event to the outbox. It must run in the transaction that made the
+// change, so that the event is recorded if and only if the change is.
+func (r *MongoPolicyRepository) recordEvent(ctx context.Context, eventType string, p *Policy) error {
+	return r.appendEvent(ctx, newOutboxEvent(eventType, p, time.Now().UTC()))
+}
+
+// RecordChange records an event of eventType for a change to p made outside the service, such
+// as directly in the database, which therefore has none. changeID identifies the change on the
+// policies change stream: every instance following the stream reports the change, and only
+// the first records it. It reports whether this call recorded the event.
+func (r *MongoPolicyRepository) RecordChange(ctx context.Context, eventType string, p *Policy, changeID string, at time.Time) (bool, error) {
+	e := newOutboxEvent(eventType, p, at)
+	e.ChangeID = changeID
+	err := r.inTransaction(ctx, func(ctx context.Context) error {
+		return r.appendEvent(ctx, e)
+	})
+	if isDuplicateIn(err, changeIndex) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func newOutboxEvent(eventType string, p *Policy, at time.Time) *OutboxEvent {
+	e := &OutboxEvent{
+		Type:        eventType,
+		PolicyID:    p.ID,
+		UserID:      p.UserID,
+		Marketplace: p.Marketplace,
+		OccurredAt:  at,
+	}
+	if eventType != EventPolicyPurged {
+		e.Policy = p
+	}
+	return e
+}
+
+// appendEvent numbers e and inserts it into the outbox. Drawing the sequence number from a
+// single counter document makes concurrent transactions conflict, so that they commit, and
+// are numbered, one after another.
+func (r *MongoPolicyRepository) appendEvent(ctx context.Context, e *OutboxEvent) error {
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := r.counters.FindOneAndUpdate(ctx, bson.M{"_id": policyEventsCounter}, bson.M{"$inc": bson.M{"seq": 1}}, opts).Decode(&counter)
+	if err != nil {
+		return err
+	}
+
+	e.Seq = counter.Seq
+	_, err = r.events.InsertOne(ctx, e)
+	return err
+}
@@ -3,35 +3,240 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ErrDuplicatePolicyName is returned when a user already has a policy with the same name,
+// ignoring case, in the marketplace.
+var ErrDuplicatePolicyName = errors.New("a policy with this name already exists in the marketplace")
+
+// ErrDefaultPolicyExists is returned when a marketplace already has a different default policy.
+var ErrDefaultPolicyExists = errors.New("marketplace already has a default policy")
+
+// ErrDuplicatePolicyNames is returned when existing policies share a name, which stops the
+// unique name index from being built.
+var ErrDuplicatePolicyNames = errors.New("policies share a name and must be renamed")
+
 type PolicyRepository interface {
 	GetPolicy(ctx context.Context, userID uuid.UUID, id primitive.ObjectID) (*Policy, error)
 	CreatePolicy(ctx context.Context, p *Policy) error
 	ListPoliciesWithMarketplace(ctx context.Context, userID uuid.UUID, marketplace *string) ([]*Policy, error)
 	ListPolicies(ctx context.Context, userID uuid.UUID) ([]*Policy, error)
 	ListPoliciesByMarketplace(ctx context.Context, userID uuid.UUID, marketplace string) ([]*Policy, error)
-	UpdatePolicy(ctx context.Context, userID uuid.UUID, p *Policy) error
+	ListPoliciesWithFilter(ctx context.Context, userID uuid.UUID, filter PolicyFilter) ([]*Policy, error)
+	SearchPolicies(ctx context.Context, userID uuid.UUID, query, marketplace string, limit int) ([]*PolicySearchResult, error)
+	UpdatePolicy(ctx context.Context, userID uuid.UUID, p *Policy, baseScript string, baseDraft *string) (*Policy, error)
+	SetPolicyStatus(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, from, to string) (*Policy, error)
+	SetDraftScript(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, draft *string) (*Policy, error)
+	PublishPolicy(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, from string, draft *string, dayparts []Daypart, summary *PolicySummary) (*Policy, error)
+	TakeDraftScript(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, draft string) (*Policy, error)
+	ApplyScript(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, baseScript string, dayparts []Daypart, script string, summary *PolicySummary) (*Policy, error)
+	GetDefaultPolicy(ctx context.Context, userID uuid.UUID, marketplace string) (*Policy, error)
+	ClearDefaultPolicy(ctx context.Context, userID uuid.UUID, marketplace string) (*Policy, error)
+	SetPolicyDefault(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, isDefault bool) (*Policy, error)
+	SetDayparts(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, script string, dayparts []Daypart, summary *PolicySummary) (*Policy, error)
+	ApplyDayparts(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, script string, base, dayparts []Daypart, summary *PolicySummary) (*Policy, error)
+	SetPolicyWindow(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, from, until *time.Time) (*Policy, error)
+	ListPoliciesWithDueWindow(ctx context.Context, now time.Time) ([]*Policy, error)
+	AdvancePolicyWindow(ctx context.Context, p *Policy, status string, from, until *time.Time) (*Policy, error)
 	DeletePolicy(ctx context.Context, userID uuid.UUID, id string) (*Policy, error)
+	ListDeletedPolicies(ctx context.Context, userID uuid.UUID) ([]*Policy, error)
+	RestorePolicy(ctx context.Context, userID uuid.UUID, id string) (*Policy, error)
+	PurgeDeletedPolicies(ctx context.Context, deletedBefore time.Time) (int64, error)
+	ListPoliciesWithoutSummary(ctx context.Context, afterID primitive.ObjectID, limit int) ([]*Policy, error)
+	SetPolicySummary(ctx context.Context, p *Policy, summary *PolicySummary) error
+	RecordChange(ctx context.Context, eventType string, p *Policy, changeID string, at time.Time) (bool, error)
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
+// MongoPolicyRepository stores policies, recording every change to them as an OutboxEvent in
+// the same transaction.
 type MongoPolicyRepository struct {
-	coll *mongo.Collection
+	coll       *mongo.Collection
+	events     *mongo.Collection
+	counters   *mongo.Collection
+	migrations *mongo.Collection
 }
 
 func NewMongoPolicyRepository(db *mongo.Database) *MongoPolicyRepository {
-	return &MongoPolicyRepository{coll: db.Collection("policies")}
+	return &MongoPolicyRepository{
+		coll:       db.Collection("policies"),
+		events:     db.Collection("policy_events"),
+		counters:   db.Collection("counters"),
+		migrations: db.Collection("migrations"),
+	}
+}
+
+const (
+	nameIndex    = "user_marketplace_name"
+	defaultIndex = "user_marketplace_default"
+)
+
+// nameCollation compares policy names ignoring case.
+var nameCollation = &options.Collation{Locale: "en", Strength: 2}
+
+// EnsureIndexes creates the indexes that policy queries rely on. Creating an index that
+// already exists is a no-op, so this is safe to call on every startup.
+func (r *MongoPolicyRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "marketplace", Value: 1}},
+			Options: options.Index().SetName("user_marketplace"),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "status", Value: 1}},
+			Options: options.Index().SetName("user_status"),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "tags", Value: 1}},
+			Options: options.Index().SetName("user_tags"),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "summary.metrics", Value: 1}},
+			Options: options.Index().SetName("user_summary_metrics"),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "marketplace", Value: 1}},
+			Options: options.Index().
+				SetName(defaultIndex).
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"is_default": true}),
+		},
+		{
+			Keys:    bson.D{{Key: "effective_from", Value: 1}},
+			Options: options.Index().SetName("effective_from").SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: "effective_until", Value: 1}},
+			Options: options.Index().SetName("effective_until").SetSparse(true),
+		},
+		{
+			// A collection can only have one text index, so every searchable field lives here
+			Keys: bson.D{{Key: "name", Value: "text"}, {Key: "description", Value: "text"}},
+			Options: options.Index().
+				SetName("policy_text").
+				SetWeights(bson.D{{Key: "name", Value: 3}, {Key: "description", Value: 1}}),
+		},
+	})
+	return err
+}
+
+// uniqueNamesMigration names the migration that builds the unique name index.
+const uniqueNamesMigration = "unique_policy_names"
+
+// maxReportedDuplicates bounds how many duplicate names MigrateUniqueNames reports.
+const maxReportedDuplicates = 20
+
+// MigrateUniqueNames builds the index that keeps policy names unique, ignoring case, per user
+// and marketplace, once. Policies in the trash are left out so their names can be reused. A
+// partial index cannot match a missing field, so documents written before soft delete get an
+// explicit null first. If policies already share a name, nothing is built and an
+// ErrDuplicatePolicyNames error lists them, so that they can be renamed before starting again.
+func (r *MongoPolicyRepository) MigrateUniqueNames(ctx context.Context) error {
+	err := r.migrations.FindOne(ctx, bson.M{"_id": uniqueNamesMigration}).Err()
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+
+	_, err = r.coll.UpdateMany(ctx,
+		bson.M{"deleted_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"deleted_at": nil}},
+	)
+	if err != nil {
+		return err
+	}
+
+	duplicates, err := r.findDuplicateNames(ctx)
+	if err != nil {
+		return err
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("%w: %s", ErrDuplicatePolicyNames, strings.Join(duplicates, "; "))
+	}
+
+	_, err = r.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "marketplace", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().
+			SetName(nameIndex).
+			SetUnique(true).
+			SetCollation(nameCollation).
+			SetPartialFilterExpression(bson.M{"deleted_at": bson.M{"$type": "null"}}),
+	})
+	if err != nil {
+		return fmt.Errorf("unique name index: %w", err)
+	}
+
+	_, err = r.migrations.InsertOne(ctx, bson.M{"_id": uniqueNamesMigration, "applied_at": time.Now().UTC()})
+	if mongo.IsDuplicateKeyError(err) {
+		// Another replica finished the migration first
+		return nil
+	}
+	return err
+}
+
+// findDuplicateNames describes the names, compared like the unique name index compares them,
+// that several policies outside the trash share within a user's marketplace.
+func (r *MongoPolicyRepository) findDuplicateNames(ctx context.Context) ([]string, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"deleted_at": nil}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"user_id": "$user_id", "marketplace": "$marketplace", "name": "$name"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		{{Key: "$limit", Value: maxReportedDuplicates}},
+	}
+	cur, err := r.coll.Aggregate(ctx, pipeline, options.Aggregate().SetCollation(nameCollation))
+	if err != nil {
+		return nil, err
+	}
+	defer func(cur *mongo.Cursor, ctx context.Context) {
+		if err := cur.Close(ctx); err != nil {
+			log.Printf("failed to close cursor: %v\n", err)
+		}
+	}(cur, ctx)
+
+	var groups []struct {
+		ID struct {
+			UserID      string `bson:"user_id"`
+			Marketplace string `bson:"marketplace"`
+			Name        string `bson:"name"`
+		} `bson:"_id"`
+		Count int `bson:"count"`
+	}
+	if err := cur.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	duplicates := make([]string, 0, len(groups))
+	for _, g := range groups {
+		duplicates = append(duplicates, fmt.Sprintf("user %s has %d policies named %q in %s", g.ID.UserID, g.Count, g.ID.Name, g.ID.Marketplace))
+	}
+	return duplicates, nil
+}
+
+// isDuplicateIn reports whether err is a duplicate key error raised by the named index, as
+// opposed to another unique index written to in the same operation.
+func isDuplicateIn(err error, index string) bool {
+	return mongo.IsDuplicateKeyError(err) && strings.Contains(err.Error(), "index: "+index+" ")
 }
 
+// GetPolicy retrieves a policy that is not in the trash. Like the other lookups, it matches
+// "deleted_at": nil, which also covers documents written before soft delete existed.
 func (r *MongoPolicyRepository) GetPolicy(ctx context.Context, userID uuid.UUID, id primitive.ObjectID) (*Policy, error) {
-	filter := bson.M{"_id": id, "user_id": userID.String()}
+	filter := bson.M{"_id": id, "user_id": userID.String(), "deleted_at": nil}
 
 	var doc policyDoc
 	err := r.coll.FindOne(ctx, filter).Decode(&doc)
@@ -42,24 +247,25 @@ func (r *MongoPolicyRepository) GetPolicy(ctx context.Context, userID uuid.UUID,
 		return nil, err
 	}
 
-	return &Policy{
-		ID:          doc.ID,
-		UserID:      doc.UserID,
-		Marketplace: doc.Marketplace,
-		Name:        doc.Name,
-		Script:      doc.Script,
-	}, nil
+	return doc.toPolicy(), nil
 }
 
+// CreatePolicy stores a new policy, returning ErrDuplicatePolicyName if its name is taken.
 func (r *MongoPolicyRepository) CreatePolicy(ctx context.Context, p *Policy) error {
-	res, err := r.coll.InsertOne(ctx, p)
-	if err != nil {
-		return err
-	}
-	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
-		p.ID = oid
+	err := r.inTransaction(ctx, func(ctx context.Context) error {
+		res, err := r.coll.InsertOne(ctx, p)
+		if err != nil {
+			return err
+		}
+		if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+			p.ID = oid
+		}
+		return r.recordEvent(ctx, EventPolicyCreated, p)
+	})
+	if isDuplicateIn(err, nameIndex) {
+		return ErrDuplicatePolicyName
 	}
-	return nil
+	return err
 }
 
 type policyDoc struct {
@@ -67,18 +273,131 @@ type policyDoc struct {
 	UserID      string             `bson:"user_id"`
 	Marketplace string             `bson:"marketplace"`
 	Name        string             `bson:"name"`
+	Description string             `bson:"description"`
 	Script      string             `bson:"script"`
+	DraftScript *string            `bson:"draft_script"`
+	Status      string             `bson:"status"`
+	Tags        []string           `bson:"tags"`
+	Summary     *PolicySummary     `bson:"summary"`
+	DeletedAt   *time.Time         `bson:"deleted_at"`
+
+	EffectiveFrom  *time.Time `bson:"effective_from"`
+	EffectiveUntil *time.Time `bson:"effective_until"`
+
+	Dayparts []Daypart `bson:"dayparts"`
+
+	IsDefault bool `bson:"is_default"`
+}
+
+func (d *policyDoc) toPolicy() *Policy {
+	status := d.Status
+	if status == "" {
+		status = StatusActive
+	}
+	return &Policy{
+		ID:          d.ID,
+		UserID:      d.UserID,
+		Marketplace: d.Marketplace,
+		Name:        d.Name,
+		Description: d.Description,
+		Script:      d.Script,
+		DraftScript: d.DraftScript,
+		Status:      status,
+		Tags:        d.Tags,
+		Summary:     d.Summary,
+		DeletedAt:   d.DeletedAt,
+
+		EffectiveFrom:  d.EffectiveFrom,
+		EffectiveUntil: d.EffectiveUntil,
+
+		Dayparts: d.Dayparts,
+
+		IsDefault: d.IsDefault,
+	}
 }
 
 // ListPoliciesWithMarketplace lists policies for a user, optionally filtered by marketplace.
 func (r *MongoPolicyRepository) ListPoliciesWithMarketplace(ctx context.Context, userID uuid.UUID, marketplace *string) ([]*Policy, error) {
-	filter := bson.M{"user_id": userID.String()}
+	var filter PolicyFilter
 	if marketplace != nil {
-		if m := strings.TrimSpace(*marketplace); m != "" {
-			filter["marketplace"] = m
+		filter.Marketplace = *marketplace
+	}
+	return r.ListPoliciesWithFilter(ctx, userID, filter)
+}
+
+// ListPoliciesWithFilter lists a user's policies matching every non-empty field of filter.
+func (r *MongoPolicyRepository) ListPoliciesWithFilter(ctx context.Context, userID uuid.UUID, filter PolicyFilter) ([]*Policy, error) {
+	query := bson.M{"user_id": userID.String(), "deleted_at": nil}
+	if m := strings.TrimSpace(filter.Marketplace); m != "" {
+		query["marketplace"] = m
+	}
+	if len(filter.Tags) > 0 {
+		if filter.MatchAllTags {
+			query["tags"] = bson.M{"$all": filter.Tags}
+		} else {
+			query["tags"] = bson.M{"$in": filter.Tags}
 		}
 	}
-	cur, err := r.coll.Find(ctx, filter)
+	if len(filter.Metrics) > 0 {
+		query["summary.metrics"] = bson.M{"$all": filter.Metrics}
+	}
+	if len(filter.Operators) > 0 {
+		query["summary.operators"] = bson.M{"$all": filter.Operators}
+	}
+	if len(filter.Statuses) > 0 {
+		query["status"] = statusMatch(filter.Statuses...)
+	}
+	return r.findPolicies(ctx, query, nil)
+}
+
+// searchDoc is a policy document with the text score projected alongside it.
+type searchDoc struct {
+	policyDoc `bson:",inline"`
+	Score     float64 `bson:"score"`
+}
+
+// SearchPolicies runs a full-text query over a user's policy names and descriptions,
+// optionally within a marketplace, returning at most limit results in relevance order.
+func (r *MongoPolicyRepository) SearchPolicies(ctx context.Context, userID uuid.UUID, query, marketplace string, limit int) ([]*PolicySearchResult, error) {
+	filter := bson.M{
+		"$text":      bson.M{"$search": query},
+		"user_id":    userID.String(),
+		"deleted_at": nil,
+	}
+	if m := strings.TrimSpace(marketplace); m != "" {
+		filter["marketplace"] = m
+	}
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}}).
+		SetLimit(int64(limit))
+
+	cur, err := r.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func(cur *mongo.Cursor, ctx context.Context) {
+		if err := cur.Close(ctx); err != nil {
+			log.Printf("failed to close cursor: %v\n", err)
+		}
+	}(cur, ctx)
+
+	var docs []searchDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	results := make([]*PolicySearchResult, 0, len(docs))
+	for _, d := range docs {
+		results = append(results, &PolicySearchResult{Policy: d.toPolicy(), Score: d.Score})
+	}
+
+	return results, nil
+}
+
+func (r *MongoPolicyRepository) findPolicies(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]*Policy, error) {
+	cur, err := r.coll.Find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -96,13 +415,7 @@ func (r *MongoPolicyRepository) ListPoliciesWithMarketplace(ctx context.Context,
 
 	policies := make([]*Policy, 0, len(docs))
 	for _, d := range docs {
-		policies = append(policies, &Policy{
-			ID:          d.ID,
-			UserID:      d.UserID,
-			Marketplace: d.Marketplace,
-			Name:        d.Name,
-			Script:      d.Script,
-		})
+		policies = append(policies, d.toPolicy())
 	}
 
 	return policies, nil
@@ -117,45 +430,383 @@ func (r *MongoPolicyRepository) ListPoliciesByMarketplace(ctx context.Context, u
 	return r.ListPoliciesWithMarketplace(ctx, userID, &marketplace)
 }
 
-// DeletePolicy deletes a policy by its ID.
+// DeletePolicy moves a policy to the trash by stamping its deletion time. A policy in the trash
+// is no longer the default.
 func (r *MongoPolicyRepository) DeletePolicy(ctx context.Context, userID uuid.UUID, id string) (*Policy, error) {
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, nil
 	}
-	filter := bson.M{"_id": objID, "user_id": userID.String()}
+	filter := bson.M{"_id": objID, "user_id": userID.String(), "deleted_at": nil}
+	update := bson.M{"$set": bson.M{"deleted_at": time.Now().UTC(), "is_default": false}}
+
+	return r.findOneAndUpdate(ctx, EventPolicyDeleted, filter, update)
+}
+
+// ListDeletedPolicies lists the policies in a user's trash, most recently deleted first.
+func (r *MongoPolicyRepository) ListDeletedPolicies(ctx context.Context, userID uuid.UUID) ([]*Policy, error) {
+	filter := bson.M{"user_id": userID.String(), "deleted_at": bson.M{"$ne": nil}}
+	opts := options.Find().SetSort(bson.D{{Key: "deleted_at", Value: -1}})
+	return r.findPolicies(ctx, filter, opts)
+}
+
+// RestorePolicy takes a policy back out of the trash, returning ErrDuplicatePolicyName if
+// another policy has taken its name in the meantime.
+func (r *MongoPolicyRepository) RestorePolicy(ctx context.Context, userID uuid.UUID, id string) (*Policy, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+	filter := bson.M{"_id": objID, "user_id": userID.String(), "deleted_at": bson.M{"$ne": nil}}
+	update := bson.M{"$set": bson.M{"deleted_at": nil}}
+
+	policy, err := r.findOneAndUpdate(ctx, EventPolicyUpdated, filter, update)
+	if isDuplicateIn(err, nameIndex) {
+		return nil, ErrDuplicatePolicyName
+	}
+	return policy, err
+}
+
+// purgeBatch bounds the policies purged per transaction. Every purge records an event, and
+// events are numbered from a single counter, so a long transaction would hold up every other
+// write to policies, and an unbounded one could outgrow the limits on transactions.
+const purgeBatch = 100
+
+// PurgeDeletedPolicies permanently removes policies that were moved to the trash before
+// deletedBefore, purgeBatch at a time, each batch in its own transaction.
+func (r *MongoPolicyRepository) PurgeDeletedPolicies(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var total int64
+	for {
+		purged, found, err := r.purgeNextBatch(ctx, deletedBefore)
+		total += purged
+		if err != nil || found < purgeBatch {
+			return total, err
+		}
+	}
+}
+
+// purgeNextBatch purges up to purgeBatch policies moved to the trash before deletedBefore,
+// returning how many it purged and how many it found to purge.
+func (r *MongoPolicyRepository) purgeNextBatch(ctx context.Context, deletedBefore time.Time) (purged int64, found int, err error) {
+	err = r.inTransaction(ctx, func(ctx context.Context) error {
+		purged, found = 0, 0
+		opts := options.Find().SetLimit(purgeBatch)
+		policies, err := r.findPolicies(ctx, bson.M{"deleted_at": bson.M{"$ne": nil, "$lte": deletedBefore}}, opts)
+		if err != nil || len(policies) == 0 {
+			return err
+		}
+
+		ids := make(bson.A, len(policies))
+		for i, p := range policies {
+			ids[i] = p.ID
+		}
+		res, err := r.coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			return err
+		}
+		for _, p := range policies {
+			if err := r.recordEvent(ctx, EventPolicyPurged, p); err != nil {
+				return err
+			}
+		}
+		purged, found = res.DeletedCount, len(policies)
+		return nil
+	})
+	return purged, found, err
+}
+
+// SetPolicyStatus moves a policy from one status to another, returning nil if the policy does
+// not exist or no longer has status from. Archiving a policy also stops it being the default.
+func (r *MongoPolicyRepository) SetPolicyStatus(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, from, to string) (*Policy, error) {
+	filter := bson.M{"_id": id, "user_id": userID.String(), "deleted_at": nil, "status": statusMatch(from)}
+	set := bson.M{"status": to}
+	if to == StatusArchived {
+		set["is_default"] = false
+	}
+	return r.findOneAndUpdate(ctx, EventPolicyUpdated, filter, bson.M{"$set": set})
+}
+
+// SetDraftScript replaces a policy's draft script, or discards it when draft is nil.
+// Archived policies are not matched.
+func (r *MongoPolicyRepository) SetDraftScript(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, draft *string) (*Policy, error) {
+	filter := bson.M{"_id": id, "user_id": userID.String(), "deleted_at": nil, "status": bson.M{"$ne": StatusArchived}}
+	update := bson.M{"$set": bson.M{"draft_script": draft}}
+	return r.findOneAndUpdate(ctx, EventPolicyUpdated, filter, update)
+}
+
+// PublishPolicy activates a policy that currently has status from. When draft is non-nil it
+// also promotes the draft to the published script, along with its summary, and clears it.
+// It returns nil if the policy does not exist or its status, draft or dayparts changed since
+// they were read.
+func (r *MongoPolicyRepository) PublishPolicy(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, from string, draft *string, dayparts []Daypart, summary *PolicySummary) (*Policy, error) {
+	filter := bson.M{
+		"_id":          id,
+		"user_id":      userID.String(),
+		"deleted_at":   nil,
+		"status":       statusMatch(from),
+		"draft_script": draft,
+		"dayparts":     daypartsMatch(dayparts),
+	}
+	set := bson.M{"status": StatusActive}
+	if draft != nil {
+		set["script"] = *draft
+		set["summary"] = summary
+		set["draft_script"] = nil
+	}
+	return r.findOneAndUpdate(ctx, EventPolicyUpdated, filter, bson.M{"$set": set})
+}
+
+// TakeDraftScript clears a policy's draft so it can be submitted for approval. It returns nil
+// if the policy does not exist or its draft is no longer draft.
+func (r *MongoPolicyRepository) TakeDraftScript(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, draft string) (*Policy, error) {
+	filter := bson.M{"_id": id, "user_id": userID.String(), "deleted_at": nil, "draft_script": draft}
+	update := bson.M{"$set": bson.M{"draft_script": nil}}
+	return r.findOneAndUpdate(ctx, EventPolicyUpdated, filter, update)
+}
+
+// ApplyScript replaces a policy's published script, and its summary, with script. It returns
+// nil if the policy does not exist, is archived, no longer publishes baseScript or no longer
+// has the dayparts the summary was built with.
+func (r *MongoPolicyRepository) ApplyScript(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, baseScript string, dayparts []Daypart, script string, summary *PolicySummary) (*Policy, error) {
+	filter := bson.M{
+		"_id":        id,
+		"user_id":    userID.String(),
+		"deleted_at": nil,
+		"status":     bson.M{"$ne": StatusArchived},
+		"script":     baseScript,
+		"dayparts":   daypartsMatch(dayparts),
+	}
+	update := bson.M{"$set": bson.M{"script": script, "summary": summary}}
+	return r.findOneAndUpdate(ctx, EventPolicyUpdated, filter, update)
+}
+
+// GetDefaultPolicy returns the user's default policy for a marketplace, or nil if there is none.
+func (r *MongoPolicyRepository) GetDefaultPolicy(ctx context.Context, userID uuid.UUID, marketplace string) (*Policy, error) {
+	filter := bson.M{"user_id": userID.String(), "marketplace": marketplace, "is_default": true, "deleted_at": nil}
 
-	// Use FindOneAndDelete to retrieve the document and validate/parse rules similarly
 	var doc policyDoc
-	res := r.coll.FindOneAndDelete(ctx, filter)
-	if err := res.Decode(&doc); err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, nil
+	err := r.coll.FindOne(ctx, filter).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.toPolicy(), nil
+}
+
+// ClearDefaultPolicy stops the user's default policy for a marketplace being the default,
+// returning the policy that was the default, or nil if there was none.
+func (r *MongoPolicyRepository) ClearDefaultPolicy(ctx context.Context, userID uuid.UUID, marketplace string) (*Policy, error) {
+	filter := bson.M{"user_id": userID.String(), "marketplace": marketplace, "is_default": true}
+	return r.findOneAndUpdate(ctx, EventPolicyUpdated, filter, bson.M{"$set": bson.M{"is_default": false}})
+}
+
+// SetPolicyDefault marks or unmarks a policy as its marketplace's default. Archived policies are
+// not matched, and ErrDefaultPolicyExists is returned if the marketplace already has a default.
+func (r *MongoPolicyRepository) SetPolicyDefault(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, isDefault bool) (*Policy, error) {
+	filter := bson.M{"_id": id, "user_id": userID.String(), "deleted_at": nil, "status": bson.M{"$ne": StatusArchived}}
+	policy, err := r.findOneAndUpdate(ctx, EventPolicyUpdated, filter, bson.M{"$set": bson.M{"is_default": isDefault}})
+	if isDuplicateIn(err, defaultIndex) {
+		return nil, ErrDefaultPolicyExists
+	}
+	return policy, err
+}
+
+// SetDayparts replaces a policy's dayparts, and its summary. It returns nil if the policy does
+// not exist, is archived, or no longer publishes the script the summary was built with.
+func (r *MongoPolicyRepository) SetDayparts(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, script string, dayparts []Daypart, summary *PolicySummary) (*Policy, error) {
+	filter := bson.M{"_id": id, "user_id": userID.String(), "deleted_at": nil, "status": bson.M{"$ne": StatusArchived}, "script": script}
+	update := bson.M{"$set": bson.M{"dayparts": dayparts, "summary": summary}}
+	return r.findOneAndUpdate(ctx, EventPolicyUpdated, filter, update)
+}
+
+// ApplyDayparts replaces a policy's dayparts with dayparts, and its summary. It returns nil if
+// the policy does not exist, is archived, no longer has the dayparts in base or no longer
+// publishes the script the summary was built with.
+func (r *MongoPolicyRepository) ApplyDayparts(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, script string, base, dayparts []Daypart, summary *PolicySummary) (*Policy, error) {
+	filter := bson.M{
+		"_id":        id,
+		"user_id":    userID.String(),
+		"deleted_at": nil,
+		"status":     bson.M{"$ne": StatusArchived},
+		"script":     script,
+		"dayparts":   daypartsMatch(base),
+	}
+	update := bson.M{"$set": bson.M{"dayparts": dayparts, "summary": summary}}
+	return r.findOneAndUpdate(ctx, EventPolicyUpdated, filter, update)
+}
+
+// daypartsMatch matches a policy having exactly dayparts.
+func daypartsMatch(dayparts []Daypart) any {
+	if len(dayparts) == 0 {
+		// Policies without dayparts may store them as missing, null or empty
+		return bson.M{"$in": bson.A{nil, bson.A{}}}
+	}
+	return dayparts
+}
+
+// SetPolicyWindow sets the window in which a policy is active; nil bounds are removed.
+// Archived policies are not matched.
+func (r *MongoPolicyRepository) SetPolicyWindow(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, from, until *time.Time) (*Policy, error) {
+	filter := bson.M{"_id": id, "user_id": userID.String(), "deleted_at": nil, "status": bson.M{"$ne": StatusArchived}}
+	return r.findOneAndUpdate(ctx, EventPolicyUpdated, filter, windowUpdate(bson.M{}, from, until))
+}
+
+// ListPoliciesWithDueWindow lists policies, across all users, whose window opens or closes at or before now.
+func (r *MongoPolicyRepository) ListPoliciesWithDueWindow(ctx context.Context, now time.Time) ([]*Policy, error) {
+	filter := bson.M{
+		"deleted_at": nil,
+		"status":     bson.M{"$ne": StatusArchived},
+		"$or": bson.A{
+			bson.M{"effective_from": bson.M{"$lte": now}},
+			bson.M{"effective_until": bson.M{"$lte": now}},
+		},
+	}
+	return r.findPolicies(ctx, filter, nil)
+}
+
+// AdvancePolicyWindow sets the status and remaining window bounds of p after the scheduler
+// acted on it. It returns nil if the policy's status or window changed since p was read.
+func (r *MongoPolicyRepository) AdvancePolicyWindow(ctx context.Context, p *Policy, status string, from, until *time.Time) (*Policy, error) {
+	filter := bson.M{
+		"_id":             p.ID,
+		"deleted_at":      nil,
+		"status":          statusMatch(p.Status),
+		"effective_from":  p.EffectiveFrom,
+		"effective_until": p.EffectiveUntil,
+	}
+	return r.findOneAndUpdate(ctx, EventPolicyUpdated, filter, windowUpdate(bson.M{"status": status}, from, until))
+}
+
+// windowUpdate adds the window bounds to set, unsetting nil bounds so the sparse window
+// indexes only hold policies that still have something scheduled.
+func windowUpdate(set bson.M, from, until *time.Time) bson.M {
+	unset := bson.M{}
+	if from != nil {
+		set["effective_from"] = *from
+	} else {
+		unset["effective_from"] = ""
+	}
+	if until != nil {
+		set["effective_until"] = *until
+	} else {
+		unset["effective_until"] = ""
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update
+}
+
+// statusMatch matches any of statuses, counting policies without a status as active.
+func statusMatch(statuses ...string) bson.M {
+	values := bson.A{}
+	for _, status := range statuses {
+		values = append(values, status)
+		if status == StatusActive {
+			values = append(values, nil)
+		}
+	}
+	return bson.M{"$in": values}
+}
+
+// ListPoliciesWithoutSummary pages through policies, across all users, that were stored
+// before summaries existed, before summaries kept percentage and absolute bid changes apart,
+// or before they covered dayparts. Pages are ordered by ID and start after afterID.
+func (r *MongoPolicyRepository) ListPoliciesWithoutSummary(ctx context.Context, afterID primitive.ObjectID, limit int) ([]*Policy, error) {
+	// Summaries gained daypart_count last, so any older summary lacks it
+	filter := bson.M{"_id": bson.M{"$gt": afterID}, "summary.daypart_count": bson.M{"$exists": false}}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit))
+	return r.findPolicies(ctx, filter, opts)
+}
+
+// SetPolicySummary stores the summary of p's script and dayparts. Summaries are derived from
+// them, so unlike other changes this records no event. Nothing is stored if p was deleted or
+// its script or dayparts changed since it was read, as the change that did so stored a
+// summary of its own.
+func (r *MongoPolicyRepository) SetPolicySummary(ctx context.Context, p *Policy, summary *PolicySummary) error {
+	filter := bson.M{"_id": p.ID, "deleted_at": nil, "script": p.Script, "dayparts": daypartsMatch(p.Dayparts)}
+	_, err := r.coll.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"summary": summary}})
+	return err
+}
+
+// findOneAndUpdate applies update to the first match and returns the updated policy, or nil if
+// nothing matched. The change is recorded as an event of eventType.
+func (r *MongoPolicyRepository) findOneAndUpdate(ctx context.Context, eventType string, filter bson.M, update bson.M) (*Policy, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var policy *Policy
+	err := r.inTransaction(ctx, func(ctx context.Context) error {
+		policy = nil
+		var doc policyDoc
+		res := r.coll.FindOneAndUpdate(ctx, filter, update, opts)
+		if err := res.Decode(&doc); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil
+			}
+			return err
 		}
+		policy = doc.toPolicy()
+		return r.recordEvent(ctx, eventType, policy)
+	})
+	if err != nil {
 		return nil, err
 	}
+	return policy, nil
+}
 
-	return &Policy{
-		ID:          doc.ID,
-		UserID:      doc.UserID,
-		Marketplace: doc.Marketplace,
-		Name:        doc.Name,
-		Script:      doc.Script,
-	}, nil
-}
-
-func (r *MongoPolicyRepository) UpdatePolicy(ctx context.Context, userID uuid.UUID, p *Policy) error {
-	filter := bson.M{"_id": p.ID, "user_id": userID.String()}
-	update := bson.M{"$set": bson.M{
-		"name":   p.Name,
-		"script": p.Script,
-	}}
-	res, err := r.coll.UpdateOne(ctx, filter, update)
+// UpdatePolicy saves a policy's editable fields, returning ErrDuplicatePolicyName if it was
+// renamed to a name that is taken. Only drafts take a new script; the published script of any
+// other policy is changed by publishing, approving or scheduling, so it is left alone. It returns
+// nil if the policy does not exist, or its status, script, draft script or dayparts are no
+// longer what p was read with, baseScript and baseDraft, so that a concurrent change is not
+// overwritten.
+func (r *MongoPolicyRepository) UpdatePolicy(ctx context.Context, userID uuid.UUID, p *Policy, baseScript string, baseDraft *string) (*Policy, error) {
+	filter := bson.M{
+		"_id":          p.ID,
+		"user_id":      userID.String(),
+		"deleted_at":   nil,
+		"status":       statusMatch(p.Status),
+		"script":       baseScript,
+		"draft_script": baseDraft,
+		"dayparts":     daypartsMatch(p.Dayparts),
+	}
+	set := bson.M{
+		"name":         p.Name,
+		"description":  p.Description,
+		"draft_script": p.DraftScript,
+		"tags":         p.Tags,
+	}
+	if p.Status == StatusDraft {
+		set["script"] = p.Script
+		set["summary"] = p.Summary
+	}
+	updated, err := r.findOneAndUpdate(ctx, EventPolicyUpdated, filter, bson.M{"$set": set})
+	if isDuplicateIn(err, nameIndex) {
+		return nil, ErrDuplicatePolicyName
+	}
+	return updated, err
+}
+
+// WithTransaction runs fn inside a MongoDB transaction. Repository calls made with the
+// context passed to fn take part in the transaction, which is aborted if fn returns an error.
+// fn may be retried on transient transaction errors, so it must be safe to run again.
+func (r *MongoPolicyRepository) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := r.coll.Database().Client().StartSession()
 	if err != nil {
 		return err
 	}
-	if res.MatchedCount == 0 {
-		return mongo.ErrNoDocuments
-	}
-	return nil
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
 }
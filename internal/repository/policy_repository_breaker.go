@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/db"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BreakerPolicyRepository guards a PolicyRepository with a circuit breaker, so that while the
+// database is unreachable every call fails fast with db.ErrUnavailable.
+type BreakerPolicyRepository struct {
+	repo    PolicyRepository
+	breaker *db.Breaker
+}
+
+func NewBreakerPolicyRepository(repo PolicyRepository, breaker *db.Breaker) *BreakerPolicyRepository {
+	return &BreakerPolicyRepository{repo: repo, breaker: breaker}
+}
+
+// guard runs fn through the breaker, returning its result.
+func guard[T any](b *db.Breaker, fn func() (T, error)) (T, error) {
+	var result T
+	err := b.Do(func() error {
+		var err error
+		result, err = fn()
+		return err
+	})
+	return result, err
+}
+
+func (r *BreakerPolicyRepository) GetPolicy(ctx context.Context, userID uuid.UUID, id primitive.ObjectID) (*Policy, error) {
+	return guard(r.breaker, func() (*Policy, error) { return r.repo.GetPolicy(ctx, userID, id) })
+}
+
+func (r *BreakerPolicyRepository) CreatePolicy(ctx context.Context, p *Policy) error {
+	return r.breaker.Do(func() error { return r.repo.CreatePolicy(ctx, p) })
+}
+
+func (r *BreakerPolicyRepository) ListPoliciesWithMarketplace(ctx context.Context, userID uuid.UUID, marketplace *string) ([]*Policy, error) {
+	return guard(r.breaker, func() ([]*Policy, error) { return r.repo.ListPoliciesWithMarketplace(ctx, userID, marketplace) })
+}
+
+func (r *BreakerPolicyRepository) ListPolicies(ctx context.Context, userID uuid.UUID) ([]*Policy, error) {
+	return guard(r.breaker, func() ([]*Policy, error) { return r.repo.ListPolicies(ctx, userID) })
+}
+
+func (r *BreakerPolicyRepository) ListPoliciesByMarketplace(ctx context.Context, userID uuid.UUID, marketplace string) ([]*Policy, error) {
+	return guard(r.breaker, func() ([]*Policy, error) { return r.repo.ListPoliciesByMarketplace(ctx, userID, marketplace) })
+}
+
+func (r *BreakerPolicyRepository) ListPoliciesWithFilter(ctx context.Context, userID uuid.UUID, filter PolicyFilter) ([]*Policy, error) {
+	return guard(r.breaker, func() ([]*Policy, error) { return r.repo.ListPoliciesWithFilter(ctx, userID, filter) })
+}
+
+func (r *BreakerPolicyRepository) SearchPolicies(ctx context.Context, userID uuid.UUID, query, marketplace string, limit int) ([]*PolicySearchResult, error) {
+	return guard(r.breaker, func() ([]*PolicySearchResult, error) {
+		return r.repo.SearchPolicies(ctx, userID, query, marketplace, limit)
+	})
+}
+
+func (r *BreakerPolicyRepository) UpdatePolicy(ctx context.Context, userID uuid.UUID, p *Policy, baseScript string, baseDraft *string) (*Policy, error) {
+	return guard(r.breaker, func() (*Policy, error) { return r.repo.UpdatePolicy(ctx, userID, p, baseScript, baseDraft) })
+}
+
+func (r *BreakerPolicyRepository) SetPolicyStatus(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, from, to string) (*Policy, error) {
+	return guard(r.breaker, func() (*Policy, error) { return r.repo.SetPolicyStatus(ctx, userID, id, from, to) })
+}
+
+func (r *BreakerPolicyRepository) SetDraftScript(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, draft *string) (*Policy, error) {
+	return guard(r.breaker, func() (*Policy, error) { return r.repo.SetDraftScript(ctx, userID, id, draft) })
+}
+
+func (r *BreakerPolicyRepository) PublishPolicy(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, from string, draft *string, dayparts []Daypart, summary *PolicySummary) (*Policy, error) {
+	return guard(r.breaker, func() (*Policy, error) {
+		return r.repo.PublishPolicy(ctx, userID, id, from, draft, dayparts, summary)
+	})
+}
+
+func (r *BreakerPolicyRepository) TakeDraftScript(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, draft string) (*Policy, error) {
+	return guard(r.breaker, func() (*Policy, error) { return r.repo.TakeDraftScript(ctx, userID, id, draft) })
+}
+
+func (r *BreakerPolicyRepository) ApplyScript(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, baseScript string, dayparts []Daypart, script string, summary *PolicySummary) (*Policy, error) {
+	return guard(r.breaker, func() (*Policy, error) {
+		return r.repo.ApplyScript(ctx, userID, id, baseScript, dayparts, script, summary)
+	})
+}
+
+func (r *BreakerPolicyRepository) GetDefaultPolicy(ctx context.Context, userID uuid.UUID, marketplace string) (*Policy, error) {
+	return guard(r.breaker, func() (*Policy, error) { return r.repo.GetDefaultPolicy(ctx, userID, marketplace) })
+}
+
+func (r *BreakerPolicyRepository) ClearDefaultPolicy(ctx context.Context, userID uuid.UUID, marketplace string) (*Policy, error) {
+	return guard(r.breaker, func() (*Policy, error) { return r.repo.ClearDefaultPolicy(ctx, userID, marketplace) })
+}
+
+func (r *BreakerPolicyRepository) SetPolicyDefault(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, isDefault bool) (*Policy, error) {
+	return guard(r.breaker, func() (*Policy, error) { return r.repo.SetPolicyDefault(ctx, userID, id, isDefault) })
+}
+
+func (r *BreakerPolicyRepository) SetDayparts(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, script string, dayparts []Daypart, summary *PolicySummary) (*Policy, error) {
+	return guard(r.breaker, func() (*Policy, error) {
+		return r.repo.SetDayparts(ctx, userID, id, script, dayparts, summary)
+	})
+}
+
+func (r *BreakerPolicyRepository) ApplyDayparts(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, script string, base, dayparts []Daypart, summary *PolicySummary) (*Policy, error) {
+	return guard(r.breaker, func() (*Policy, error) {
+		return r.repo.ApplyDayparts(ctx, userID, id, script, base, dayparts, summary)
+	})
+}
+
+func (r *BreakerPolicyRepository) SetPolicyWindow(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, from, until *time.Time) (*Policy, error) {
+	return guard(r.breaker, func() (*Policy, error) { return r.repo.SetPolicyWindow(ctx, userID, id, from, until) })
+}
+
+func (r *BreakerPolicyRepository) ListPoliciesWithDueWindow(ctx context.Context, now time.Time) ([]*Policy, error) {
+	return guard(r.breaker, func() ([]*Policy, error) { return r.repo.ListPoliciesWithDueWindow(ctx, now) })
+}
+
+func (r *BreakerPolicyRepository) AdvancePolicyWindow(ctx context.Context, p *Policy, status string, from, until *time.Time) (*Policy, error) {
+	return guard(r.breaker, func() (*Policy, error) { return r.repo.AdvancePolicyWindow(ctx, p, status, from, until) })
+}
+
+func (r *BreakerPolicyRepository) DeletePolicy(ctx context.Context, userID uuid.UUID, id string) (*Policy, error) {
+	return guard(r.breaker, func() (*Policy, error) { return r.repo.DeletePolicy(ctx, userID, id) })
+}
+
+func (r *BreakerPolicyRepository) ListDeletedPolicies(ctx context.Context, userID uuid.UUID) ([]*Policy, error) {
+	return guard(r.breaker, func() ([]*Policy, error) { return r.repo.ListDeletedPolicies(ctx, userID) })
+}
+
+func (r *BreakerPolicyRepository) RestorePolicy(ctx context.Context, userID uuid.UUID, id string) (*Policy, error) {
+	return guard(r.breaker, func() (*Policy, error) { return r.repo.RestorePolicy(ctx, userID, id) })
+}
+
+func (r *BreakerPolicyRepository) PurgeDeletedPolicies(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	return guard(r.breaker, func() (int64, error) { return r.repo.PurgeDeletedPolicies(ctx, deletedBefore) })
+}
+
+func (r *BreakerPolicyRepository) ListPoliciesWithoutSummary(ctx context.Context, afterID primitive.ObjectID, limit int) ([]*Policy, error) {
+	return guard(r.breaker, func() ([]*Policy, error) { return r.repo.ListPoliciesWithoutSummary(ctx, afterID, limit) })
+}
+
+func (r *BreakerPolicyRepository) SetPolicySummary(ctx context.Context, p *Policy, summary *PolicySummary) error {
+	return r.breaker.Do(func() error { return r.repo.SetPolicySummary(ctx, p, summary) })
+}
+
+func (r *BreakerPolicyRepository) RecordChange(ctx context.Context, eventType string, p *Policy, changeID string, at time.Time) (bool, error) {
+	return guard(r.breaker, func() (bool, error) { return r.repo.RecordChange(ctx, eventType, p, changeID, at) })
+}
+
+// WithTransaction guards the transaction as a whole; the calls made within it are guarded too.
+func (r *BreakerPolicyRepository) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.breaker.Do(func() error { return r.repo.WithTransaction(ctx, fn) })
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ScheduleRepository interface {
+	CreateScheduledChange(ctx context.Context, change *ScheduledChange) error
+	ListScheduledChanges(ctx context.Context, userID uuid.UUID, policyID primitive.ObjectID) ([]*ScheduledChange, error)
+	ListOpenScheduledChanges(ctx context.Context, policyID primitive.ObjectID) ([]*ScheduledChange, error)
+	ListDueScheduledChanges(ctx context.Context, now time.Time) ([]*ScheduledChange, error)
+	MarkScheduledChangeApplied(ctx context.Context, id primitive.ObjectID, previousScript string, at time.Time) (*ScheduledChange, error)
+	MarkScheduledChangeReverted(ctx context.Context, id primitive.ObjectID, at time.Time) (*ScheduledChange, error)
+	MarkScheduledChangeFailed(ctx context.Context, id primitive.ObjectID, from, reason string) (*ScheduledChange, error)
+	CancelScheduledChange(ctx context.Context, userID uuid.UUID, policyID, id primitive.ObjectID) (*ScheduledChange, error)
+}
+
+type MongoScheduleRepository struct {
+	coll *mongo.Collection
+}
+
+func NewMongoScheduleRepository(db *mongo.Database) *MongoScheduleRepository {
+	return &MongoScheduleRepository{coll: db.Collection("scheduled_changes")}
+}
+
+// EnsureIndexes creates the indexes that the scheduler and policy listings rely on.
+func (r *MongoScheduleRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "effective_from", Value: 1}},
+			Options: options.Index().SetName("status_effective_from"),
+		},
+		{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "effective_until", Value: 1}},
+			Options: options.Index().SetName("status_effective_until"),
+		},
+		{
+			Keys:    bson.D{{Key: "policy_id", Value: 1}, {Key: "effective_from", Value: 1}},
+			Options: options.Index().SetName("policy_effective_from"),
+		},
+	})
+	return err
+}
+
+func (r *MongoScheduleRepository) CreateScheduledChange(ctx context.Context, change *ScheduledChange) error {
+	res, err := r.coll.InsertOne(ctx, change)
+	if err != nil {
+		return err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		change.ID = oid
+	}
+	return nil
+}
+
+// ListScheduledChanges lists every scheduled change of a user's policy, earliest first.
+func (r *MongoScheduleRepository) ListScheduledChanges(ctx context.Context, userID uuid.UUID, policyID primitive.ObjectID) ([]*ScheduledChange, error) {
+	filter := bson.M{"policy_id": policyID, "owner_id": userID.String()}
+	return r.find(ctx, filter, options.Find().SetSort(bson.D{{Key: "effective_from", Value: 1}}))
+}
+
+// ListOpenScheduledChanges lists a policy's changes that are waiting to be applied or reverted.
+func (r *MongoScheduleRepository) ListOpenScheduledChanges(ctx context.Context, policyID primitive.ObjectID) ([]*ScheduledChange, error) {
+	filter := bson.M{
+		"policy_id": policyID,
+		"status":    bson.M{"$in": bson.A{ScheduledChangeScheduled, ScheduledChangeApplied}},
+	}
+	return r.find(ctx, filter, nil)
+}
+
+// ListDueScheduledChanges lists changes, across all users, that should be applied or reverted
+// at or before now, in the order they fall due.
+func (r *MongoScheduleRepository) ListDueScheduledChanges(ctx context.Context, now time.Time) ([]*ScheduledChange, error) {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": ScheduledChangeScheduled, "effective_from": bson.M{"$lte": now}},
+		bson.M{"status": ScheduledChangeApplied, "effective_until": bson.M{"$lte": now}},
+	}}
+	return r.find(ctx, filter, options.Find().SetSort(bson.D{{Key: "effective_from", Value: 1}}))
+}
+
+// MarkScheduledChangeApplied records that a scheduled change was applied over previousScript.
+// It returns nil if the change is no longer scheduled.
+func (r *MongoScheduleRepository) MarkScheduledChangeApplied(ctx context.Context, id primitive.ObjectID, previousScript string, at time.Time) (*ScheduledChange, error) {
+	filter := bson.M{"_id": id, "status": ScheduledChangeScheduled}
+	update := bson.M{"$set": bson.M{"status": ScheduledChangeApplied, "previous_script": previousScript, "applied_at": at}}
+	return r.findOneAndUpdate(ctx, filter, update)
+}
+
+// MarkScheduledChangeReverted records that an applied change was reverted. It returns nil if
+// the change is no longer applied.
+func (r *MongoScheduleRepository) MarkScheduledChangeReverted(ctx context.Context, id primitive.ObjectID, at time.Time) (*ScheduledChange, error) {
+	filter := bson.M{"_id": id, "status": ScheduledChangeApplied}
+	update := bson.M{"$set": bson.M{"status": ScheduledChangeReverted, "reverted_at": at}}
+	return r.findOneAndUpdate(ctx, filter, update)
+}
+
+// MarkScheduledChangeFailed records why a change with status from could not be applied or reverted.
+func (r *MongoScheduleRepository) MarkScheduledChangeFailed(ctx context.Context, id primitive.ObjectID, from, reason string) (*ScheduledChange, error) {
+	filter := bson.M{"_id": id, "status": from}
+	update := bson.M{"$set": bson.M{"status": ScheduledChangeFailed, "error": reason}}
+	return r.findOneAndUpdate(ctx, filter, update)
+}
+
+// CancelScheduledChange cancels a change that has not been applied yet, returning nil if there is no such change.
+func (r *MongoScheduleRepository) CancelScheduledChange(ctx context.Context, userID uuid.UUID, policyID, id primitive.ObjectID) (*ScheduledChange, error) {
+	filter := bson.M{"_id": id, "policy_id": policyID, "owner_id": userID.String(), "status": ScheduledChangeScheduled}
+	update := bson.M{"$set": bson.M{"status": ScheduledChangeCancelled}}
+	return r.findOneAndUpdate(ctx, filter, update)
+}
+
+func (r *MongoScheduleRepository) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]*ScheduledChange, error) {
+	cur, err := r.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func(cur *mongo.Cursor, ctx context.Context) {
+		if err := cur.Close(ctx); err != nil {
+			log.Printf("failed to close cursor: %v\n", err)
+		}
+	}(cur, ctx)
+
+	changes := make([]*ScheduledChange, 0)
+	if err := cur.All(ctx, &changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (r *MongoScheduleRepository) findOneAndUpdate(ctx context.Context, filter bson.M, update bson.M) (*ScheduledChange, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var change ScheduledChange
+	if err := r.coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&change); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &change, nil
+}
@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, sub *WebhookSubscription) error
+	GetSubscription(ctx context.Context, id primitive.ObjectID) (*WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]*WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id primitive.ObjectID) (bool, error)
+	CreateDelivery(ctx context.Context, d *WebhookDelivery) error
+	ClaimDueDelivery(ctx context.Context, now time.Time, lease time.Duration) (*WebhookDelivery, error)
+	RecordDeliveryAttempt(ctx context.Context, id primitive.ObjectID, leasedUntil time.Time, attempt DeliveryAttempt, status string, nextAttemptAt *time.Time) (*WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, subscriptionID primitive.ObjectID, limit int) ([]*WebhookDelivery, error)
+}
+
+// MongoWebhookRepository stores webhook subscriptions and the deliveries made to them.
+type MongoWebhookRepository struct {
+	subscriptions *mongo.Collection
+	deliveries    *mongo.Collection
+}
+
+func NewMongoWebhookRepository(db *mongo.Database) *MongoWebhookRepository {
+	return &MongoWebhookRepository{
+		subscriptions: db.Collection("webhook_subscriptions"),
+		deliveries:    db.Collection("webhook_deliveries"),
+	}
+}
+
+// EnsureIndexes creates the indexes that the dispatcher relies on, including the one that
+// allows at most one delivery of an event to each subscription.
+func (r *MongoWebhookRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.deliveries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "subscription_id", Value: 1}, {Key: "event_seq", Value: 1}},
+			Options: options.Index().SetName("subscription_event").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}},
+			Options: options.Index().SetName("status_next_attempt"),
+		},
+		{
+			Keys:    bson.D{{Key: "subscription_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("subscription_created"),
+		},
+	})
+	return err
+}
+
+// EnsureRetention makes the database delete deliveries once they finished, by succeeding or
+// running out of attempts, longer than retention ago. Pending deliveries are kept.
+func (r *MongoWebhookRepository) EnsureRetention(ctx context.Context, retention time.Duration) error {
+	return ensureTTLIndex(ctx, r.deliveries, "finished_ttl", "finished_at", retention)
+}
+
+func (r *MongoWebhookRepository) CreateSubscription(ctx context.Context, sub *WebhookSubscription) error {
+	res, err := r.subscriptions.InsertOne(ctx, sub)
+	if err != nil {
+		return err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		sub.ID = oid
+	}
+	return nil
+}
+
+func (r *MongoWebhookRepository) GetSubscription(ctx context.Context, id primitive.ObjectID) (*WebhookSubscription, error) {
+	var sub WebhookSubscription
+	err := r.subscriptions.FindOne(ctx, bson.M{"_id": id}).Decode(&sub)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// ListSubscriptions lists every subscription, oldest first.
+func (r *MongoWebhookRepository) ListSubscriptions(ctx context.Context) ([]*WebhookSubscription, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cur, err := r.subscriptions.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func(cur *mongo.Cursor, ctx context.Context) {
+		if err := cur.Close(ctx); err != nil {
+			log.Printf("failed to close cursor: %v\n", err)
+		}
+	}(cur, ctx)
+
+	subs := make([]*WebhookSubscription, 0)
+	if err := cur.All(ctx, &subs); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// DeleteSubscription removes a subscription. Its pending deliveries fail when next attempted.
+func (r *MongoWebhookRepository) DeleteSubscription(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	res, err := r.subscriptions.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
+
+// CreateDelivery stores a delivery. A delivery of the same event to the same subscription may
+// already exist if an earlier fan-out was interrupted, in which case it is left as it is.
+func (r *MongoWebhookRepository) CreateDelivery(ctx context.Context, d *WebhookDelivery) error {
+	res, err := r.deliveries.InsertOne(ctx, d)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		d.ID = oid
+	}
+	return nil
+}
+
+// ClaimDueDelivery takes the pending delivery that has been due the longest, pushing its next
+// attempt back by lease so that no other dispatcher takes it meanwhile. The returned delivery's
+// NextAttemptAt is when the lease runs out. It returns nil if no delivery is due.
+func (r *MongoWebhookRepository) ClaimDueDelivery(ctx context.Context, now time.Time, lease time.Duration) (*WebhookDelivery, error) {
+	filter := bson.M{"status": DeliveryPending, "next_attempt_at": bson.M{"$lte": now}}
+	update := bson.M{"$set": bson.M{"next_attempt_at": now.Add(lease)}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var d WebhookDelivery
+	err := r.deliveries.FindOneAndUpdate(ctx, filter, update, opts).Decode(&d)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// RecordDeliveryAttempt appends an attempt to a delivery and moves it to status, to be
+// attempted again at nextAttemptAt if it is still pending. It only applies while the delivery
+// is still held under the lease that runs out at leasedUntil, as ClaimDueDelivery returned it,
+// and returns nil if the lease ran out and the delivery was claimed again.
+func (r *MongoWebhookRepository) RecordDeliveryAttempt(ctx context.Context, id primitive.ObjectID, leasedUntil time.Time, attempt DeliveryAttempt, status string, nextAttemptAt *time.Time) (*WebhookDelivery, error) {
+	update := bson.M{
+		"$push": bson.M{"attempts": attempt},
+		"$set":  bson.M{"status": status},
+	}
+	if nextAttemptAt != nil {
+		update["$set"].(bson.M)["next_attempt_at"] = *nextAttemptAt
+	} else {
+		update["$unset"] = bson.M{"next_attempt_at": ""}
+	}
+	if status != DeliveryPending {
+		update["$set"].(bson.M)["finished_at"] = time.Now().UTC()
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var d WebhookDelivery
+	filter := bson.M{"_id": id, "status": DeliveryPending, "next_attempt_at": leasedUntil}
+	err := r.deliveries.FindOneAndUpdate(ctx, filter, update, opts).Decode(&d)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// ListDeliveries lists up to limit of a subscription's deliveries, most recent first.
+func (r *MongoWebhookRepository) ListDeliveries(ctx context.Context, subscriptionID primitive.ObjectID, limit int) ([]*WebhookDelivery, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit))
+	cur, err := r.deliveries.Find(ctx, bson.M{"subscription_id": subscriptionID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func(cur *mongo.Cursor, ctx context.Context) {
+		if err := cur.Close(ctx); err != nil {
+			log.Printf("failed to close cursor: %v\n", err)
+		}
+	}(cur, ctx)
+
+	deliveries := make([]*WebhookDelivery, 0)
+	if err := cur.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
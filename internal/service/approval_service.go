@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/convert"
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrNotApprover         = errors.New("user is not an approver for this account")
+	ErrSelfApproval        = errors.New("changes cannot be approved by the user who requested them")
+	ErrInvalidApprover     = errors.New("approver IDs must be UUIDs other than the account's own")
+	ErrApproversLocked     = errors.New("approvers can only be changed by one of the account's current approvers")
+	ErrPendingApprovals    = errors.New("approvals cannot be turned off while change requests are pending")
+	ErrChangeRequestClosed = errors.New("change request is no longer pending")
+	ErrStaleChangeRequest  = errors.New("policy has changed since the change request was made")
+)
+
+// ApprovalService runs the two-person approval workflow. Accounts opt in by naming approvers;
+// from then on, changes to the published script of a live policy become change requests
+// that one of those approvers must accept before they take effect.
+type ApprovalService struct {
+	approvals repository.ApprovalRepository
+	schedules repository.ScheduleRepository
+	policies  *PolicyService
+}
+
+// ApprovalServiceInterface defines the contract for the approval workflow.
+type ApprovalServiceInterface interface {
+	GetApprovers(ctx context.Context, ownerID uuid.UUID) ([]string, error)
+	SetApprovers(ctx context.Context, userID, ownerID uuid.UUID, approverIDs []string) ([]string, error)
+	ListChangeRequests(ctx context.Context, userID uuid.UUID, status string) ([]*repository.ChangeRequest, error)
+	GetChangeRequest(ctx context.Context, userID uuid.UUID, id string) (*repository.ChangeRequest, error)
+	ApproveChangeRequest(ctx context.Context, userID uuid.UUID, id string) (*repository.ChangeRequest, error)
+	RejectChangeRequest(ctx context.Context, userID uuid.UUID, id, comment string) (*repository.ChangeRequest, error)
+	CommentOnChangeRequest(ctx context.Context, userID uuid.UUID, id, body string) (*repository.ChangeRequest, error)
+}
+
+// NewApprovalService creates a new ApprovalService
+func NewApprovalService(approvals repository.ApprovalRepository, schedules repository.ScheduleRepository, policies *PolicyService) *ApprovalService {
+	return &ApprovalService{approvals: approvals, schedules: schedules, policies: policies}
+}
+
+// GetApprovers lists the users who may approve changes to ownerID's policies.
+func (s *ApprovalService) GetApprovers(ctx context.Context, ownerID uuid.UUID) ([]string, error) {
+	return s.approvals.GetApprovers(ctx, ownerID.String())
+}
+
+// SetApprovers replaces ownerID's approvers on behalf of userID. Owners may only opt in, naming
+// the first approvers; from then on only a current approver may change the list, so that an
+// owner cannot sidestep review by naming themselves or turning approvals off. An empty list
+// turns approvals off, which is refused while change requests are pending.
+func (s *ApprovalService) SetApprovers(ctx context.Context, userID, ownerID uuid.UUID, approverIDs []string) ([]string, error) {
+	current, err := s.approvals.GetApprovers(ctx, ownerID.String())
+	if err != nil {
+		return nil, err
+	}
+	if userID == ownerID {
+		if len(current) > 0 {
+			return nil, ErrApproversLocked
+		}
+	} else if !slices.Contains(current, userID.String()) {
+		return nil, ErrApproversLocked
+	}
+
+	normalized := make([]string, 0, len(approverIDs))
+	for _, approverID := range approverIDs {
+		parsed, err := uuid.Parse(strings.TrimSpace(approverID))
+		if err != nil || parsed == ownerID {
+			return nil, ErrInvalidApprover
+		}
+		if !slices.Contains(normalized, parsed.String()) {
+			normalized = append(normalized, parsed.String())
+		}
+	}
+
+	if len(normalized) == 0 && len(current) > 0 {
+		pending, err := s.approvals.ListChangeRequests(ctx, []string{ownerID.String()}, repository.ChangeRequestPending)
+		if err != nil {
+			return nil, err
+		}
+		if len(pending) > 0 {
+			return nil, ErrPendingApprovals
+		}
+	}
+
+	if err := s.approvals.SetApprovers(ctx, ownerID.String(), normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// ListChangeRequests lists the change requests on the user's own policies and on the policies
+// of accounts they approve for, newest first. An empty status lists every status.
+func (s *ApprovalService) ListChangeRequests(ctx context.Context, userID uuid.UUID, status string) ([]*repository.ChangeRequest, error) {
+	owners, err := s.approvals.ListOwnersForApprover(ctx, userID.String())
+	if err != nil {
+		return nil, err
+	}
+	return s.approvals.ListChangeRequests(ctx, append(owners, userID.String()), status)
+}
+
+// GetChangeRequest retrieves a change request visible to the user, or nil if there is none.
+func (s *ApprovalService) GetChangeRequest(ctx context.Context, userID uuid.UUID, id string) (*repository.ChangeRequest, error) {
+	cr, _, err := s.loadChangeRequest(ctx, userID, id)
+	return cr, err
+}
+
+// ApproveChangeRequest applies a pending change request to its policy. Only an approver other
+// than the requester may approve, and only while the policy still publishes the script, or
+// has the dayparts, the change was made against. A change request with a window is scheduled instead of applied.
+func (s *ApprovalService) ApproveChangeRequest(ctx context.Context, userID uuid.UUID, id string) (*repository.ChangeRequest, error) {
+	cr, isApprover, err := s.loadChangeRequest(ctx, userID, id)
+	if err != nil || cr == nil {
+		return nil, err
+	}
+	if cr.RequestedBy == userID.String() {
+		return nil, ErrSelfApproval
+	}
+	if !isApprover {
+		return nil, ErrNotApprover
+	}
+	if cr.Status != repository.ChangeRequestPending {
+		return nil, ErrChangeRequestClosed
+	}
+
+	ownerID, err := uuid.Parse(cr.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	var approved *repository.ChangeRequest
+	err = s.policies.WithTransaction(ctx, func(ctx context.Context) error {
+		decided, err := s.approvals.DecideChangeRequest(ctx, cr.ID, repository.ChangeRequestApproved, userID.String(), nil)
+		if err != nil {
+			return err
+		}
+		if decided == nil {
+			return ErrChangeRequestClosed
+		}
+		approved = decided
+
+		if cr.EffectiveFrom != nil {
+			change := newScheduledChange(cr.PolicyID, cr.OwnerID, cr.ProposedScript, *cr.EffectiveFrom, cr.EffectiveUntil)
+			return s.schedules.CreateScheduledChange(ctx, change)
+		}
+
+		var policy *repository.Policy
+		if cr.ProposedDayparts != nil {
+			policy, err = s.policies.ApplyDayparts(ctx, ownerID, cr.PolicyID, cr.BaseDayparts, *cr.ProposedDayparts)
+		} else {
+			policy, err = s.policies.ApplyScript(ctx, ownerID, cr.PolicyID, cr.BaseScript, cr.ProposedScript)
+		}
+		if err != nil {
+			return err
+		}
+		if policy == nil {
+			return ErrStaleChangeRequest
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return approved, nil
+}
+
+// RejectChangeRequest closes a pending change request without applying it. Approvers reject
+// changes; owners may reject their own to withdraw them. A non-empty comment is recorded with
+// the decision.
+func (s *ApprovalService) RejectChangeRequest(ctx context.Context, userID uuid.UUID, id, comment string) (*repository.ChangeRequest, error) {
+	cr, _, err := s.loadChangeRequest(ctx, userID, id)
+	if err != nil || cr == nil {
+		return nil, err
+	}
+	if cr.Status != repository.ChangeRequestPending {
+		return nil, ErrChangeRequestClosed
+	}
+
+	var note *repository.ChangeComment
+	if comment = strings.TrimSpace(comment); comment != "" {
+		note = &repository.ChangeComment{AuthorID: userID.String(), Body: comment, CreatedAt: time.Now().UTC()}
+	}
+
+	rejected, err := s.approvals.DecideChangeRequest(ctx, cr.ID, repository.ChangeRequestRejected, userID.String(), note)
+	if err != nil {
+		return nil, err
+	}
+	if rejected == nil {
+		return nil, ErrChangeRequestClosed
+	}
+	return rejected, nil
+}
+
+// CommentOnChangeRequest adds a comment to a change request.
+func (s *ApprovalService) CommentOnChangeRequest(ctx context.Context, userID uuid.UUID, id, body string) (*repository.ChangeRequest, error) {
+	cr, _, err := s.loadChangeRequest(ctx, userID, id)
+	if err != nil || cr == nil {
+		return nil, err
+	}
+
+	comment := repository.ChangeComment{AuthorID: userID.String(), Body: strings.TrimSpace(body), CreatedAt: time.Now().UTC()}
+	return s.approvals.AddChangeComment(ctx, cr.ID, comment)
+}
+
+// loadChangeRequest fetches a change request and reports whether the user approves for its
+// owner. Requests the user can neither own nor approve are reported as missing.
+func (s *ApprovalService) loadChangeRequest(ctx context.Context, userID uuid.UUID, id string) (*repository.ChangeRequest, bool, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	cr, err := s.approvals.GetChangeRequest(ctx, objID)
+	if err != nil || cr == nil {
+		return nil, false, err
+	}
+
+	approvers, err := s.approvals.GetApprovers(ctx, cr.OwnerID)
+	if err != nil {
+		return nil, false, err
+	}
+	isApprover := slices.Contains(approvers, userID.String())
+	if !isApprover && cr.OwnerID != userID.String() {
+		return nil, false, nil
+	}
+	return cr, isApprover, nil
+}
+
+// requiresApproval reports whether the account has opted in to approvals.
+func (s *PolicyService) requiresApproval(ctx context.Context, userID uuid.UUID) (bool, error) {
+	approvers, err := s.approvals.GetApprovers(ctx, userID.String())
+	if err != nil {
+		return false, err
+	}
+	return len(approvers) > 0, nil
+}
+
+// needsApproval reports whether changing the published script of a policy with status needs
+// approval. Paused policies count as live, so pausing cannot be used to sidestep review.
+func needsApproval(status string) bool {
+	return status == repository.StatusActive || status == repository.StatusPaused
+}
+
+// submitChangeRequest moves a live policy's draft into a new change request, diffing it
+// against the published script. A non-nil from schedules the change for that window once approved.
+func (s *PolicyService) submitChangeRequest(ctx context.Context, userID uuid.UUID, existing *repository.Policy, from, until *time.Time) (*repository.ChangeRequest, error) {
+	draft := *existing.DraftScript
+	cr := &repository.ChangeRequest{
+		PolicyID:       existing.ID,
+		OwnerID:        userID.String(),
+		RequestedBy:    userID.String(),
+		BaseScript:     existing.Script,
+		ProposedScript: draft,
+		Diff:           convert.DiffTrees(s.converter.ScriptToTree(existing.Script), s.converter.ScriptToTree(draft)),
+		Status:         repository.ChangeRequestPending,
+		Comments:       []repository.ChangeComment{},
+		CreatedAt:      time.Now().UTC(),
+		EffectiveFrom:  from,
+		EffectiveUntil: until,
+	}
+
+	err := s.WithTransaction(ctx, func(ctx context.Context) error {
+		cr.ID = primitive.NilObjectID
+		taken, err := s.TakeDraft(ctx, userID, existing.ID, draft)
+		if err != nil {
+			return err
+		}
+		if taken == nil {
+			return fmt.Errorf("%w: policy changed while publishing", ErrInvalidTransition)
+		}
+		return s.approvals.CreateChangeRequest(ctx, cr)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/google/uuid"
+)
+
+// memoryApprovals keeps approvers and pending change requests in memory.
+type memoryApprovals struct {
+	repository.ApprovalRepository
+	approvers map[string][]string
+	pending   map[string]int
+}
+
+func (m *memoryApprovals) GetApprovers(_ context.Context, ownerID string) ([]string, error) {
+	return append([]string{}, m.approvers[ownerID]...), nil
+}
+
+func (m *memoryApprovals) SetApprovers(_ context.Context, ownerID string, approverIDs []string) error {
+	m.approvers[ownerID] = approverIDs
+	return nil
+}
+
+func (m *memoryApprovals) ListChangeRequests(_ context.Context, ownerIDs []string, _ string) ([]*repository.ChangeRequest, error) {
+	changeRequests := make([]*repository.ChangeRequest, 0)
+	for _, ownerID := range ownerIDs {
+		for range m.pending[ownerID] {
+			changeRequests = append(changeRequests, &repository.ChangeRequest{OwnerID: ownerID})
+		}
+	}
+	return changeRequests, nil
+}
+
+func TestSetApproversIsNotSelfService(t *testing.T) {
+	ctx := context.Background()
+	owner, approver, other := uuid.New(), uuid.New(), uuid.New()
+	approvals := &memoryApprovals{approvers: map[string][]string{}, pending: map[string]int{}}
+	s := NewApprovalService(approvals, nil, nil)
+
+	if _, err := s.SetApprovers(ctx, owner, owner, []string{owner.String()}); !errors.Is(err, ErrInvalidApprover) {
+		t.Fatalf("expected the owner to be refused as their own approver, got %v", err)
+	}
+	if _, err := s.SetApprovers(ctx, owner, owner, []string{approver.String()}); err != nil {
+		t.Fatalf("expected the owner to opt in, got %v", err)
+	}
+	if _, err := s.SetApprovers(ctx, owner, owner, []string{}); !errors.Is(err, ErrApproversLocked) {
+		t.Fatalf("expected the owner to be locked out once opted in, got %v", err)
+	}
+	if _, err := s.SetApprovers(ctx, other, owner, []string{other.String()}); !errors.Is(err, ErrApproversLocked) {
+		t.Fatalf("expected a stranger to be locked out, got %v", err)
+	}
+
+	approvals.pending[owner.String()] = 1
+	if _, err := s.SetApprovers(ctx, approver, owner, []string{}); !errors.Is(err, ErrPendingApprovals) {
+		t.Fatalf("expected approvals to stay on while changes are pending, got %v", err)
+	}
+	if _, err := s.SetApprovers(ctx, approver, owner, []string{approver.String(), other.String()}); err != nil {
+		t.Fatalf("expected an approver to change the list, got %v", err)
+	}
+
+	approvals.pending[owner.String()] = 0
+	if _, err := s.SetApprovers(ctx, approver, owner, []string{}); err != nil {
+		t.Fatalf("expected an approver to turn approvals off, got %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrMarketplaceMismatch = errors.New("policy belongs to a different marketplace")
+	ErrPolicyAssigned      = errors.New("policy cannot be deleted while it is assigned; reassign or remove its assignments first")
+)
+
+// SkipPolicyMissing is the reason given for passing over an assignment whose policy is gone.
+// Assignments whose policy is not active are passed over with the policy's status as the reason.
+const SkipPolicyMissing = "missing"
+
+// assignmentPrecedence orders entity types from most to least specific.
+var assignmentPrecedence = []string{repository.EntityTarget, repository.EntityAdGroup, repository.EntityCampaign}
+
+// ResolvedAssignment is the policy in effect for an entity and the assignment it came from,
+// which is nil when the marketplace's default policy applies. Skipped lists the more specific
+// assignments that were passed over because their policy could not be used, and Policy is nil
+// when nothing could be used at all.
+type ResolvedAssignment struct {
+	Assignment *repository.PolicyAssignment `json:"assignment"`
+	Policy     *repository.Policy           `json:"policy"`
+	Skipped    []SkippedAssignment          `json:"skipped"`
+}
+
+// SkippedAssignment is an assignment passed over while resolving, and why.
+type SkippedAssignment struct {
+	Assignment *repository.PolicyAssignment `json:"assignment"`
+	Reason     string                       `json:"reason"`
+}
+
+// AssignmentService links policies to the campaigns, ad groups and targets they bid on.
+type AssignmentService struct {
+	assignments repository.AssignmentRepository
+	policies    *PolicyService
+}
+
+// AssignmentServiceInterface defines the contract for policy assignments.
+type AssignmentServiceInterface interface {
+	CreateAssignment(ctx context.Context, userID uuid.UUID, marketplace, entityType, entityID, policyID string) (*repository.PolicyAssignment, error)
+	GetAssignment(ctx context.Context, userID uuid.UUID, id string) (*repository.PolicyAssignment, error)
+	ListAssignments(ctx context.Context, userID uuid.UUID, filter repository.AssignmentFilter) ([]*repository.PolicyAssignment, error)
+	UpdateAssignment(ctx context.Context, userID uuid.UUID, id, policyID string) (*repository.PolicyAssignment, error)
+	DeleteAssignment(ctx context.Context, userID uuid.UUID, id string) (bool, error)
+	ResolveAssignment(ctx context.Context, userID uuid.UUID, marketplace string, entityIDs map[string]string) (*ResolvedAssignment, error)
+}
+
+// NewAssignmentService creates a new AssignmentService
+func NewAssignmentService(assignments repository.AssignmentRepository, policies *PolicyService) *AssignmentService {
+	return &AssignmentService{assignments: assignments, policies: policies}
+}
+
+// CreateAssignment assigns a policy to an entity. The policy must belong to the entity's marketplace.
+func (s *AssignmentService) CreateAssignment(ctx context.Context, userID uuid.UUID, marketplace, entityType, entityID, policyID string) (*repository.PolicyAssignment, error) {
+	policy, err := s.assignablePolicy(ctx, userID, marketplace, policyID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	a := &repository.PolicyAssignment{
+		UserID:      userID.String(),
+		Marketplace: marketplace,
+		EntityType:  entityType,
+		EntityID:    entityID,
+		PolicyID:    policy.ID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.assignments.CreateAssignment(ctx, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (s *AssignmentService) GetAssignment(ctx context.Context, userID uuid.UUID, id string) (*repository.PolicyAssignment, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+	return s.assignments.GetAssignment(ctx, userID, objID)
+}
+
+func (s *AssignmentService) ListAssignments(ctx context.Context, userID uuid.UUID, filter repository.AssignmentFilter) ([]*repository.PolicyAssignment, error) {
+	return s.assignments.ListAssignments(ctx, userID, filter)
+}
+
+// UpdateAssignment points an assignment at another policy from the same marketplace.
+func (s *AssignmentService) UpdateAssignment(ctx context.Context, userID uuid.UUID, id, policyID string) (*repository.PolicyAssignment, error) {
+	existing, err := s.GetAssignment(ctx, userID, id)
+	if err != nil || existing == nil {
+		return nil, err
+	}
+
+	policy, err := s.assignablePolicy(ctx, userID, existing.Marketplace, policyID)
+	if err != nil {
+		return nil, err
+	}
+	return s.assignments.UpdateAssignmentPolicy(ctx, userID, existing.ID, policy.ID)
+}
+
+func (s *AssignmentService) DeleteAssignment(ctx context.Context, userID uuid.UUID, id string) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, nil
+	}
+	return s.assignments.DeleteAssignment(ctx, userID, objID)
+}
+
+// ResolveAssignment returns the policy in effect for an entity, given the IDs of the entity and
+// its parents keyed by entity type. The most specific assignment wins: target, then ad group,
+// then campaign. Assignments whose policy is gone or not active are passed over and reported
+// as skipped, and when no assignment applies the marketplace's active default policy is used,
+// without an assignment. It returns nil if there are no assignments and no default policy.
+func (s *AssignmentService) ResolveAssignment(ctx context.Context, userID uuid.UUID, marketplace string, entityIDs map[string]string) (*ResolvedAssignment, error) {
+	entities := make([]repository.AssignmentEntity, 0, len(assignmentPrecedence))
+	for _, entityType := range assignmentPrecedence {
+		if id := entityIDs[entityType]; id != "" {
+			entities = append(entities, repository.AssignmentEntity{Type: entityType, ID: id})
+		}
+	}
+
+	assignments, err := s.assignments.FindAssignments(ctx, userID, marketplace, entities)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := &ResolvedAssignment{Skipped: []SkippedAssignment{}}
+	for _, entity := range entities {
+		for _, a := range assignments {
+			if a.EntityType != entity.Type || a.EntityID != entity.ID {
+				continue
+			}
+			policy, err := s.policies.GetPolicy(ctx, userID, a.PolicyID.Hex())
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case policy == nil:
+				resolved.Skipped = append(resolved.Skipped, SkippedAssignment{Assignment: a, Reason: SkipPolicyMissing})
+			case policy.Status != repository.StatusActive:
+				resolved.Skipped = append(resolved.Skipped, SkippedAssignment{Assignment: a, Reason: policy.Status})
+			default:
+				resolved.Assignment, resolved.Policy = a, policy
+				return resolved, nil
+			}
+		}
+	}
+
+	fallback, err := s.policies.GetDefaultPolicy(ctx, userID, marketplace)
+	if err != nil {
+		return nil, err
+	}
+	if fallback != nil && fallback.Status == repository.StatusActive {
+		resolved.Policy = fallback
+	} else if len(resolved.Skipped) == 0 {
+		return nil, nil
+	}
+	return resolved, nil
+}
+
+// assignablePolicy loads the policy to assign, checking that it exists and belongs to marketplace.
+func (s *AssignmentService) assignablePolicy(ctx context.Context, userID uuid.UUID, marketplace, policyID string) (*repository.Policy, error) {
+	policy, err := s.policies.GetPolicy(ctx, userID, policyID)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return nil, ErrPolicyNotFound
+	}
+	if policy.Marketplace != marketplace {
+		return nil, fmt.Errorf("%w: policy is for %s, not %s", ErrMarketplaceMismatch, policy.Marketplace, marketplace)
+	}
+	return policy, nil
+}
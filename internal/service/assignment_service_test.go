@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/cache"
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryAssignments holds assignments in memory.
+type memoryAssignments struct {
+	repository.AssignmentRepository
+	assignments []*repository.PolicyAssignment
+}
+
+func (m *memoryAssignments) FindAssignments(context.Context, uuid.UUID, string, []repository.AssignmentEntity) ([]*repository.PolicyAssignment, error) {
+	return m.assignments, nil
+}
+
+// memoryPolicies holds policies in memory, keyed by ID.
+type memoryPolicies struct {
+	repository.PolicyRepository
+	policies map[primitive.ObjectID]*repository.Policy
+}
+
+func (m *memoryPolicies) GetPolicy(_ context.Context, _ uuid.UUID, id primitive.ObjectID) (*repository.Policy, error) {
+	return m.policies[id], nil
+}
+
+func (m *memoryPolicies) GetDefaultPolicy(context.Context, uuid.UUID, string) (*repository.Policy, error) {
+	return nil, nil
+}
+
+func TestResolveAssignmentReportsSkipped(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	paused := &repository.Policy{ID: primitive.NewObjectID(), Status: repository.StatusPaused}
+	active := &repository.Policy{ID: primitive.NewObjectID(), Status: repository.StatusActive}
+
+	target := &repository.PolicyAssignment{EntityType: repository.EntityTarget, EntityID: "t", PolicyID: primitive.NewObjectID()}
+	adGroup := &repository.PolicyAssignment{EntityType: repository.EntityAdGroup, EntityID: "a", PolicyID: paused.ID}
+	campaign := &repository.PolicyAssignment{EntityType: repository.EntityCampaign, EntityID: "c", PolicyID: active.ID}
+
+	policies := &memoryPolicies{policies: map[primitive.ObjectID]*repository.Policy{paused.ID: paused, active.ID: active}}
+	policyService := &PolicyService{repo: policies, cache: cache.NewMemoryCache(100)}
+	s := NewAssignmentService(&memoryAssignments{assignments: []*repository.PolicyAssignment{target, adGroup, campaign}}, policyService)
+
+	entityIDs := map[string]string{repository.EntityTarget: "t", repository.EntityAdGroup: "a", repository.EntityCampaign: "c"}
+	resolved, err := s.ResolveAssignment(ctx, userID, "UK", entityIDs)
+	if err != nil {
+		t.Fatalf("expected to resolve, got %v", err)
+	}
+	if resolved.Policy == nil || resolved.Policy.ID != active.ID {
+		t.Fatalf("expected the campaign's policy, got %v", resolved.Policy)
+	}
+	if len(resolved.Skipped) != 2 {
+		t.Fatalf("expected 2 skipped assignments, got %d", len(resolved.Skipped))
+	}
+	if resolved.Skipped[0].Reason != SkipPolicyMissing || resolved.Skipped[1].Reason != repository.StatusPaused {
+		t.Fatalf("expected reasons [missing paused], got [%s %s]", resolved.Skipped[0].Reason, resolved.Skipped[1].Reason)
+	}
+
+	delete(entityIDs, repository.EntityCampaign)
+	resolved, err = s.ResolveAssignment(ctx, userID, "UK", entityIDs)
+	if err != nil || resolved == nil {
+		t.Fatalf("expected skipped assignments without a policy, got %v (%v)", resolved, err)
+	}
+	if resolved.Policy != nil || len(resolved.Skipped) != 2 {
+		t.Fatalf("expected no policy and 2 skipped assignments, got %v and %d", resolved.Policy, len(resolved.Skipped))
+	}
+}
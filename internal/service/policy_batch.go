@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type BatchOperationType string
+
+const (
+	BatchOperationCreate BatchOperationType = "create"
+	BatchOperationUpdate BatchOperationType = "update"
+	BatchOperationDelete BatchOperationType = "delete"
+)
+
+var (
+	ErrPolicyNotFound = errors.New("policy not found")
+	ErrBatchAborted   = errors.New("batch aborted")
+)
+
+// BatchOperation is a single create, update or delete within a batch.
+// ID is used by update and delete, Marketplace and Status only by create, and Fields by create and update.
+type BatchOperation struct {
+	Type        BatchOperationType
+	ID          string
+	Marketplace string
+	Status      string
+	Fields      PolicyFields
+}
+
+// BatchResult is the outcome of the operation at Index in the submitted batch.
+type BatchResult struct {
+	Index   int                `json:"index"`
+	Op      BatchOperationType `json:"op"`
+	ID      string             `json:"id,omitempty"`
+	Success bool               `json:"success"`
+	Error   string             `json:"error,omitempty"`
+	Policy  *repository.Policy `json:"policy,omitempty"`
+}
+
+// BatchPolicies applies ops in order. In atomic mode all operations run in a single
+// transaction and the first failure rolls back the batch, returning ErrBatchAborted
+// alongside the results; otherwise each operation is applied independently.
+func (s *PolicyService) BatchPolicies(ctx context.Context, userID uuid.UUID, ops []BatchOperation, atomic bool) ([]BatchResult, error) {
+	if !atomic {
+		results := make([]BatchResult, len(ops))
+		for i, op := range ops {
+			policy, err := s.applyBatchOperation(ctx, userID, op)
+			results[i] = newBatchResult(i, op, policy, err)
+		}
+		s.invalidateBatch(ctx, userID, ops, results)
+		return results, nil
+	}
+
+	var results []BatchResult
+	var failure error
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		// The transaction may be retried, so start from a clean slate every time
+		results = make([]BatchResult, len(ops))
+		failure = nil
+		for i, op := range ops {
+			policy, err := s.applyBatchOperation(ctx, userID, op)
+			results[i] = newBatchResult(i, op, policy, err)
+			if err != nil {
+				failure = err
+				return err
+			}
+		}
+		return nil
+	})
+	if failure != nil {
+		// Nothing was committed, so strip the results of operations that had succeeded
+		for i := range results {
+			if results[i].Success {
+				results[i] = BatchResult{Index: i, Op: results[i].Op, ID: results[i].ID, Error: "rolled back"}
+			}
+		}
+		return results, fmt.Errorf("%w: %w", ErrBatchAborted, failure)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidateBatch(ctx, userID, ops, results)
+	return results, nil
+}
+
+func (s *PolicyService) applyBatchOperation(ctx context.Context, userID uuid.UUID, op BatchOperation) (*repository.Policy, error) {
+	switch op.Type {
+	case BatchOperationCreate:
+		p := s.newPolicy(userID, op.Marketplace, op.Status, op.Fields)
+		if err := s.repo.CreatePolicy(ctx, p); err != nil {
+			return nil, err
+		}
+		return p, nil
+
+	case BatchOperationUpdate:
+		objID, err := primitive.ObjectIDFromHex(op.ID)
+		if err != nil {
+			return nil, ErrPolicyNotFound
+		}
+		existing, err := s.repo.GetPolicy(ctx, userID, objID)
+		if err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			return nil, ErrPolicyNotFound
+		}
+		if existing.Status == repository.StatusArchived {
+			return nil, ErrPolicyArchived
+		}
+		return s.updatePolicy(ctx, userID, existing, op.Fields)
+
+	case BatchOperationDelete:
+		if err := s.checkUnassigned(ctx, userID, op.ID); err != nil {
+			return nil, err
+		}
+		policy, err := s.repo.DeletePolicy(ctx, userID, op.ID)
+		if err != nil {
+			return nil, err
+		}
+		if policy == nil {
+			return nil, ErrPolicyNotFound
+		}
+		return policy, nil
+
+	default:
+		return nil, fmt.Errorf("unknown operation %q", op.Type)
+	}
+}
+
+// invalidateBatch drops the cached lookups of the policies that successful operations changed,
+// in one round trip, along with the user's cached listings.
+func (s *PolicyService) invalidateBatch(ctx context.Context, userID uuid.UUID, ops []BatchOperation, results []BatchResult) {
+	ids := make([]string, 0, len(ops))
+	for i, op := range ops {
+		if results[i].Success && op.Type != BatchOperationCreate {
+			ids = append(ids, results[i].ID)
+		}
+	}
+	s.invalidatePolicy(ctx, userID.String(), ids...)
+}
+
+func newBatchResult(index int, op BatchOperation, policy *repository.Policy, err error) BatchResult {
+	result := BatchResult{Index: index, Op: op.Type, ID: op.ID}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Success = true
+	result.ID = policy.ID.Hex()
+	if op.Type != BatchOperationDelete {
+		result.Policy = policy
+	}
+	return result
+}
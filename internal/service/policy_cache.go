@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/cache"
+	"github.com/LittleAksMax/bids-policy-service/internal/db"
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CacheTTLs sets how long policy lookups are cached. NotFound applies to lookups of policies
+// that do not exist and is usually much shorter than Policy. List applies to policy listings
+// and must stay below listGenerationTTL. Stale applies to the last-known copies of policies and
+// listings, which are only served while the database is unavailable; it is usually hours rather
+// than minutes, and must stay below listGenerationTTL too. Changes drop stale copies along with
+// the entries they back, so an outage never brings back a policy as it was before a change.
+type CacheTTLs struct {
+	Policy   time.Duration
+	NotFound time.Duration
+	List     time.Duration
+	Stale    time.Duration
+}
+
+// listGenerationTTL keeps a user's list generation alive well past any list cached under it,
+// so that a generation restarting from 0 after expiring cannot revive an old list.
+const listGenerationTTL = 24 * time.Hour
+
+// cachedPolicy is the cache entry for a policy lookup. NotFound records that there was no
+// such policy. Delta is how long the lookup took, which scales how early it is refreshed.
+type cachedPolicy struct {
+	Policy   *repository.Policy `json:"policy,omitempty"`
+	NotFound bool               `json:"not_found,omitempty"`
+	Delta    time.Duration      `json:"delta"`
+}
+
+// staleCopy is the last-known value of a cache entry and when it was cached.
+type staleCopy[T any] struct {
+	Value    T         `json:"value"`
+	CachedAt time.Time `json:"cached_at"`
+}
+
+// cachedPolicy reads the cache entry for a policy lookup, reporting whether there was a usable one.
+func (s *PolicyService) cachedPolicy(ctx context.Context, cacheKey string) (*cachedPolicy, time.Time, bool) {
+	entry, expiresAt, err := cache.GetJSON[cachedPolicy](ctx, s.cache, cacheKey)
+	if err != nil || !expiresAt.After(time.Now()) {
+		return nil, time.Time{}, false
+	}
+	// Entries in any other shape, such as a bare policy, are treated as misses
+	if entry.Policy == nil && !entry.NotFound {
+		return nil, time.Time{}, false
+	}
+	return entry, expiresAt, true
+}
+
+// loadPolicy reads a policy from the database and caches the result, including its absence.
+func (s *PolicyService) loadPolicy(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, cacheKey string) (*repository.Policy, error) {
+	start := time.Now()
+	policy, err := s.repo.GetPolicy(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := cachedPolicy{Policy: policy, NotFound: policy == nil, Delta: time.Since(start)}
+	ttl := s.ttls.Policy
+	if entry.NotFound {
+		ttl = s.ttls.NotFound
+	}
+	if ttl > 0 {
+		_ = cache.SetJSON(ctx, s.cache, cacheKey, entry, ttl)
+	}
+	if policy != nil {
+		s.keepStale(ctx, cacheKey, policy)
+	}
+	return policy, nil
+}
+
+// keepStale stores value as the last-known copy of the cache entry at key.
+func (s *PolicyService) keepStale(ctx context.Context, key string, value any) {
+	if s.ttls.Stale <= 0 {
+		return
+	}
+	_ = cache.SetJSON(ctx, s.cache, key+":stale", staleCopy[any]{Value: value, CachedAt: time.Now()}, s.ttls.Stale)
+}
+
+// readStale reads the last-known copy of the cache entry at key, recording in ctx that a stale
+// copy was served.
+func readStale[T any](ctx context.Context, c cache.RequestCache, key string) (T, bool) {
+	stale, expiresAt, err := cache.GetJSON[staleCopy[T]](ctx, c, key+":stale")
+	if err != nil || !expiresAt.After(time.Now()) {
+		var zero T
+		return zero, false
+	}
+	markStale(ctx, stale.CachedAt)
+	return stale.Value, true
+}
+
+// refreshEarly decides whether to refresh a cache entry before it expires (XFetch). The chance
+// grows as expiry nears and with how long the lookup takes, so that a single request usually
+// refreshes a hot entry before the crowd behind it sees it expire.
+func refreshEarly(delta time.Duration, expiresAt time.Time) bool {
+	// 1 - Float64 lies in (0, 1], keeping the logarithm finite
+	gap := time.Duration(float64(delta) * -math.Log(1-rand.Float64()))
+	return !time.Now().Add(gap).Before(expiresAt)
+}
+
+// invalidatePolicy drops the cached lookups of the given policies and every cached listing of
+// their owner's policies. Inside a transaction started by WithTransaction, this waits until it commits.
+func (s *PolicyService) invalidatePolicy(ctx context.Context, userID string, ids ...string) {
+	if deferInvalidation(ctx, userID, ids...) {
+		return
+	}
+
+	keys := make([]string, 0, 2*len(ids))
+	for _, id := range ids {
+		key := userID + ":policy:" + id
+		keys = append(keys, key, key+":stale")
+	}
+	_ = s.cache.DeleteMany(ctx, keys...)
+	s.invalidateLists(ctx, userID)
+}
+
+// invalidateLists bumps the user's list generation, which every cached listing and its stale
+// copy are keyed by, so that none of them can be read again.
+func (s *PolicyService) invalidateLists(ctx context.Context, userID string) {
+	if _, err := s.cache.Incr(ctx, listGenerationKey(userID), listGenerationTTL); err != nil {
+		log.Printf("failed to bump policy list generation: %v\n", err)
+	}
+}
+
+func listGenerationKey(userID string) string {
+	return userID + ":policies:generation"
+}
+
+// listGeneration returns the user's current list generation, reporting false if the cache
+// cannot be read.
+func (s *PolicyService) listGeneration(ctx context.Context, userID string) (string, bool) {
+	generation, _, err := s.cache.Get(ctx, listGenerationKey(userID))
+	switch {
+	case err == nil:
+		return generation, true
+	case errors.Is(err, cache.ErrNotFound), errors.Is(err, cache.ErrExpired):
+		return "0", true
+	default:
+		return "", false
+	}
+}
+
+// listCacheKey returns the cache key of a listing of the user's policies under the current list
+// generation, reporting false if the generation cannot be read.
+func (s *PolicyService) listCacheKey(ctx context.Context, userID string, listKey string) (string, bool) {
+	generation, ok := s.listGeneration(ctx, userID)
+	return userID + ":policies:" + generation + ":" + listKey, ok
+}
+
+// cachedList returns a cached listing of the user's policies, calling load and caching its
+// result on a miss. The generation is read before load runs, so a listing loaded while a
+// change lands is cached under a generation that the change has already retired. While the
+// database is unavailable, the last listing loaded under the current generation is served instead.
+func (s *PolicyService) cachedList(ctx context.Context, userID uuid.UUID, listKey string, load func() ([]*repository.Policy, error)) ([]*repository.Policy, error) {
+	cacheKey, ok := s.listCacheKey(ctx, userID.String(), listKey)
+	useCache := ok && s.ttls.List > 0
+
+	if useCache {
+		cached, expiresAt, err := cache.GetJSON[[]*repository.Policy](ctx, s.cache, cacheKey)
+		if err == nil && expiresAt.After(time.Now()) && *cached != nil {
+			return *cached, nil
+		}
+	}
+
+	policies, err := load()
+	if err != nil {
+		if ok && db.IsUnavailable(err) {
+			if stale, found := readStale[[]*repository.Policy](ctx, s.cache, cacheKey); found && stale != nil {
+				return stale, nil
+			}
+		}
+		return nil, err
+	}
+	if useCache {
+		_ = cache.SetJSON(ctx, s.cache, cacheKey, policies, s.ttls.List)
+	}
+	if ok {
+		s.keepStale(ctx, cacheKey, policies)
+	}
+	return policies, nil
+}
+
+// filterCacheKey condenses a policy filter into a cache key component.
+func filterCacheKey(filter repository.PolicyFilter) string {
+	b, _ := json.Marshal(filter)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
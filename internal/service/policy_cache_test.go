@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/cache"
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+)
+
+func TestRefreshEarly(t *testing.T) {
+	now := time.Now()
+
+	if refreshEarly(0, now.Add(time.Minute)) {
+		t.Fatal("expected an entry with no lookup time to be kept until it expires")
+	}
+	if !refreshEarly(0, now.Add(-time.Second)) {
+		t.Fatal("expected an expired entry to be refreshed")
+	}
+
+	// With a lookup as long as the time left, about a third of requests (1/e) refresh
+	refreshed := 0
+	for range 1000 {
+		if refreshEarly(time.Minute, now.Add(time.Minute)) {
+			refreshed++
+		}
+	}
+	if refreshed < 250 || refreshed == 1000 {
+		t.Fatalf("expected a share of requests to refresh early, got %d of 1000", refreshed)
+	}
+}
+
+func TestStaleCopyMarksContext(t *testing.T) {
+	s := &PolicyService{cache: cache.NewMemoryCache(10), ttls: CacheTTLs{Stale: time.Hour}}
+	ctx := TrackStaleness(context.Background())
+
+	if _, ok := readStale[*repository.Policy](ctx, s.cache, "user:policy:1"); ok {
+		t.Fatal("expected no stale copy before one was kept")
+	}
+	if _, stale := StaleSince(ctx); stale {
+		t.Fatal("expected a missing stale copy not to mark the context")
+	}
+
+	s.keepStale(ctx, "user:policy:1", &repository.Policy{Name: "Evenings"})
+	policy, ok := readStale[*repository.Policy](ctx, s.cache, "user:policy:1")
+	if !ok || policy == nil || policy.Name != "Evenings" {
+		t.Fatalf("expected the kept copy to be served, got %+v", policy)
+	}
+	if _, stale := StaleSince(ctx); !stale {
+		t.Fatal("expected serving a stale copy to mark the context")
+	}
+}
+
+func TestInvalidationDropsStaleCopies(t *testing.T) {
+	ctx := context.Background()
+	s := &PolicyService{cache: cache.NewMemoryCache(10), ttls: CacheTTLs{Stale: time.Hour}}
+
+	s.keepStale(ctx, "user:policy:1", &repository.Policy{Name: "Evenings"})
+	listKey, ok := s.listCacheKey(ctx, "user", "all")
+	if !ok {
+		t.Fatal("expected the list generation to be readable")
+	}
+	s.keepStale(ctx, listKey, []*repository.Policy{{Name: "Evenings"}})
+
+	s.EvictPolicy(ctx, "user", "1")
+
+	if _, ok := readStale[*repository.Policy](ctx, s.cache, "user:policy:1"); ok {
+		t.Fatal("expected the stale copy of the policy to be dropped")
+	}
+	listKey, _ = s.listCacheKey(ctx, "user", "all")
+	if _, ok := readStale[[]*repository.Policy](ctx, s.cache, listKey); ok {
+		t.Fatal("expected the stale listing to be retired")
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/convert"
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/google/uuid"
+)
+
+var ErrNoExchangeRate = errors.New("no exchange rate configured")
+
+// ErrInvalidClone is returned when a converted copy of a policy's script is not a valid script.
+var ErrInvalidClone = errors.New("converted script is invalid")
+
+// ClonePolicy copies a policy into each of the given marketplaces as a new draft, converting
+// the absolute amounts in its script and dayparts into the target marketplace's currency.
+// Every copy is converted and validated before any is stored, and the copies are stored in one
+// transaction, so either all of them are created or none are. Copies keep the policy's name,
+// numbered if the target marketplace already uses it. It returns nil if the policy does not exist.
+func (s *PolicyService) ClonePolicy(ctx context.Context, userID uuid.UUID, id string, marketplaces []string) ([]*repository.Policy, error) {
+	source, err := s.GetPolicy(ctx, userID, id)
+	if err != nil || source == nil {
+		return nil, err
+	}
+
+	clones := make([]*repository.Policy, 0, len(marketplaces))
+	for _, marketplace := range marketplaces {
+		clone, err := s.clonePolicy(userID, source, marketplace)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", marketplace, err)
+		}
+		clones = append(clones, clone)
+	}
+
+	err = s.WithTransaction(ctx, func(ctx context.Context) error {
+		for _, clone := range clones {
+			if err := s.insertClone(ctx, userID, clone); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateLists(ctx, userID.String())
+	return clones, nil
+}
+
+// insertClone stores clone under the first of its name and numbered variants that its
+// marketplace does not use yet. A failed insert aborts the transaction it runs in, so free
+// names are found up front rather than by retrying on ErrDuplicatePolicyName.
+func (s *PolicyService) insertClone(ctx context.Context, userID uuid.UUID, clone *repository.Policy) error {
+	existing, err := s.repo.ListPoliciesByMarketplace(ctx, userID, clone.Marketplace)
+	if err != nil {
+		return err
+	}
+	taken := func(name string) bool {
+		for _, p := range existing {
+			if strings.EqualFold(p.Name, name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	name := clone.Name
+	for n := 2; taken(clone.Name) && n <= maxNameSuffix; n++ {
+		clone.Name = fmt.Sprintf("%s (%d)", name, n)
+	}
+	return s.repo.CreatePolicy(ctx, clone)
+}
+
+// clonePolicy builds the draft copy of source for a marketplace.
+func (s *PolicyService) clonePolicy(userID uuid.UUID, source *repository.Policy, marketplace string) (*repository.Policy, error) {
+	rate, err := s.exchangeRate(source.Marketplace, marketplace)
+	if err != nil {
+		return nil, err
+	}
+
+	script, err := s.convertScript(source.Script, rate)
+	if err != nil {
+		return nil, err
+	}
+	clone := s.newPolicy(userID, marketplace, repository.StatusDraft, PolicyFields{
+		Name:        source.Name,
+		Script:      script,
+		Description: &source.Description,
+		Tags:        source.Tags,
+	})
+
+	for _, daypart := range source.Dayparts {
+		if daypart.Script, err = s.convertScript(daypart.Script, rate); err != nil {
+			return nil, fmt.Errorf("daypart %q: %w", daypart.Name, err)
+		}
+		clone.Dayparts = append(clone.Dayparts, daypart)
+	}
+	clone.Summary = s.summarize(clone.Script, clone.Dayparts)
+	return clone, nil
+}
+
+// convertScript rewrites the absolute amounts in a script at the given exchange rate, checking
+// that the result is still a valid script.
+func (s *PolicyService) convertScript(script string, rate float64) (string, error) {
+	if rate == 1 {
+		return script, nil
+	}
+	root := s.converter.ScriptToTree(script)
+	if root == nil {
+		return "", errors.New("script cannot be parsed")
+	}
+
+	converted, err := convert.ConvertCurrency(root, rate)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidClone, err)
+	}
+	script, err = s.converter.TreeToScript(converted)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidClone, err)
+	}
+	if parseErrs := convert.GetScriptErrors(script); parseErrs != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidClone, parseErrs)
+	}
+	return script, nil
+}
+
+// exchangeRate returns the factor that converts amounts in the currency of one marketplace
+// into the currency of another.
+func (s *PolicyService) exchangeRate(from, to string) (float64, error) {
+	fromCurrency := repository.MarketplaceCurrency(from)
+	toCurrency := repository.MarketplaceCurrency(to)
+	if fromCurrency == toCurrency {
+		return 1, nil
+	}
+
+	fromRate, ok := s.rates[fromCurrency]
+	if !ok {
+		return 0, fmt.Errorf("%w for %s", ErrNoExchangeRate, fromCurrency)
+	}
+	toRate, ok := s.rates[toCurrency]
+	if !ok {
+		return 0, fmt.Errorf("%w for %s", ErrNoExchangeRate, toCurrency)
+	}
+	return toRate / fromRate, nil
+}
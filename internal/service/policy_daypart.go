@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/convert"
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrInvalidDaypart = errors.New("invalid daypart")
+
+const (
+	minutesPerDay  = 24 * 60
+	minutesPerWeek = 7 * minutesPerDay
+)
+
+// daypartDays maps day names to their position in a week starting on Monday.
+var daypartDays = map[string]int{"mon": 0, "tue": 1, "wed": 2, "thu": 3, "fri": 4, "sat": 5, "sun": 6}
+
+// ResolvedPolicy is the script a policy runs at a given instant.
+// Daypart is the name of the daypart in force, or nil when the policy's own script applies.
+type ResolvedPolicy struct {
+	PolicyID  string        `json:"policy_id"`
+	At        time.Time     `json:"at"`
+	LocalTime string        `json:"local_time"`
+	Daypart   *string       `json:"daypart"`
+	Script    string        `json:"script"`
+	Tree      *convert.Node `json:"tree"`
+}
+
+// SetDayparts replaces a policy's dayparts after checking that their times are well formed,
+// their scripts are publishable and no two of them overlap. Dayparts run in place of the
+// script, so like the script of a live policy in an account that requires approval, they are
+// instead submitted as a change request, which is returned in place of the policy.
+func (s *PolicyService) SetDayparts(ctx context.Context, userID uuid.UUID, id string, dayparts []repository.Daypart) (*repository.Policy, *repository.ChangeRequest, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil, nil
+	}
+
+	existing, err := s.repo.GetPolicy(ctx, userID, objID)
+	if err != nil || existing == nil {
+		return nil, nil, err
+	}
+	if existing.Status == repository.StatusArchived {
+		return nil, nil, ErrPolicyArchived
+	}
+
+	if err := checkDayparts(dayparts); err != nil {
+		return nil, nil, err
+	}
+	for i, daypart := range dayparts {
+		if err := s.checkPublishable(daypart.Script); err != nil {
+			return nil, nil, fmt.Errorf("daypart %d: %w", i+1, err)
+		}
+	}
+
+	if needsApproval(existing.Status) {
+		required, err := s.requiresApproval(ctx, userID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if required {
+			cr, err := s.submitDaypartChange(ctx, userID, existing, dayparts)
+			return nil, cr, err
+		}
+	}
+
+	summary := s.summarize(existing.Script, dayparts)
+	updated, err := s.repo.SetDayparts(ctx, userID, objID, existing.Script, dayparts, summary)
+	if err != nil {
+		return nil, nil, err
+	}
+	if updated == nil {
+		return nil, nil, ErrPolicyChanged
+	}
+
+	s.invalidatePolicy(ctx, userID.String(), id)
+	return updated, nil, nil
+}
+
+// submitDaypartChange submits new dayparts for a live policy as a change request. The policy
+// is left as it is until the request is approved.
+func (s *PolicyService) submitDaypartChange(ctx context.Context, userID uuid.UUID, existing *repository.Policy, dayparts []repository.Daypart) (*repository.ChangeRequest, error) {
+	cr := &repository.ChangeRequest{
+		PolicyID:         existing.ID,
+		OwnerID:          userID.String(),
+		RequestedBy:      userID.String(),
+		BaseScript:       existing.Script,
+		ProposedScript:   existing.Script,
+		Diff:             []convert.TreeChange{},
+		Status:           repository.ChangeRequestPending,
+		Comments:         []repository.ChangeComment{},
+		CreatedAt:        time.Now().UTC(),
+		BaseDayparts:     existing.Dayparts,
+		ProposedDayparts: &dayparts,
+	}
+	if err := s.approvals.CreateChangeRequest(ctx, cr); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// ResolvePolicy returns the script, and its tree, that a policy runs at the given instant.
+func (s *PolicyService) ResolvePolicy(ctx context.Context, userID uuid.UUID, id string, at time.Time) (*ResolvedPolicy, error) {
+	policy, err := s.GetPolicy(ctx, userID, id)
+	if err != nil || policy == nil {
+		return nil, err
+	}
+
+	resolved := &ResolvedPolicy{
+		PolicyID:  policy.ID.Hex(),
+		At:        at.UTC(),
+		LocalTime: at.In(repository.MarketplaceLocation(policy.Marketplace)).Format(time.RFC3339),
+		Script:    policy.Script,
+	}
+	if daypart := ResolveDaypart(policy, at); daypart != nil {
+		resolved.Daypart = &daypart.Name
+		resolved.Script = daypart.Script
+	}
+	resolved.Tree = s.converter.ScriptToTree(resolved.Script)
+	return resolved, nil
+}
+
+// ResolveDaypart returns the daypart of p in force at the given instant in the marketplace's
+// local time, or nil if none is. Dayparts are stored without overlaps, but if several match,
+// the first one wins.
+func ResolveDaypart(p *repository.Policy, at time.Time) *repository.Daypart {
+	local := at.In(repository.MarketplaceLocation(p.Marketplace))
+	// time.Weekday starts on Sunday, dayparts on Monday
+	minute := ((int(local.Weekday())+6)%7)*minutesPerDay + local.Hour()*60 + local.Minute()
+
+	for i := range p.Dayparts {
+		ranges, err := daypartRanges(p.Dayparts[i])
+		if err != nil {
+			continue
+		}
+		for _, r := range ranges {
+			if r[0] <= minute && minute < r[1] {
+				return &p.Dayparts[i]
+			}
+		}
+	}
+	return nil
+}
+
+// checkDayparts reports the first malformed or overlapping daypart.
+func checkDayparts(dayparts []repository.Daypart) error {
+	ranges := make([][][2]int, len(dayparts))
+	for i, daypart := range dayparts {
+		if strings.TrimSpace(daypart.Name) == "" {
+			return fmt.Errorf("%w: daypart %d has no name", ErrInvalidDaypart, i+1)
+		}
+		r, err := daypartRanges(daypart)
+		if err != nil {
+			return fmt.Errorf("%w: daypart %q: %w", ErrInvalidDaypart, daypart.Name, err)
+		}
+		ranges[i] = r
+
+		for j := 0; j < i; j++ {
+			if rangesOverlap(ranges[j], ranges[i]) {
+				return fmt.Errorf("%w: daypart %q overlaps daypart %q", ErrInvalidDaypart, daypart.Name, dayparts[j].Name)
+			}
+		}
+	}
+	return nil
+}
+
+// daypartRanges converts a daypart into half-open ranges of minutes since Monday 00:00.
+// A daypart that runs past Sunday midnight wraps around to Monday.
+func daypartRanges(daypart repository.Daypart) ([][2]int, error) {
+	if len(daypart.Days) == 0 {
+		return nil, errors.New("no days given")
+	}
+	start, err := parseClock(daypart.Start)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(daypart.End)
+	if err != nil {
+		return nil, err
+	}
+	if start == end {
+		return nil, errors.New("start and end are the same")
+	}
+
+	length := end - start
+	if end < start {
+		length += minutesPerDay
+	}
+
+	ranges := make([][2]int, 0, len(daypart.Days))
+	for _, day := range daypart.Days {
+		index, ok := daypartDays[day]
+		if !ok {
+			return nil, fmt.Errorf("unknown day %q", day)
+		}
+		from := index*minutesPerDay + start
+		to := from + length
+		if to <= minutesPerWeek {
+			ranges = append(ranges, [2]int{from, to})
+		} else {
+			ranges = append(ranges, [2]int{from, minutesPerWeek}, [2]int{0, to - minutesPerWeek})
+		}
+	}
+	return ranges, nil
+}
+
+func rangesOverlap(a, b [][2]int) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x[0] < y[1] && y[0] < x[1] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseClock parses a "15:04" time of day into minutes after midnight.
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q: expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/convert"
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+)
+
+func TestResolveDaypart(t *testing.T) {
+	policy := &repository.Policy{
+		Marketplace: repository.MpUK,
+		Dayparts: []repository.Daypart{
+			{Name: "peak", Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "08:00", End: "20:00"},
+			{Name: "late", Days: []string{"sun"}, Start: "22:00", End: "02:00"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		expected string
+	}{
+		// 2026-07-01 is a Wednesday; London is on BST (UTC+1)
+		{name: "weekday peak", at: time.Date(2026, 7, 1, 7, 0, 0, 0, time.UTC), expected: "peak"},
+		{name: "before peak in local time", at: time.Date(2026, 7, 1, 6, 59, 0, 0, time.UTC), expected: ""},
+		{name: "end is exclusive", at: time.Date(2026, 7, 1, 19, 0, 0, 0, time.UTC), expected: ""},
+		{name: "weekend", at: time.Date(2026, 7, 4, 12, 0, 0, 0, time.UTC), expected: ""},
+		{name: "sunday night", at: time.Date(2026, 7, 5, 22, 30, 0, 0, time.UTC), expected: "late"},
+		{name: "wraps into monday", at: time.Date(2026, 7, 6, 0, 30, 0, 0, time.UTC), expected: "late"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			daypart := ResolveDaypart(policy, test.at)
+			got := ""
+			if daypart != nil {
+				got = daypart.Name
+			}
+			if got != test.expected {
+				t.Fatalf("expected daypart %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestCheckDayparts(t *testing.T) {
+	tests := []struct {
+		name     string
+		dayparts []repository.Daypart
+		valid    bool
+	}{
+		{
+			name: "disjoint",
+			dayparts: []repository.Daypart{
+				{Name: "morning", Days: []string{"mon"}, Start: "06:00", End: "12:00"},
+				{Name: "afternoon", Days: []string{"mon"}, Start: "12:00", End: "18:00"},
+			},
+			valid: true,
+		},
+		{
+			name: "overlapping",
+			dayparts: []repository.Daypart{
+				{Name: "morning", Days: []string{"mon"}, Start: "06:00", End: "12:00"},
+				{Name: "midday", Days: []string{"mon"}, Start: "11:00", End: "14:00"},
+			},
+		},
+		{
+			name: "overnight overlaps next day",
+			dayparts: []repository.Daypart{
+				{Name: "night", Days: []string{"sun"}, Start: "23:00", End: "03:00"},
+				{Name: "early", Days: []string{"mon"}, Start: "02:00", End: "05:00"},
+			},
+		},
+		{
+			name:     "unknown day",
+			dayparts: []repository.Daypart{{Name: "odd", Days: []string{"funday"}, Start: "01:00", End: "02:00"}},
+		},
+		{
+			name:     "malformed time",
+			dayparts: []repository.Daypart{{Name: "odd", Days: []string{"mon"}, Start: "8am", End: "10:00"}},
+		},
+		{
+			name:     "empty span",
+			dayparts: []repository.Daypart{{Name: "odd", Days: []string{"mon"}, Start: "10:00", End: "10:00"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkDayparts(test.dayparts)
+			if test.valid && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !test.valid && !errors.Is(err, ErrInvalidDaypart) {
+				t.Fatalf("expected ErrInvalidDaypart, got %v", err)
+			}
+		})
+	}
+}
+
+// scriptTrees is a converter that looks up the tree of each script.
+type scriptTrees struct {
+	ConvertServiceInterface
+	trees map[string]*convert.Node
+}
+
+func (c scriptTrees) ScriptToTree(source string) *convert.Node {
+	return c.trees[source]
+}
+
+func TestSummaryCoversDayparts(t *testing.T) {
+	s := &PolicyService{converter: scriptTrees{trees: map[string]*convert.Node{
+		"published": terminalNode(convert.OperatorSet, 1.00, false),
+		"peak": conditionNode(convert.MetricACoS,
+			[]convert.BranchNode{branchNode(nil, float64Ptr(0.20), terminalNode(convert.OperatorAdd, 5.00, true))},
+			terminalNode(convert.OperatorSub, 0.10, false),
+		),
+	}}}
+	dayparts := []repository.Daypart{{Name: "peak", Script: "peak"}}
+
+	summary := s.summarize("published", dayparts)
+	if summary == nil {
+		t.Fatal("expected a summary")
+	}
+	if !slices.Equal(summary.Metrics, []string{string(convert.MetricACoS)}) {
+		t.Fatalf("expected the daypart's metric to be summarized, got %v", summary.Metrics)
+	}
+	if !slices.Equal(summary.Operators, []string{"=", "+", "-"}) {
+		t.Fatalf("expected the operators of both scripts, got %v", summary.Operators)
+	}
+	if summary.MaxDepth != 1 || summary.BranchCount != 1 || summary.DaypartCount != 1 {
+		t.Fatalf("expected depth 1, 1 branch and 1 daypart, got %+v", summary)
+	}
+}
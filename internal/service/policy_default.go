@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/db"
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetDefaultPolicy returns the user's default policy for a marketplace, or nil if there is none.
+// While the database is unavailable, the last-known default is served and recorded as stale in
+// ctx, unless a policy of the user changed since; it is kept under the list generation for that.
+func (s *PolicyService) GetDefaultPolicy(ctx context.Context, userID uuid.UUID, marketplace string) (*repository.Policy, error) {
+	staleKey, ok := s.listCacheKey(ctx, userID.String(), "default:"+marketplace)
+	policy, err := s.repo.GetDefaultPolicy(ctx, userID, marketplace)
+	if err != nil {
+		if ok && db.IsUnavailable(err) {
+			if stale, ok := readStale[*repository.Policy](ctx, s.cache, staleKey); ok && stale != nil {
+				return stale, nil
+			}
+		}
+		return nil, err
+	}
+	if ok && policy != nil {
+		s.keepStale(ctx, staleKey, policy)
+	}
+	return policy, nil
+}
+
+// SetDefaultPolicy makes a policy the default for its marketplace, replacing the previous default.
+func (s *PolicyService) SetDefaultPolicy(ctx context.Context, userID uuid.UUID, id string) (*repository.Policy, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	existing, err := s.repo.GetPolicy(ctx, userID, objID)
+	if err != nil || existing == nil {
+		return nil, err
+	}
+	if existing.Status == repository.StatusArchived {
+		return nil, ErrPolicyArchived
+	}
+
+	var previous, updated *repository.Policy
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if previous, err = s.repo.ClearDefaultPolicy(ctx, userID, existing.Marketplace); err != nil {
+			return err
+		}
+		if updated, err = s.repo.SetPolicyDefault(ctx, userID, objID, true); err != nil {
+			return err
+		}
+		if updated == nil {
+			return ErrPolicyArchived
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{id}
+	if previous != nil {
+		ids = append(ids, previous.ID.Hex())
+	}
+	s.invalidatePolicy(ctx, userID.String(), ids...)
+	return updated, nil
+}
+
+// UnsetDefaultPolicy stops a policy being its marketplace's default.
+func (s *PolicyService) UnsetDefaultPolicy(ctx context.Context, userID uuid.UUID, id string) (*repository.Policy, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	updated, err := s.repo.SetPolicyDefault(ctx, userID, objID, false)
+	if err != nil || updated == nil {
+		return nil, err
+	}
+
+	s.invalidatePolicy(ctx, userID.String(), id)
+	return updated, nil
+}
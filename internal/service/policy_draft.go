@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/convert"
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrNothingToPublish = errors.New("policy is already active and has no draft to publish")
+
+// DraftValidationError reports why a draft script cannot be published.
+type DraftValidationError struct {
+	Details []error
+}
+
+func (e *DraftValidationError) Error() string {
+	return "draft cannot be published: " + errors.Join(e.Details...).Error()
+}
+
+func (e *DraftValidationError) Unwrap() []error {
+	return e.Details
+}
+
+// SaveDraft stores script as the policy's draft without affecting the published script.
+func (s *PolicyService) SaveDraft(ctx context.Context, userID uuid.UUID, id, script string) (*repository.Policy, error) {
+	return s.setDraft(ctx, userID, id, &script)
+}
+
+// DiscardDraft drops the policy's draft, if it has one.
+func (s *PolicyService) DiscardDraft(ctx context.Context, userID uuid.UUID, id string) (*repository.Policy, error) {
+	return s.setDraft(ctx, userID, id, nil)
+}
+
+func (s *PolicyService) setDraft(ctx context.Context, userID uuid.UUID, id string, draft *string) (*repository.Policy, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	existing, err := s.repo.GetPolicy(ctx, userID, objID)
+	if err != nil || existing == nil {
+		return nil, err
+	}
+	if existing.Status == repository.StatusArchived {
+		return nil, ErrPolicyArchived
+	}
+
+	updated, err := s.repo.SetDraftScript(ctx, userID, objID, draft)
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidatePolicy(ctx, userID.String(), id)
+	return updated, nil
+}
+
+// PublishPolicy activates a policy. If the policy has a draft, the draft is validated and
+// linted, then promoted to the published script; otherwise only the status changes.
+// In accounts that require approval, a draft of an active or paused policy is instead submitted
+// as a change request, which is returned in place of the policy; the status is left unchanged.
+func (s *PolicyService) PublishPolicy(ctx context.Context, userID uuid.UUID, id string) (*repository.Policy, *repository.ChangeRequest, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil, nil
+	}
+
+	existing, err := s.repo.GetPolicy(ctx, userID, objID)
+	if err != nil || existing == nil {
+		return nil, nil, err
+	}
+
+	from := existing.Status
+	if existing.DraftScript == nil && from == repository.StatusActive {
+		return nil, nil, ErrNothingToPublish
+	}
+	if from != repository.StatusActive && !CanTransition(from, repository.StatusActive) {
+		return nil, nil, fmt.Errorf("%w: cannot move from %s to %s", ErrInvalidTransition, from, repository.StatusActive)
+	}
+
+	var summary *repository.PolicySummary
+	if existing.DraftScript != nil {
+		if err := s.checkPublishable(*existing.DraftScript); err != nil {
+			return nil, nil, err
+		}
+
+		if needsApproval(from) {
+			required, err := s.requiresApproval(ctx, userID)
+			if err != nil {
+				return nil, nil, err
+			}
+			if required {
+				cr, err := s.submitChangeRequest(ctx, userID, existing, nil, nil)
+				return nil, cr, err
+			}
+		}
+
+		summary = s.summarize(*existing.DraftScript, existing.Dayparts)
+	}
+
+	// The update only applies if neither the status, the draft nor the dayparts the summary was
+	// built with changed since they were read
+	updated, err := s.repo.PublishPolicy(ctx, userID, objID, from, existing.DraftScript, existing.Dayparts, summary)
+	if err != nil {
+		return nil, nil, err
+	}
+	if updated == nil {
+		return nil, nil, fmt.Errorf("%w: policy changed while publishing", ErrInvalidTransition)
+	}
+
+	s.invalidatePolicy(ctx, userID.String(), id)
+	return updated, nil, nil
+}
+
+// checkPublishable validates script as the script validator does and lints its tree.
+func (s *PolicyService) checkPublishable(script string) error {
+	if parseErrs := convert.GetScriptErrors(script); parseErrs != nil {
+		return &DraftValidationError{Details: []error{parseErrs}}
+	}
+
+	root := s.converter.ScriptToTree(script)
+	if root == nil {
+		return &DraftValidationError{Details: []error{errors.New("script could not be converted to a tree")}}
+	}
+
+	if errs := convert.LintTree(root); len(errs) > 0 {
+		return &DraftValidationError{Details: errs}
+	}
+	return nil
+}
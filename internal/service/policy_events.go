@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/events"
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+)
+
+const (
+	// followBatch is how many events are read from the log at a time while following it.
+	followBatch = 100
+	// followPollInterval is how often a follower checks the log without being woken, which
+	// catches events whose wake-up was missed and is when idle followers are kept alive.
+	followPollInterval = 15 * time.Second
+	// followWakeBuffer lets a burst of changes queue up wake-ups without any being dropped.
+	followWakeBuffer = 64
+)
+
+// ErrCursorExpired is returned for a change feed cursor whose following events were already
+// deleted from the log, so that changes after it can no longer be listed.
+var ErrCursorExpired = errors.New("cursor has expired")
+
+// PolicyEventService reads the policy event log that the outbox keeps.
+type PolicyEventService struct {
+	outbox repository.OutboxRepository
+	bus    *events.Bus
+}
+
+// PolicyEventServiceInterface defines the contract for reading policy events.
+type PolicyEventServiceInterface interface {
+	LastEventSeq(ctx context.Context) (int64, error)
+	Follow(ctx context.Context, afterSeq int64, userID string, send func(e *repository.OutboxEvent) error, idle func() error) error
+	ListChanges(ctx context.Context, since int64, userID string, limit int) (*ChangeFeed, error)
+}
+
+// ChangeFeedEntry is the latest state of a policy that changed. A policy that was deleted,
+// whether moved to the trash or purged, is a tombstone: Deleted is set and Policy is nil.
+type ChangeFeedEntry struct {
+	Seq         int64              `json:"seq"`
+	PolicyID    string             `json:"policy_id"`
+	UserID      string             `json:"user_id"`
+	Marketplace string             `json:"marketplace"`
+	Deleted     bool               `json:"deleted"`
+	Policy      *repository.Policy `json:"policy,omitempty"`
+}
+
+// ChangeFeed is a page of policy changes. Passing NextCursor back as the cursor continues after
+// the last change on the page. HasMore is set when the page was full, so more may be waiting.
+// Changes made through the service record their event in the same transaction; edits made
+// directly in the database are recorded by the PolicyWatcher, shortly after they happen.
+// Events are deleted some time after they were handed to webhooks, see config.EventConfig, so
+// a cursor only stays usable for that long after the changes following it.
+type ChangeFeed struct {
+	Changes    []*ChangeFeedEntry `json:"changes"`
+	NextCursor int64              `json:"next_cursor"`
+	HasMore    bool               `json:"has_more"`
+}
+
+// NewPolicyEventService creates a PolicyEventService. Followers are woken by changes
+// published on bus, rather than waiting for their next poll.
+func NewPolicyEventService(outbox repository.OutboxRepository, bus *events.Bus) *PolicyEventService {
+	return &PolicyEventService{outbox: outbox, bus: bus}
+}
+
+// LastEventSeq returns the sequence number of the latest event, or 0 if there are none.
+func (s *PolicyEventService) LastEventSeq(ctx context.Context) (int64, error) {
+	return s.outbox.LastEventSeq(ctx)
+}
+
+// Follow calls send with every event after afterSeq, in order, only for userID's policies if
+// it is set, and then with every event recorded after that, including those the PolicyWatcher
+// records for direct edits, which publish their wake-up once recorded. idle is called whenever
+// the log has been checked without a wake-up. It returns when ctx is cancelled or either callback fails.
+func (s *PolicyEventService) Follow(ctx context.Context, afterSeq int64, userID string, send func(e *repository.OutboxEvent) error, idle func() error) error {
+	// Subscribe before the first read, so that no change between the two goes unnoticed
+	wake, unsubscribe := s.bus.Subscribe(followWakeBuffer)
+	defer unsubscribe()
+
+	ticker := time.NewTicker(followPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			batch, err := s.outbox.ListEventsAfter(ctx, afterSeq, userID, followBatch)
+			if err != nil {
+				return err
+			}
+			for _, e := range batch {
+				if err := send(e); err != nil {
+					return err
+				}
+				afterSeq = e.Seq
+			}
+			if len(batch) < followBatch {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wake:
+			// One read covers every queued wake-up
+			for len(wake) > 0 {
+				<-wake
+			}
+		case <-ticker.C:
+			if err := idle(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ListChanges returns the policies, only userID's if it is set, that changed after the cursor
+// since, reading at most limit events. A policy that changed more than once appears once, in
+// its latest state, ordered by when that happened. It returns ErrCursorExpired if events after
+// since were deleted.
+func (s *PolicyEventService) ListChanges(ctx context.Context, since int64, userID string, limit int) (*ChangeFeed, error) {
+	batch, err := s.outbox.ListEventsAfter(ctx, since, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	// Events are deleted oldest first, so checking after reading shows whether the read missed any
+	first, err := s.outbox.FirstEventSeq(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if since < first-1 {
+		return nil, ErrCursorExpired
+	}
+
+	feed := &ChangeFeed{Changes: []*ChangeFeedEntry{}, NextCursor: since, HasMore: len(batch) == limit}
+	latest := make(map[string]int, len(batch))
+	for _, e := range batch {
+		entry := &ChangeFeedEntry{
+			Seq:         e.Seq,
+			PolicyID:    e.PolicyID.Hex(),
+			UserID:      e.UserID,
+			Marketplace: e.Marketplace,
+		}
+		switch e.Type {
+		case repository.EventPolicyDeleted, repository.EventPolicyPurged:
+			entry.Deleted = true
+		default:
+			entry.Policy = e.Policy
+		}
+
+		// Drop the policy's earlier change, keeping the feed in order of latest change
+		if i, ok := latest[entry.PolicyID]; ok {
+			feed.Changes[i] = nil
+		}
+		latest[entry.PolicyID] = len(feed.Changes)
+		feed.Changes = append(feed.Changes, entry)
+		feed.NextCursor = e.Seq
+	}
+	feed.Changes = slices.DeleteFunc(feed.Changes, func(entry *ChangeFeedEntry) bool { return entry == nil })
+	return feed, nil
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/events"
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryOutbox is an in-memory event log.
+type memoryOutbox struct {
+	mu     sync.Mutex
+	events []*repository.OutboxEvent
+}
+
+func (o *memoryOutbox) add(userID string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, &repository.OutboxEvent{Seq: int64(len(o.events) + 1), UserID: userID, Type: repository.EventPolicyUpdated})
+}
+
+func (o *memoryOutbox) ListEventsAfter(_ context.Context, afterSeq int64, userID string, limit int) ([]*repository.OutboxEvent, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var out []*repository.OutboxEvent
+	for _, e := range o.events {
+		if e.Seq > afterSeq && (userID == "" || e.UserID == userID) && len(out) < limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (o *memoryOutbox) LastEventSeq(context.Context) (int64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return int64(len(o.events)), nil
+}
+
+func (o *memoryOutbox) FirstEventSeq(context.Context) (int64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.events) == 0 {
+		return 1, nil
+	}
+	return o.events[0].Seq, nil
+}
+
+func (o *memoryOutbox) ListUndispatchedEvents(context.Context, int) ([]*repository.OutboxEvent, error) {
+	return nil, nil
+}
+
+func (o *memoryOutbox) MarkEventDispatched(context.Context, primitive.ObjectID, time.Time) error {
+	return nil
+}
+
+func TestFollowResumesAndWakes(t *testing.T) {
+	outbox := &memoryOutbox{}
+	outbox.add("a")
+	outbox.add("b")
+	outbox.add("a")
+	bus := events.NewBus()
+	s := NewPolicyEventService(outbox, bus)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var seen []int64
+	stop := errors.New("stop")
+	send := func(e *repository.OutboxEvent) error {
+		seen = append(seen, e.Seq)
+		switch len(seen) {
+		case 1:
+			// Caught up; the next change arrives while the follower waits
+			go func() {
+				outbox.add("a")
+				bus.Publish(events.PolicyEvent{Type: events.PolicyUpdated})
+			}()
+		case 2:
+			return stop
+		}
+		return nil
+	}
+
+	err := s.Follow(ctx, 1, "a", send, func() error { return nil })
+	if !errors.Is(err, stop) {
+		t.Fatalf("expected the follower to stop when send fails, got %v", err)
+	}
+	if len(seen) != 2 || seen[0] != 3 || seen[1] != 4 {
+		t.Fatalf("expected events 3 and 4 of user a, got %v", seen)
+	}
+}
+
+func TestListChangesKeepsLatestState(t *testing.T) {
+	first, second := primitive.NewObjectID(), primitive.NewObjectID()
+	outbox := &memoryOutbox{events: []*repository.OutboxEvent{
+		{Seq: 1, Type: repository.EventPolicyCreated, PolicyID: first, Policy: &repository.Policy{Name: "v1"}},
+		{Seq: 2, Type: repository.EventPolicyCreated, PolicyID: second, Policy: &repository.Policy{Name: "other"}},
+		{Seq: 3, Type: repository.EventPolicyUpdated, PolicyID: first, Policy: &repository.Policy{Name: "v2"}},
+		{Seq: 4, Type: repository.EventPolicyDeleted, PolicyID: second, Policy: &repository.Policy{Name: "other"}},
+	}}
+	s := NewPolicyEventService(outbox, events.NewBus())
+
+	feed, err := s.ListChanges(context.Background(), 0, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.NextCursor != 4 || feed.HasMore {
+		t.Fatalf("expected cursor 4 with nothing more, got %d (has more: %v)", feed.NextCursor, feed.HasMore)
+	}
+	if len(feed.Changes) != 2 {
+		t.Fatalf("expected one change per policy, got %d", len(feed.Changes))
+	}
+	updated, deleted := feed.Changes[0], feed.Changes[1]
+	if updated.Seq != 3 || updated.Deleted || updated.Policy.Name != "v2" {
+		t.Fatalf("expected the first policy's latest state, got %+v", updated)
+	}
+	if deleted.Seq != 4 || !deleted.Deleted || deleted.Policy != nil {
+		t.Fatalf("expected a tombstone for the second policy, got %+v", deleted)
+	}
+
+	feed, err = s.ListChanges(context.Background(), 3, "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.NextCursor != 4 || !feed.HasMore || len(feed.Changes) != 1 {
+		t.Fatalf("expected a full page after cursor 3, got %+v", feed)
+	}
+
+	feed, err = s.ListChanges(context.Background(), 4, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.NextCursor != 4 || len(feed.Changes) != 0 {
+		t.Fatalf("expected no changes and an unchanged cursor, got %+v", feed)
+	}
+}
+
+func TestListChangesRejectsPrunedCursor(t *testing.T) {
+	outbox := &memoryOutbox{events: []*repository.OutboxEvent{
+		{Seq: 5, Type: repository.EventPolicyUpdated, PolicyID: primitive.NewObjectID(), Policy: &repository.Policy{}},
+	}}
+	s := NewPolicyEventService(outbox, events.NewBus())
+
+	if _, err := s.ListChanges(context.Background(), 3, "", 10); !errors.Is(err, ErrCursorExpired) {
+		t.Fatalf("expected a cursor before deleted events to have expired, got %v", err)
+	}
+	if _, err := s.ListChanges(context.Background(), 4, "", 10); err != nil {
+		t.Fatalf("expected the cursor just before the oldest event to work, got %v", err)
+	}
+}
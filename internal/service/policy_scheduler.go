@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// PolicyScheduler periodically applies policy windows and scheduled changes that have fallen due.
+type PolicyScheduler struct {
+	schedules *ScheduleService
+	interval  time.Duration
+}
+
+// NewPolicyScheduler creates a PolicyScheduler that runs every interval.
+func NewPolicyScheduler(schedules *ScheduleService, interval time.Duration) *PolicyScheduler {
+	return &PolicyScheduler{schedules: schedules, interval: interval}
+}
+
+// Run applies due changes immediately and then on every tick until ctx is cancelled.
+func (s *PolicyScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.schedules.ApplyDue(ctx, time.Now().UTC()); err != nil {
+			log.Printf("scheduled policy changes failed: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
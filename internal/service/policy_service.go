@@ -2,84 +2,188 @@ package service
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/LittleAksMax/bids-policy-service/internal/cache"
+	"github.com/LittleAksMax/bids-policy-service/internal/convert"
+	"github.com/LittleAksMax/bids-policy-service/internal/db"
 	"github.com/LittleAksMax/bids-policy-service/internal/repository"
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/sync/singleflight"
 )
 
+// maxNameSuffix bounds the suffixes tried when creating a policy whose name is taken.
+const maxNameSuffix = 100
+
+// ErrPolicyChanged is returned when a policy is changed by another request between being read
+// and being updated, so the update would have overwritten that change.
+var ErrPolicyChanged = errors.New("policy was changed by another request; reload it and try again")
+
 // PolicyService provides business logic for policies, with cache support.
 type PolicyService struct {
-	repo  repository.PolicyRepository
-	cache cache.RequestCache
+	repo        repository.PolicyRepository
+	cache       cache.RequestCache
+	converter   ConvertServiceInterface
+	approvals   repository.ApprovalRepository
+	assignments repository.AssignmentRepository
+	rates       map[string]float64
+	ttls        CacheTTLs
+	lookups     singleflight.Group // coalesces concurrent cache misses per key
 }
 
 // PolicyServiceInterface defines the contract for policy service logic.
 type PolicyServiceInterface interface {
 	GetPolicy(ctx context.Context, userID uuid.UUID, id string) (*repository.Policy, error)
-	CreatePolicy(ctx context.Context, userID uuid.UUID, marketplace, name, script string) (*repository.Policy, error)
+	CreatePolicy(ctx context.Context, userID uuid.UUID, marketplace, status string, fields PolicyFields, autoSuffix bool) (*repository.Policy, error)
 	ListPolicies(ctx context.Context, userID uuid.UUID) ([]*repository.Policy, error)
 	ListPoliciesByMarketplace(ctx context.Context, userID uuid.UUID, marketplace string) ([]*repository.Policy, error)
-	UpdatePolicy(ctx context.Context, userID uuid.UUID, id, name, script string) (*repository.Policy, error)
+	ListPoliciesWithFilter(ctx context.Context, userID uuid.UUID, filter repository.PolicyFilter) ([]*repository.Policy, error)
+	SearchPolicies(ctx context.Context, userID uuid.UUID, query, marketplace string, limit int) ([]*repository.PolicySearchResult, error)
+	UpdatePolicy(ctx context.Context, userID uuid.UUID, id string, fields PolicyFields) (*repository.Policy, error)
+	TransitionPolicy(ctx context.Context, userID uuid.UUID, id, to string) (*repository.Policy, error)
+	SaveDraft(ctx context.Context, userID uuid.UUID, id, script string) (*repository.Policy, error)
+	DiscardDraft(ctx context.Context, userID uuid.UUID, id string) (*repository.Policy, error)
+	PublishPolicy(ctx context.Context, userID uuid.UUID, id string) (*repository.Policy, *repository.ChangeRequest, error)
+	GetDefaultPolicy(ctx context.Context, userID uuid.UUID, marketplace string) (*repository.Policy, error)
+	ClonePolicy(ctx context.Context, userID uuid.UUID, id string, marketplaces []string) ([]*repository.Policy, error)
+	SetDefaultPolicy(ctx context.Context, userID uuid.UUID, id string) (*repository.Policy, error)
+	UnsetDefaultPolicy(ctx context.Context, userID uuid.UUID, id string) (*repository.Policy, error)
+	SetDayparts(ctx context.Context, userID uuid.UUID, id string, dayparts []repository.Daypart) (*repository.Policy, *repository.ChangeRequest, error)
+	ResolvePolicy(ctx context.Context, userID uuid.UUID, id string, at time.Time) (*ResolvedPolicy, error)
 	DeletePolicy(ctx context.Context, userID uuid.UUID, id string) (bool, error)
+	ListDeletedPolicies(ctx context.Context, userID uuid.UUID) ([]*repository.Policy, error)
+	RestorePolicy(ctx context.Context, userID uuid.UUID, id string) (*repository.Policy, error)
+	BatchPolicies(ctx context.Context, userID uuid.UUID, ops []BatchOperation, atomic bool) ([]BatchResult, error)
+}
+
+// PolicyFields holds the user-editable fields of a policy.
+// On update, a nil Description or Tags leaves the stored value unchanged.
+type PolicyFields struct {
+	Name        string
+	Script      string
+	Description *string
+	Tags        []string
+}
+
+// applyTo copies the fields onto p. Callers must refresh the summary afterwards.
+func (f PolicyFields) applyTo(p *repository.Policy) {
+	p.Name = f.Name
+	p.Script = f.Script
+	if f.Description != nil {
+		p.Description = strings.TrimSpace(*f.Description)
+	}
+	if f.Tags != nil {
+		p.Tags = normalizeTags(f.Tags)
+	}
 }
 
 // NewPolicyService creates a new PolicyService
-func NewPolicyService(repo repository.PolicyRepository, cache cache.RequestCache) *PolicyService {
-	return &PolicyService{repo: repo, cache: cache}
+// rates are the exchange rates used when cloning policies between marketplaces.
+func NewPolicyService(repo repository.PolicyRepository, cache cache.RequestCache, converter ConvertServiceInterface, approvals repository.ApprovalRepository, assignments repository.AssignmentRepository, rates map[string]float64, ttls CacheTTLs) *PolicyService {
+	return &PolicyService{repo: repo, cache: cache, converter: converter, approvals: approvals, assignments: assignments, rates: rates, ttls: ttls}
 }
 
-// GetPolicy retrieves a policy by its ID, first checking the cache.
+// GetPolicy retrieves a policy by its ID, first checking the cache. Policies that do not exist
+// are cached too, for a shorter time. Concurrent misses for the same policy share a single
+// database lookup, and hot entries are refreshed shortly before they expire. While the database
+// is unavailable, the last-known copy of the policy is served and recorded as stale in ctx.
 func (s *PolicyService) GetPolicy(ctx context.Context, userID uuid.UUID, id string) (*repository.Policy, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+
 	cacheKey := userID.String() + ":policy:" + id
-	cached, expiresAt, err := s.cache.Get(ctx, cacheKey)
-	if err == nil && cached != "" && expiresAt.After(time.Now()) {
-		var policy repository.Policy
-		if err := json.Unmarshal([]byte(cached), &policy); err == nil {
-			return &policy, nil
-		}
+	entry, expiresAt, cached := s.cachedPolicy(ctx, cacheKey)
+	if cached && !refreshEarly(entry.Delta, expiresAt) {
+		return entry.Policy, nil
 	}
 
-	objID, err := primitive.ObjectIDFromHex(id)
+	loaded, err, _ := s.lookups.Do(cacheKey, func() (any, error) {
+		// The lookup is shared, so it must not fail because the first caller gave up
+		return s.loadPolicy(context.WithoutCancel(ctx), userID, objID, cacheKey)
+	})
 	if err != nil {
-		return nil, nil
+		if cached {
+			// Serve the entry that was being refreshed early, as it has not expired yet
+			return entry.Policy, nil
+		}
+		if db.IsUnavailable(err) {
+			// Keep bidders running on the last-known policy until the database is back
+			if stale, ok := readStale[*repository.Policy](ctx, s.cache, cacheKey); ok && stale != nil {
+				return stale, nil
+			}
+		}
+		return nil, err
 	}
-	policy, err := s.repo.GetPolicy(ctx, userID, objID)
-	if err != nil || policy == nil {
-		return policy, err
+	policy := loaded.(*repository.Policy)
+	if policy == nil {
+		return nil, nil
 	}
-	b, _ := json.Marshal(policy)
-	_ = s.cache.Set(ctx, cacheKey, string(b), 5*time.Minute)
-	return policy, nil
+	// Callers share the loaded policy, so each gets its own copy
+	return policy.Copy(), nil
 }
 
-// CreatePolicy creates a new policy.
-func (s *PolicyService) CreatePolicy(ctx context.Context, userID uuid.UUID, marketplace, name, script string) (*repository.Policy, error) {
-	p := &repository.Policy{
-		UserID:      userID.String(),
-		Marketplace: marketplace,
-		Name:        name,
-		Script:      script,
-	}
-	err := s.repo.CreatePolicy(ctx, p)
+// CreatePolicy creates a new policy with the given status, which defaults to active.
+// Names are unique per marketplace; when the name is taken, autoSuffix picks the first free
+// "Name (2)", "Name (3)" and so on instead of returning repository.ErrDuplicatePolicyName.
+func (s *PolicyService) CreatePolicy(ctx context.Context, userID uuid.UUID, marketplace, status string, fields PolicyFields, autoSuffix bool) (*repository.Policy, error) {
+	p := s.newPolicy(userID, marketplace, status, fields)
+	err := s.insertPolicy(ctx, p, autoSuffix)
 	return p, err
 }
 
-// ListPolicies retrieves all policies.
+// insertPolicy stores p, numbering its name when autoSuffix is set and the name is taken.
+func (s *PolicyService) insertPolicy(ctx context.Context, p *repository.Policy, autoSuffix bool) error {
+	name := p.Name
+	err := s.repo.CreatePolicy(ctx, p)
+	for n := 2; autoSuffix && errors.Is(err, repository.ErrDuplicatePolicyName) && n <= maxNameSuffix; n++ {
+		p.Name = fmt.Sprintf("%s (%d)", name, n)
+		err = s.repo.CreatePolicy(ctx, p)
+	}
+	if err == nil {
+		s.invalidateLists(ctx, p.UserID)
+	}
+	return err
+}
+
+// ListPolicies retrieves all policies, first checking the cache.
 func (s *PolicyService) ListPolicies(ctx context.Context, userID uuid.UUID) ([]*repository.Policy, error) {
-	return s.repo.ListPolicies(ctx, userID)
+	return s.cachedList(ctx, userID, "all", func() ([]*repository.Policy, error) {
+		return s.repo.ListPolicies(ctx, userID)
+	})
 }
 
-// ListPoliciesByMarketplace retrieves all policies for a specific marketplace.
+// ListPoliciesByMarketplace retrieves all policies for a specific marketplace, first checking the cache.
 func (s *PolicyService) ListPoliciesByMarketplace(ctx context.Context, userID uuid.UUID, marketplace string) ([]*repository.Policy, error) {
-	return s.repo.ListPoliciesByMarketplace(ctx, userID, marketplace)
+	return s.cachedList(ctx, userID, "marketplace:"+marketplace, func() ([]*repository.Policy, error) {
+		return s.repo.ListPoliciesByMarketplace(ctx, userID, marketplace)
+	})
+}
+
+// ListPoliciesWithFilter retrieves the policies matching filter, first checking the cache.
+func (s *PolicyService) ListPoliciesWithFilter(ctx context.Context, userID uuid.UUID, filter repository.PolicyFilter) ([]*repository.Policy, error) {
+	filter.Tags = normalizeTags(filter.Tags)
+	return s.cachedList(ctx, userID, "filter:"+filterCacheKey(filter), func() ([]*repository.Policy, error) {
+		return s.repo.ListPoliciesWithFilter(ctx, userID, filter)
+	})
 }
 
-// UpdatePolicy updates an existing policy.
-func (s *PolicyService) UpdatePolicy(ctx context.Context, userID uuid.UUID, id, name, script string) (*repository.Policy, error) {
+// SearchPolicies runs a full-text search over policy names and descriptions, best matches first.
+func (s *PolicyService) SearchPolicies(ctx context.Context, userID uuid.UUID, query, marketplace string, limit int) ([]*repository.PolicySearchResult, error) {
+	return s.repo.SearchPolicies(ctx, userID, query, marketplace, limit)
+}
+
+// UpdatePolicy updates an existing policy. Once a policy has been published, a changed script
+// is saved as its draft rather than replacing the published script, and goes live only when
+// the draft is published.
+func (s *PolicyService) UpdatePolicy(ctx context.Context, userID uuid.UUID, id string, fields PolicyFields) (*repository.Policy, error) {
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, nil
@@ -90,21 +194,198 @@ func (s *PolicyService) UpdatePolicy(ctx context.Context, userID uuid.UUID, id,
 	if err != nil || existing == nil {
 		return nil, err
 	}
+	if existing.Status == repository.StatusArchived {
+		return nil, ErrPolicyArchived
+	}
+
+	updated, err := s.updatePolicy(ctx, userID, existing, fields)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidatePolicy(ctx, userID.String(), id) // Invalidate cache for updated policy
+	return updated, nil
+}
 
-	existing.Name = name
-	existing.Script = script
-	err = s.repo.UpdatePolicy(ctx, userID, existing)
-	if err == nil {
-		_ = s.cache.Delete(ctx, userID.String()+":policy:"+existing.ID.Hex()) // Invalidate cache for updated policy
+// updatePolicy applies fields to existing and saves it, returning ErrPolicyChanged if the
+// policy changed since existing was read.
+func (s *PolicyService) updatePolicy(ctx context.Context, userID uuid.UUID, existing *repository.Policy, fields PolicyFields) (*repository.Policy, error) {
+	baseScript, baseDraft := existing.Script, existing.DraftScript
+	s.applyEdit(existing, fields)
+	updated, err := s.repo.UpdatePolicy(ctx, userID, existing, baseScript, baseDraft)
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		return nil, ErrPolicyChanged
 	}
-	return existing, err
+	return updated, nil
 }
 
-// DeletePolicy deletes a policy.
+// DeletePolicy moves a policy to the trash. Policies that are still assigned to entities
+// cannot be deleted, as their assignments would be left pointing at nothing.
 func (s *PolicyService) DeletePolicy(ctx context.Context, userID uuid.UUID, id string) (bool, error) {
+	if err := s.checkUnassigned(ctx, userID, id); err != nil {
+		return false, err
+	}
 	policy, err := s.repo.DeletePolicy(ctx, userID, id)
 	if err == nil {
-		_ = s.cache.Delete(ctx, userID.String()+":policy:"+id) // Invalidate cache for deleted policy
+		s.invalidatePolicy(ctx, userID.String(), id) // Invalidate cache for deleted policy
 	}
 	return policy != nil, err
 }
+
+// checkUnassigned returns ErrPolicyAssigned if the policy is assigned to any entity.
+func (s *PolicyService) checkUnassigned(ctx context.Context, userID uuid.UUID, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil
+	}
+	n, err := s.assignments.CountPolicyAssignments(ctx, userID, objID)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("%w: it is assigned to %d entities", ErrPolicyAssigned, n)
+	}
+	return nil
+}
+
+// ListDeletedPolicies retrieves the policies in the user's trash.
+func (s *PolicyService) ListDeletedPolicies(ctx context.Context, userID uuid.UUID) ([]*repository.Policy, error) {
+	return s.repo.ListDeletedPolicies(ctx, userID)
+}
+
+// RestorePolicy takes a policy out of the trash.
+func (s *PolicyService) RestorePolicy(ctx context.Context, userID uuid.UUID, id string) (*repository.Policy, error) {
+	policy, err := s.repo.RestorePolicy(ctx, userID, id)
+	if err == nil && policy != nil {
+		s.invalidatePolicy(ctx, userID.String(), id) // Drop the cached not-found result and stale lists
+	}
+	return policy, err
+}
+
+// EvictPolicy drops the cached lookups of a policy, and the owner's cached listings, after it
+// was changed outside the service.
+func (s *PolicyService) EvictPolicy(ctx context.Context, userID, id string) {
+	s.invalidatePolicy(ctx, userID, id)
+}
+
+// RecordChange records the event of a change to a policy made outside the service, so that
+// webhooks, the event stream and the change feed see it like any other. The change is
+// recorded once, however many instances report it.
+func (s *PolicyService) RecordChange(ctx context.Context, change *repository.PolicyChange, eventType string) error {
+	p := change.Policy
+	if p == nil {
+		// Purged without a pre-image, so only the policy's ID is known
+		p = &repository.Policy{ID: change.PolicyID}
+	}
+	_, err := s.repo.RecordChange(ctx, eventType, p, change.ID, change.At)
+	return err
+}
+
+// newPolicy builds a policy for insertion, with an empty rather than nil tag list.
+func (s *PolicyService) newPolicy(userID uuid.UUID, marketplace, status string, fields PolicyFields) *repository.Policy {
+	if status == "" {
+		status = repository.StatusActive
+	}
+	p := &repository.Policy{
+		UserID:      userID.String(),
+		Marketplace: marketplace,
+		Status:      status,
+		Tags:        []string{},
+	}
+	s.applyFields(p, fields)
+	return p
+}
+
+// applyEdit copies fields onto a stored policy. Unless the policy is still a draft, a changed
+// script is kept as its draft script so that the published script is left alone.
+func (s *PolicyService) applyEdit(p *repository.Policy, fields PolicyFields) {
+	if p.Status != repository.StatusDraft && fields.Script != p.Script {
+		draft := fields.Script
+		p.DraftScript = &draft
+		fields.Script = p.Script
+	}
+	s.applyFields(p, fields)
+}
+
+// applyFields copies fields onto p and refreshes its summary to match the new script.
+func (s *PolicyService) applyFields(p *repository.Policy, fields PolicyFields) {
+	fields.applyTo(p)
+	p.Summary = s.summarize(p.Script, p.Dayparts)
+}
+
+// summarize derives the stored summary of a script together with the scripts of dayparts,
+// which run in its place, so that filtering by metric or operator finds a policy that only
+// uses one in a daypart. Scripts are validated before they reach the service, so a script that
+// cannot be parsed is left without a summary, and such a daypart is left out of it.
+func (s *PolicyService) summarize(script string, dayparts []repository.Daypart) *repository.PolicySummary {
+	root := s.converter.ScriptToTree(script)
+	if root == nil {
+		return nil
+	}
+	roots := []*convert.Node{root}
+	for _, daypart := range dayparts {
+		roots = append(roots, s.converter.ScriptToTree(daypart.Script))
+	}
+
+	tree := convert.Summarize(roots...)
+	summary := &repository.PolicySummary{
+		Metrics:             make([]string, 0, len(tree.Metrics)),
+		MaxDepth:            tree.MaxDepth,
+		BranchCount:         tree.BranchCount,
+		Operators:           make([]string, 0, len(tree.Operators)),
+		MinBidChange:        tree.MinBidChange,
+		MaxBidChange:        tree.MaxBidChange,
+		MinPercentBidChange: tree.MinPercentBidChange,
+		MaxPercentBidChange: tree.MaxPercentBidChange,
+		DaypartCount:        len(dayparts),
+	}
+	for _, metric := range tree.Metrics {
+		summary.Metrics = append(summary.Metrics, string(metric))
+	}
+	for _, operator := range tree.Operators {
+		summary.Operators = append(summary.Operators, string(rune(operator)))
+	}
+	return summary
+}
+
+// BackfillSummaries stores summaries for policies whose summary is missing or predates the
+// current form, such as those created before summaries covered dayparts.
+func (s *PolicyService) BackfillSummaries(ctx context.Context) error {
+	const pageSize = 100
+
+	var afterID primitive.ObjectID
+	for {
+		policies, err := s.repo.ListPoliciesWithoutSummary(ctx, afterID, pageSize)
+		if err != nil {
+			return err
+		}
+		for _, p := range policies {
+			afterID = p.ID
+			summary := s.summarize(p.Script, p.Dayparts)
+			if summary == nil {
+				log.Printf("skipping summary for policy %s: script could not be parsed\n", p.ID.Hex())
+				continue
+			}
+			if err := s.repo.SetPolicySummary(ctx, p, summary); err != nil {
+				return err
+			}
+		}
+		if len(policies) < pageSize {
+			return nil
+		}
+	}
+}
+
+// normalizeTags lower-cases and trims tags, dropping blanks and duplicates.
+func normalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
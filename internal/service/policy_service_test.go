@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/cache"
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestApplyEditKeepsPublishedScript(t *testing.T) {
+	s := &PolicyService{converter: NewConvertService()}
+
+	draft := &repository.Policy{Status: repository.StatusDraft, Script: "=1.00"}
+	s.applyEdit(draft, PolicyFields{Name: "a", Script: "=2.00"})
+	if draft.Script != "=2.00" || draft.DraftScript != nil {
+		t.Fatalf("expected an unpublished policy to take the script, got %q (draft %v)", draft.Script, draft.DraftScript)
+	}
+
+	live := &repository.Policy{Status: repository.StatusActive, Script: "=1.00"}
+	s.applyEdit(live, PolicyFields{Name: "b", Script: "=2.00"})
+	if live.Script != "=1.00" {
+		t.Fatalf("expected the published script to be kept, got %q", live.Script)
+	}
+	if live.DraftScript == nil || *live.DraftScript != "=2.00" {
+		t.Fatalf("expected the edit to be saved as the draft, got %v", live.DraftScript)
+	}
+	if live.Name != "b" {
+		t.Fatalf("expected other fields to be updated, got name %q", live.Name)
+	}
+
+	s.applyEdit(live, PolicyFields{Name: "c", Script: "=1.00"})
+	if live.DraftScript == nil || *live.DraftScript != "=2.00" {
+		t.Fatalf("expected an unchanged script to leave the draft alone, got %v", live.DraftScript)
+	}
+}
+
+// transactionalRepo runs transactions by calling fn directly.
+type transactionalRepo struct {
+	repository.PolicyRepository
+}
+
+func (transactionalRepo) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func TestWithTransactionInvalidatesOnCommit(t *testing.T) {
+	ctx := context.Background()
+	requestCache := cache.NewMemoryCache(10)
+	s := &PolicyService{repo: transactionalRepo{}, cache: requestCache}
+	key := "u:policy:p"
+
+	_ = requestCache.Set(ctx, key, "cached", time.Minute)
+	err := s.WithTransaction(ctx, func(ctx context.Context) error {
+		s.invalidatePolicy(ctx, "u", "p")
+		if _, _, err := requestCache.Get(ctx, key); err != nil {
+			t.Fatalf("expected the entry to survive until the transaction commits, got %v", err)
+		}
+		return errors.New("abort")
+	})
+	if err == nil {
+		t.Fatal("expected the transaction to fail")
+	}
+	if _, _, err := requestCache.Get(ctx, key); err != nil {
+		t.Fatalf("expected an aborted transaction to leave the entry alone, got %v", err)
+	}
+
+	if err := s.WithTransaction(ctx, func(ctx context.Context) error {
+		s.invalidatePolicy(ctx, "u", "p")
+		return nil
+	}); err != nil {
+		t.Fatalf("expected the transaction to commit, got %v", err)
+	}
+	if _, _, err := requestCache.Get(ctx, key); !errors.Is(err, cache.ErrNotFound) {
+		t.Fatalf("expected the entry to be dropped on commit, got %v", err)
+	}
+}
+
+// racedPolicies is a policy repository whose policy changes after every read.
+type racedPolicies struct {
+	memoryPolicies
+	baseScript string
+	baseDraft  *string
+}
+
+func (r *racedPolicies) UpdatePolicy(_ context.Context, _ uuid.UUID, _ *repository.Policy, baseScript string, baseDraft *string) (*repository.Policy, error) {
+	r.baseScript, r.baseDraft = baseScript, baseDraft
+	return nil, nil
+}
+
+func TestUpdatePolicyReportsConcurrentChange(t *testing.T) {
+	ctx := context.Background()
+	draft := "=3.00"
+	p := &repository.Policy{ID: primitive.NewObjectID(), Status: repository.StatusActive, Script: "=1.00", DraftScript: &draft}
+	repo := &racedPolicies{memoryPolicies: memoryPolicies{policies: map[primitive.ObjectID]*repository.Policy{p.ID: p}}}
+	s := &PolicyService{repo: repo, cache: cache.NewMemoryCache(10), converter: NewConvertService()}
+
+	_, err := s.UpdatePolicy(ctx, uuid.New(), p.ID.Hex(), PolicyFields{Name: "a", Script: "=2.00"})
+	if !errors.Is(err, ErrPolicyChanged) {
+		t.Fatalf("expected ErrPolicyChanged, got %v", err)
+	}
+	if repo.baseScript != "=1.00" || repo.baseDraft == nil || *repo.baseDraft != "=3.00" {
+		t.Fatalf("expected the update to be conditional on the scripts read, got %q and %v", repo.baseScript, repo.baseDraft)
+	}
+}
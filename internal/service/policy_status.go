@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrInvalidTransition = errors.New("invalid status transition")
+	ErrPolicyArchived    = errors.New("archived policies cannot be edited")
+)
+
+// statusTransitions lists the statuses each status may move to. Archived is final.
+var statusTransitions = map[string][]string{
+	repository.StatusDraft:    {repository.StatusActive, repository.StatusArchived},
+	repository.StatusActive:   {repository.StatusPaused, repository.StatusArchived},
+	repository.StatusPaused:   {repository.StatusActive, repository.StatusArchived},
+	repository.StatusArchived: {},
+}
+
+// CanTransition reports whether a policy may move from one status to another.
+func CanTransition(from, to string) bool {
+	return slices.Contains(statusTransitions[from], to)
+}
+
+// TransitionPolicy moves a policy to status to, returning ErrInvalidTransition if its
+// current status does not allow it.
+func (s *PolicyService) TransitionPolicy(ctx context.Context, userID uuid.UUID, id, to string) (*repository.Policy, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	existing, err := s.repo.GetPolicy(ctx, userID, objID)
+	if err != nil || existing == nil {
+		return nil, err
+	}
+	if !CanTransition(existing.Status, to) {
+		return nil, fmt.Errorf("%w: cannot move from %s to %s", ErrInvalidTransition, existing.Status, to)
+	}
+
+	// The update only applies if nobody changed the status since it was read
+	updated, err := s.repo.SetPolicyStatus(ctx, userID, objID, existing.Status, to)
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		return nil, fmt.Errorf("%w: policy status changed concurrently", ErrInvalidTransition)
+	}
+
+	s.invalidatePolicy(ctx, userID.String(), id)
+	return updated, nil
+}
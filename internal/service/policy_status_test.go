@@ -0,0 +1,34 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{repository.StatusDraft, repository.StatusActive, true},
+		{repository.StatusDraft, repository.StatusPaused, false},
+		{repository.StatusDraft, repository.StatusArchived, true},
+		{repository.StatusActive, repository.StatusPaused, true},
+		{repository.StatusActive, repository.StatusDraft, false},
+		{repository.StatusActive, repository.StatusArchived, true},
+		{repository.StatusPaused, repository.StatusActive, true},
+		{repository.StatusPaused, repository.StatusArchived, true},
+		{repository.StatusArchived, repository.StatusActive, false},
+		{repository.StatusArchived, repository.StatusDraft, false},
+		{repository.StatusActive, repository.StatusActive, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.from+"->"+test.to, func(t *testing.T) {
+			if got := CanTransition(test.from, test.to); got != test.allowed {
+				t.Fatalf("CanTransition(%q, %q) = %v, want %v", test.from, test.to, got, test.allowed)
+			}
+		})
+	}
+}
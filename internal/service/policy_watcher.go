@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/events"
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// policyStreamName is the name the policies change stream's resume token is saved under.
+// Instances share it, so a restarted instance resumes from the last change any of them saw.
+const policyStreamName = "policies"
+
+// tokenSaveInterval is how often the instance holding the resume token's lease saves it. A
+// restart can replay the changes of up to this long, which only evicts and publishes again.
+const tokenSaveInterval = 5 * time.Second
+
+// tokenLease is how long an instance keeps the right to save the resume token after saving it.
+// Once it lapses, say because the instance stopped, the next instance to save takes over.
+const tokenLease = 3 * tokenSaveInterval
+
+// PolicyWatcher follows the policies change stream, so that changes made directly in the
+// database, such as by migrations or support scripts, evict the cache like changes made
+// through the service, and are recorded in the outbox so that webhooks, the event stream and
+// the change feed see them too. Every change is also published on the event bus. Every
+// instance follows the stream, but only the one holding the resume token's lease saves the
+// token.
+type PolicyWatcher struct {
+	changes       repository.ChangeStreamRepository
+	policies      *PolicyService
+	bus           *events.Bus
+	retryInterval time.Duration
+	instance      string // owns the resume token's lease while this instance saves it
+	savedAt       time.Time
+}
+
+// NewPolicyWatcher creates a PolicyWatcher that reopens a failed stream after retryInterval.
+func NewPolicyWatcher(changes repository.ChangeStreamRepository, policies *PolicyService, bus *events.Bus, retryInterval time.Duration) *PolicyWatcher {
+	return &PolicyWatcher{changes: changes, policies: policies, bus: bus, retryInterval: retryInterval, instance: uuid.NewString()}
+}
+
+// Run watches for changes until ctx is cancelled, resuming after the last change handled
+// whenever the stream is reopened, including after a restart.
+func (w *PolicyWatcher) Run(ctx context.Context) {
+	for {
+		err := w.watch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, repository.ErrResumeTokenLost) {
+			// Changes since the token are gone from the oplog; the cache catches up as entries expire
+			log.Printf("policy change stream cannot resume, restarting from now: %v\n", err)
+			if err := w.changes.ClearResumeToken(ctx, policyStreamName); err == nil {
+				continue
+			}
+		} else {
+			log.Printf("policy change stream failed: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.retryInterval):
+		}
+	}
+}
+
+func (w *PolicyWatcher) watch(ctx context.Context) error {
+	token, err := w.changes.GetResumeToken(ctx, policyStreamName)
+	if err != nil {
+		return err
+	}
+	return w.changes.WatchPolicies(ctx, token, func(change *repository.PolicyChange, token bson.Raw) error {
+		if err := w.handle(ctx, change); err != nil {
+			return err
+		}
+		return w.saveToken(ctx, token)
+	})
+}
+
+// handle evicts the changed policy from the cache and publishes the change, first recording
+// its event if it was made outside the service. If that fails, the stream is reopened from the
+// last saved token, so the change is handled again.
+func (w *PolicyWatcher) handle(ctx context.Context, change *repository.PolicyChange) error {
+	id := change.PolicyID.Hex()
+	if change.Policy == nil && change.Operation != repository.ChangeDelete {
+		// The policy was purged before the change could be read back. The purge follows on
+		// the stream and is published then, so this change is only evicted.
+		if change.Previous != nil {
+			w.policies.EvictPolicy(ctx, change.Previous.UserID, id)
+		} else {
+			log.Printf("policy %s was purged before its %s could be read, its cache entries expire on their own\n", id, change.Operation)
+		}
+		return nil
+	}
+
+	e := policyEvent(change)
+	if e.UserID != "" {
+		w.policies.EvictPolicy(ctx, e.UserID, e.PolicyID)
+	} else {
+		log.Printf("policy %s was purged without a pre-image, its cache entries expire on their own\n", e.PolicyID)
+	}
+	if !change.Transactional {
+		if err := w.policies.RecordChange(ctx, change, e.Type); err != nil {
+			return err
+		}
+	}
+	// Published only once recorded, so that event streams woken by it find the event
+	w.bus.Publish(e)
+	return nil
+}
+
+// saveToken saves token as the one to resume after, at most every tokenSaveInterval and only
+// while this instance holds the token's lease or can take it over.
+func (w *PolicyWatcher) saveToken(ctx context.Context, token bson.Raw) error {
+	if time.Since(w.savedAt) < tokenSaveInterval {
+		return nil
+	}
+	if _, err := w.changes.SaveResumeToken(ctx, policyStreamName, w.instance, token, tokenLease); err != nil {
+		return err
+	}
+	// Instances without the lease wait as long before trying again
+	w.savedAt = time.Now()
+	return nil
+}
+
+// policyEvent normalises a change stream change into a policy event.
+func policyEvent(change *repository.PolicyChange) events.PolicyEvent {
+	e := events.PolicyEvent{PolicyID: change.PolicyID.Hex(), OccurredAt: change.At}
+	if change.Policy != nil {
+		e.UserID = change.Policy.UserID
+		e.Marketplace = change.Policy.Marketplace
+	}
+
+	switch {
+	case change.Operation == repository.ChangeInsert:
+		e.Type = events.PolicyCreated
+	case change.Operation == repository.ChangeDelete:
+		e.Type = events.PolicyPurged
+		return e
+	case change.Policy != nil && change.Policy.DeletedAt != nil:
+		e.Type = events.PolicyDeleted
+	default:
+		e.Type = events.PolicyUpdated
+	}
+	e.Policy = change.Policy
+	return e
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/cache"
+	"github.com/LittleAksMax/bids-policy-service/internal/events"
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPolicyEvent(t *testing.T) {
+	deletedAt := time.Now()
+	live := &repository.Policy{UserID: "user", Marketplace: "UK"}
+	trashed := &repository.Policy{UserID: "user", Marketplace: "UK", DeletedAt: &deletedAt}
+
+	tests := []struct {
+		name       string
+		change     repository.PolicyChange
+		wantType   string
+		wantUser   string
+		wantPolicy bool
+	}{
+		{"insert", repository.PolicyChange{Operation: repository.ChangeInsert, Policy: live}, events.PolicyCreated, "user", true},
+		{"update", repository.PolicyChange{Operation: repository.ChangeUpdate, Policy: live}, events.PolicyUpdated, "user", true},
+		{"replace", repository.PolicyChange{Operation: repository.ChangeReplace, Policy: live}, events.PolicyUpdated, "user", true},
+		{"moved to trash", repository.PolicyChange{Operation: repository.ChangeUpdate, Policy: trashed}, events.PolicyDeleted, "user", true},
+		{"purged", repository.PolicyChange{Operation: repository.ChangeDelete, Policy: trashed}, events.PolicyPurged, "user", false},
+		{"purged without pre-image", repository.PolicyChange{Operation: repository.ChangeDelete}, events.PolicyPurged, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change.PolicyID = primitive.NewObjectID()
+			e := policyEvent(&tt.change)
+			if e.Type != tt.wantType || e.UserID != tt.wantUser || (e.Policy != nil) != tt.wantPolicy {
+				t.Fatalf("got %+v", e)
+			}
+			if e.PolicyID != tt.change.PolicyID.Hex() {
+				t.Fatalf("expected policy ID %s, got %s", tt.change.PolicyID.Hex(), e.PolicyID)
+			}
+		})
+	}
+}
+
+func TestWatcherEvictsUpdateOfPurgedPolicy(t *testing.T) {
+	ctx := context.Background()
+	requestCache := cache.NewMemoryCache(10)
+	bus := events.NewBus()
+	published, unsubscribe := bus.Subscribe(1)
+	defer unsubscribe()
+	w := NewPolicyWatcher(nil, &PolicyService{cache: requestCache}, bus, time.Minute)
+
+	id := primitive.NewObjectID()
+	key := "user:policy:" + id.Hex()
+	_ = requestCache.Set(ctx, key, "cached", time.Minute)
+
+	err := w.handle(ctx, &repository.PolicyChange{
+		Operation: repository.ChangeUpdate,
+		PolicyID:  id,
+		Previous:  &repository.Policy{UserID: "user"},
+	})
+	if err != nil {
+		t.Fatalf("expected the update to be handled, got %v", err)
+	}
+
+	if _, _, err := requestCache.Get(ctx, key); !errors.Is(err, cache.ErrNotFound) {
+		t.Fatalf("expected the policy to be evicted, got %v", err)
+	}
+	select {
+	case e := <-published:
+		t.Fatalf("expected the update to be left for the purge to publish, got %+v", e)
+	default:
+	}
+}
+
+// recordedChanges records the changes the watcher hands to RecordChange.
+type recordedChanges struct {
+	memoryPolicies
+	recorded []string
+}
+
+func (m *recordedChanges) RecordChange(_ context.Context, eventType string, _ *repository.Policy, changeID string, _ time.Time) (bool, error) {
+	m.recorded = append(m.recorded, eventType+" "+changeID)
+	return true, nil
+}
+
+func TestWatcherRecordsChangesMadeOutsideTheService(t *testing.T) {
+	ctx := context.Background()
+	repo := &recordedChanges{}
+	bus := events.NewBus()
+	published, unsubscribe := bus.Subscribe(2)
+	defer unsubscribe()
+	w := NewPolicyWatcher(nil, &PolicyService{repo: repo, cache: cache.NewMemoryCache(10)}, bus, time.Minute)
+
+	policy := &repository.Policy{ID: primitive.NewObjectID(), UserID: "user"}
+	for _, change := range []*repository.PolicyChange{
+		{ID: "service", Operation: repository.ChangeUpdate, PolicyID: policy.ID, Policy: policy, Transactional: true},
+		{ID: "direct", Operation: repository.ChangeUpdate, PolicyID: policy.ID, Policy: policy},
+	} {
+		if err := w.handle(ctx, change); err != nil {
+			t.Fatalf("expected the %s change to be handled, got %v", change.ID, err)
+		}
+		<-published
+	}
+
+	if len(repo.recorded) != 1 || repo.recorded[0] != events.PolicyUpdated+" direct" {
+		t.Fatalf("expected only the direct change to be recorded, got %v", repo.recorded)
+	}
+}
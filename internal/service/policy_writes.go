@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The methods in this file change policies on behalf of the other services, such as approvals
+// and scheduling, so that those changes keep the summary and the cache in step like any other.
+
+type pendingInvalidationsKey struct{}
+
+// pendingInvalidations collects the policies changed inside a transaction, whose cache entries
+// are dropped only once it commits; dropping them earlier would let a concurrent read cache
+// the state from before the transaction again.
+type pendingInvalidations struct {
+	mu       sync.Mutex
+	policies []policyRef
+}
+
+type policyRef struct {
+	userID string
+	id     string
+}
+
+// WithTransaction runs fn inside a database transaction. Policy changes made through the
+// service with the context passed to fn take part in it, and their cache entries are dropped
+// once it commits. fn may be retried, so it must be safe to run again.
+func (s *PolicyService) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	pending := &pendingInvalidations{}
+	if err := s.repo.WithTransaction(context.WithValue(ctx, pendingInvalidationsKey{}, pending), fn); err != nil {
+		return err
+	}
+	for _, ref := range pending.policies {
+		s.invalidatePolicy(ctx, ref.userID, ref.id)
+	}
+	return nil
+}
+
+// deferInvalidation queues the cache entries of the given policies to be dropped when the
+// transaction running under ctx commits, reporting false if ctx is not in one.
+func deferInvalidation(ctx context.Context, userID string, ids ...string) bool {
+	pending, ok := ctx.Value(pendingInvalidationsKey{}).(*pendingInvalidations)
+	if !ok {
+		return false
+	}
+	pending.mu.Lock()
+	defer pending.mu.Unlock()
+	for _, id := range ids {
+		pending.policies = append(pending.policies, policyRef{userID: userID, id: id})
+	}
+	return true
+}
+
+// FindPolicy reads a policy straight from the database, bypassing the cache, for callers that
+// are about to change it. It returns nil if the policy does not exist.
+func (s *PolicyService) FindPolicy(ctx context.Context, userID uuid.UUID, id primitive.ObjectID) (*repository.Policy, error) {
+	return s.repo.GetPolicy(ctx, userID, id)
+}
+
+// ApplyScript replaces the published script of a policy, and its summary, with script. It
+// returns nil if the policy does not exist, is archived, or no longer publishes baseScript.
+func (s *PolicyService) ApplyScript(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, baseScript, script string) (*repository.Policy, error) {
+	// The summary covers the dayparts too, so it is built from the ones the policy has now
+	current, err := s.repo.GetPolicy(ctx, userID, id)
+	if err != nil || current == nil {
+		return nil, err
+	}
+	summary := s.summarize(script, current.Dayparts)
+	updated, err := s.repo.ApplyScript(ctx, userID, id, baseScript, current.Dayparts, script, summary)
+	if err != nil || updated == nil {
+		return nil, err
+	}
+	s.invalidatePolicy(ctx, userID.String(), id.Hex())
+	return updated, nil
+}
+
+// ApplyDayparts replaces a policy's dayparts, and refreshes its summary. It returns nil if the
+// policy does not exist, is archived, or no longer has the dayparts in base.
+func (s *PolicyService) ApplyDayparts(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, base, dayparts []repository.Daypart) (*repository.Policy, error) {
+	current, err := s.repo.GetPolicy(ctx, userID, id)
+	if err != nil || current == nil {
+		return nil, err
+	}
+	summary := s.summarize(current.Script, dayparts)
+	updated, err := s.repo.ApplyDayparts(ctx, userID, id, current.Script, base, dayparts, summary)
+	if err != nil || updated == nil {
+		return nil, err
+	}
+	s.invalidatePolicy(ctx, userID.String(), id.Hex())
+	return updated, nil
+}
+
+// TakeDraft clears a policy's draft so that it can be submitted for approval or scheduled. It
+// returns nil if the policy no longer has draft as its draft.
+func (s *PolicyService) TakeDraft(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, draft string) (*repository.Policy, error) {
+	taken, err := s.repo.TakeDraftScript(ctx, userID, id, draft)
+	if err != nil || taken == nil {
+		return nil, err
+	}
+	s.invalidatePolicy(ctx, userID.String(), id.Hex())
+	return taken, nil
+}
+
+// SetPolicyWindow sets the window in which a policy is active; nil bounds are removed. It
+// returns nil if the policy does not exist or is archived.
+func (s *PolicyService) SetPolicyWindow(ctx context.Context, userID uuid.UUID, id primitive.ObjectID, from, until *time.Time) (*repository.Policy, error) {
+	updated, err := s.repo.SetPolicyWindow(ctx, userID, id, from, until)
+	if err != nil || updated == nil {
+		return nil, err
+	}
+	s.invalidatePolicy(ctx, userID.String(), id.Hex())
+	return updated, nil
+}
+
+// ListPoliciesWithDueWindow lists policies, across all users, whose window opens or closes at
+// or before now.
+func (s *PolicyService) ListPoliciesWithDueWindow(ctx context.Context, now time.Time) ([]*repository.Policy, error) {
+	return s.repo.ListPoliciesWithDueWindow(ctx, now)
+}
+
+// AdvancePolicyWindow sets the status and remaining window bounds of p once the scheduler has
+// acted on its window. It returns nil if p changed since it was listed.
+func (s *PolicyService) AdvancePolicyWindow(ctx context.Context, p *repository.Policy, status string, from, until *time.Time) (*repository.Policy, error) {
+	updated, err := s.repo.AdvancePolicyWindow(ctx, p, status, from, until)
+	if err != nil || updated == nil {
+		return nil, err
+	}
+	s.invalidatePolicy(ctx, p.UserID, p.ID.Hex())
+	return updated, nil
+}
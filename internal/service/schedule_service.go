@@ -0,0 +1,335 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrInvalidWindow       = errors.New("invalid schedule window")
+	ErrScheduleConflict    = errors.New("window overlaps another scheduled change for this policy")
+	ErrNoDraftToSchedule   = errors.New("policy has no draft to schedule")
+	errScheduledPolicyGone = errors.New("policy changed before the scheduled change ran")
+)
+
+// ScheduleService schedules policy changes ahead of time. A policy can carry a window in which
+// it is active, and its draft can be scheduled to replace the published script for a window.
+// Times without an offset are read in the policy's marketplace time zone.
+type ScheduleService struct {
+	schedules repository.ScheduleRepository
+	policies  *PolicyService
+}
+
+// ScheduleServiceInterface defines the contract for scheduling policy changes.
+type ScheduleServiceInterface interface {
+	SetPolicyWindow(ctx context.Context, userID uuid.UUID, id, from, until string) (*repository.Policy, error)
+	ScheduleDraft(ctx context.Context, userID uuid.UUID, id, from, until string) (*repository.ScheduledChange, *repository.ChangeRequest, error)
+	ListScheduledChanges(ctx context.Context, userID uuid.UUID, id string) ([]*repository.ScheduledChange, error)
+	CancelScheduledChange(ctx context.Context, userID uuid.UUID, id, changeID string) (*repository.ScheduledChange, error)
+}
+
+// NewScheduleService creates a new ScheduleService
+func NewScheduleService(schedules repository.ScheduleRepository, policies *PolicyService) *ScheduleService {
+	return &ScheduleService{schedules: schedules, policies: policies}
+}
+
+// SetPolicyWindow sets when a policy becomes active and when it is paused again. Empty bounds
+// are cleared, so clearing both removes the window.
+func (s *ScheduleService) SetPolicyWindow(ctx context.Context, userID uuid.UUID, id, from, until string) (*repository.Policy, error) {
+	existing, err := s.loadPolicy(ctx, userID, id)
+	if err != nil || existing == nil {
+		return nil, err
+	}
+
+	fromTime, untilTime, err := parseWindow(existing.Marketplace, from, until)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := s.policies.SetPolicyWindow(ctx, userID, existing.ID, fromTime, untilTime)
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		return nil, ErrPolicyArchived
+	}
+	return updated, nil
+}
+
+// ScheduleDraft moves the policy's draft into a change that replaces the published script at
+// from and, if until is given, reverts it at until. In accounts that require approval, drafts
+// of live policies are submitted as a change request carrying the window instead.
+func (s *ScheduleService) ScheduleDraft(ctx context.Context, userID uuid.UUID, id, from, until string) (*repository.ScheduledChange, *repository.ChangeRequest, error) {
+	existing, err := s.loadPolicy(ctx, userID, id)
+	if err != nil || existing == nil {
+		return nil, nil, err
+	}
+	if existing.DraftScript == nil {
+		return nil, nil, ErrNoDraftToSchedule
+	}
+	if err := s.policies.checkPublishable(*existing.DraftScript); err != nil {
+		return nil, nil, err
+	}
+
+	fromTime, untilTime, err := parseWindow(existing.Marketplace, from, until)
+	if err != nil {
+		return nil, nil, err
+	}
+	if fromTime == nil {
+		return nil, nil, fmt.Errorf("%w: effective_from is required", ErrInvalidWindow)
+	}
+	if err := s.checkOverlap(ctx, existing.ID, *fromTime, untilTime); err != nil {
+		return nil, nil, err
+	}
+
+	if needsApproval(existing.Status) {
+		required, err := s.policies.requiresApproval(ctx, userID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if required {
+			cr, err := s.policies.submitChangeRequest(ctx, userID, existing, fromTime, untilTime)
+			return nil, cr, err
+		}
+	}
+
+	change := newScheduledChange(existing.ID, userID.String(), *existing.DraftScript, *fromTime, untilTime)
+	err = s.policies.WithTransaction(ctx, func(ctx context.Context) error {
+		change.ID = primitive.NilObjectID
+		taken, err := s.policies.TakeDraft(ctx, userID, existing.ID, *existing.DraftScript)
+		if err != nil {
+			return err
+		}
+		if taken == nil {
+			return fmt.Errorf("%w: policy changed while scheduling", ErrInvalidTransition)
+		}
+		return s.schedules.CreateScheduledChange(ctx, change)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return change, nil, nil
+}
+
+// ListScheduledChanges lists every change scheduled for a policy, or nil if the policy does not exist.
+func (s *ScheduleService) ListScheduledChanges(ctx context.Context, userID uuid.UUID, id string) ([]*repository.ScheduledChange, error) {
+	existing, err := s.loadPolicy(ctx, userID, id)
+	if err != nil || existing == nil {
+		return nil, err
+	}
+	return s.schedules.ListScheduledChanges(ctx, userID, existing.ID)
+}
+
+// CancelScheduledChange cancels a change that has not been applied yet.
+func (s *ScheduleService) CancelScheduledChange(ctx context.Context, userID uuid.UUID, id, changeID string) (*repository.ScheduledChange, error) {
+	policyID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+	changeObjID, err := primitive.ObjectIDFromHex(changeID)
+	if err != nil {
+		return nil, nil
+	}
+	return s.schedules.CancelScheduledChange(ctx, userID, policyID, changeObjID)
+}
+
+// ApplyDue opens and closes policy windows and applies and reverts scheduled changes that
+// fall due at or before now. Each item is handled on its own, so one failure does not hold up the rest.
+func (s *ScheduleService) ApplyDue(ctx context.Context, now time.Time) error {
+	policies, err := s.policies.ListPoliciesWithDueWindow(ctx, now)
+	if err != nil {
+		return err
+	}
+	for _, p := range policies {
+		if err := s.advanceWindow(ctx, p, now); err != nil {
+			log.Printf("failed to advance window of policy %s: %v\n", p.ID.Hex(), err)
+		}
+	}
+
+	changes, err := s.schedules.ListDueScheduledChanges(ctx, now)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if change.Status == repository.ScheduledChangeScheduled {
+			err = s.applyScheduledChange(ctx, change, now)
+		} else {
+			err = s.revertScheduledChange(ctx, change, now)
+		}
+		if err != nil {
+			log.Printf("failed to run scheduled change %s: %v\n", change.ID.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// advanceWindow activates a policy whose window has opened and pauses one whose window has
+// closed, clearing each bound once it has been acted on. A window that opened and closed
+// while the scheduler was not running leaves the policy inactive.
+func (s *ScheduleService) advanceWindow(ctx context.Context, p *repository.Policy, now time.Time) error {
+	status, from, until := p.Status, p.EffectiveFrom, p.EffectiveUntil
+
+	if from != nil && !from.After(now) {
+		from = nil
+		if (until == nil || until.After(now)) && status != repository.StatusActive && CanTransition(status, repository.StatusActive) {
+			status = repository.StatusActive
+		}
+	}
+	if from == nil && until != nil && !until.After(now) {
+		until = nil
+		if status == repository.StatusActive {
+			status = repository.StatusPaused
+		}
+	}
+
+	_, err := s.policies.AdvancePolicyWindow(ctx, p, status, from, until)
+	return err
+}
+
+func (s *ScheduleService) applyScheduledChange(ctx context.Context, change *repository.ScheduledChange, now time.Time) error {
+	if change.EffectiveUntil != nil && !change.EffectiveUntil.After(now) {
+		_, err := s.schedules.MarkScheduledChangeFailed(ctx, change.ID, repository.ScheduledChangeScheduled, "window ended before the change could be applied")
+		return err
+	}
+
+	ownerID, err := uuid.Parse(change.OwnerID)
+	if err != nil {
+		return err
+	}
+	p, err := s.policies.FindPolicy(ctx, ownerID, change.PolicyID)
+	if err != nil {
+		return err
+	}
+	if p == nil || p.Status == repository.StatusArchived {
+		_, err := s.schedules.MarkScheduledChangeFailed(ctx, change.ID, repository.ScheduledChangeScheduled, "policy was deleted or archived")
+		return err
+	}
+
+	return s.policies.WithTransaction(ctx, func(ctx context.Context) error {
+		marked, err := s.schedules.MarkScheduledChangeApplied(ctx, change.ID, p.Script, now)
+		if err != nil || marked == nil {
+			// A change cancelled since it was listed is simply skipped
+			return err
+		}
+		// If the policy was edited in the meantime, the change is picked up again on the next run
+		updated, err := s.policies.ApplyScript(ctx, ownerID, p.ID, p.Script, change.Script)
+		if err != nil {
+			return err
+		}
+		if updated == nil {
+			return errScheduledPolicyGone
+		}
+		return nil
+	})
+}
+
+// revertScheduledChange puts back the script a change replaced. If the published script was
+// changed again after the change was applied, that later edit is kept and the revert fails.
+func (s *ScheduleService) revertScheduledChange(ctx context.Context, change *repository.ScheduledChange, now time.Time) error {
+	ownerID, err := uuid.Parse(change.OwnerID)
+	if err != nil {
+		return err
+	}
+	if change.PreviousScript == nil {
+		_, err := s.schedules.MarkScheduledChangeFailed(ctx, change.ID, repository.ScheduledChangeApplied, "previous script was not recorded")
+		return err
+	}
+
+	err = s.policies.WithTransaction(ctx, func(ctx context.Context) error {
+		marked, err := s.schedules.MarkScheduledChangeReverted(ctx, change.ID, now)
+		if err != nil || marked == nil {
+			return err
+		}
+		updated, err := s.policies.ApplyScript(ctx, ownerID, change.PolicyID, change.Script, *change.PreviousScript)
+		if err != nil {
+			return err
+		}
+		if updated == nil {
+			return errScheduledPolicyGone
+		}
+		return nil
+	})
+	if errors.Is(err, errScheduledPolicyGone) {
+		_, err = s.schedules.MarkScheduledChangeFailed(ctx, change.ID, repository.ScheduledChangeApplied, "policy was edited, deleted or archived after the change was applied")
+		return err
+	}
+	return err
+}
+
+// checkOverlap rejects a window that overlaps a change already waiting to be applied or reverted.
+func (s *ScheduleService) checkOverlap(ctx context.Context, policyID primitive.ObjectID, from time.Time, until *time.Time) error {
+	open, err := s.schedules.ListOpenScheduledChanges(ctx, policyID)
+	if err != nil {
+		return err
+	}
+	for _, change := range open {
+		startsBeforeEnd := until == nil || change.EffectiveFrom.Before(*until)
+		endsAfterStart := change.EffectiveUntil == nil || change.EffectiveUntil.After(from)
+		if startsBeforeEnd && endsAfterStart {
+			return ErrScheduleConflict
+		}
+	}
+	return nil
+}
+
+func (s *ScheduleService) loadPolicy(ctx context.Context, userID uuid.UUID, id string) (*repository.Policy, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	existing, err := s.policies.FindPolicy(ctx, userID, objID)
+	if err != nil || existing == nil {
+		return nil, err
+	}
+	if existing.Status == repository.StatusArchived {
+		return nil, ErrPolicyArchived
+	}
+	return existing, nil
+}
+
+// parseWindow parses optional window bounds in the marketplace's time zone. The end of the
+// window must be in the future and after its start.
+func parseWindow(marketplace, from, until string) (*time.Time, *time.Time, error) {
+	var fromTime, untilTime *time.Time
+	if from != "" {
+		t, err := repository.ParseMarketplaceTime(marketplace, from)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrInvalidWindow, err)
+		}
+		fromTime = &t
+	}
+	if until != "" {
+		t, err := repository.ParseMarketplaceTime(marketplace, until)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrInvalidWindow, err)
+		}
+		if !t.After(time.Now()) {
+			return nil, nil, fmt.Errorf("%w: effective_until is in the past", ErrInvalidWindow)
+		}
+		untilTime = &t
+	}
+	if fromTime != nil && untilTime != nil && !untilTime.After(*fromTime) {
+		return nil, nil, fmt.Errorf("%w: effective_until must be after effective_from", ErrInvalidWindow)
+	}
+	return fromTime, untilTime, nil
+}
+
+func newScheduledChange(policyID primitive.ObjectID, ownerID, script string, from time.Time, until *time.Time) *repository.ScheduledChange {
+	return &repository.ScheduledChange{
+		PolicyID:       policyID,
+		OwnerID:        ownerID,
+		Script:         script,
+		EffectiveFrom:  from,
+		EffectiveUntil: until,
+		Status:         repository.ScheduledChangeScheduled,
+		CreatedAt:      time.Now().UTC(),
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type stalenessKey struct{}
+
+// staleness records the oldest stale copy served while handling a request.
+type staleness struct {
+	mu    sync.Mutex
+	since time.Time
+}
+
+// TrackStaleness returns a context in which reads record when they fall back to stale copies
+// of policies because the database is unavailable. StaleSince reports what they recorded.
+func TrackStaleness(ctx context.Context) context.Context {
+	return context.WithValue(ctx, stalenessKey{}, &staleness{})
+}
+
+// StaleSince returns when the oldest stale copy served under ctx was cached, reporting false
+// if every read was fresh or ctx is not tracking staleness.
+func StaleSince(ctx context.Context) (time.Time, bool) {
+	st, ok := ctx.Value(stalenessKey{}).(*staleness)
+	if !ok {
+		return time.Time{}, false
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.since, !st.since.IsZero()
+}
+
+func markStale(ctx context.Context, cachedAt time.Time) {
+	st, ok := ctx.Value(stalenessKey{}).(*staleness)
+	if !ok {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.since.IsZero() || cachedAt.Before(st.since) {
+		st.since = cachedAt
+	}
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+)
+
+// TrashSweeper periodically purges policies that have been in the trash for longer than the retention period.
+type TrashSweeper struct {
+	repo      repository.PolicyRepository
+	retention time.Duration
+	interval  time.Duration
+}
+
+// NewTrashSweeper creates a TrashSweeper that runs every interval.
+func NewTrashSweeper(repo repository.PolicyRepository, retention, interval time.Duration) *TrashSweeper {
+	return &TrashSweeper{repo: repo, retention: retention, interval: interval}
+}
+
+// Run sweeps immediately and then on every tick until ctx is cancelled.
+func (s *TrashSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.sweep(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *TrashSweeper) sweep(ctx context.Context) {
+	cutoff := time.Now().Add(-s.retention)
+	purged, err := s.repo.PurgeDeletedPolicies(ctx, cutoff)
+	if err != nil {
+		log.Printf("trash sweep failed: %v\n", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("purged %d policies deleted before %s\n", purged, cutoff.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// WebhookDispatcher periodically delivers policy events to webhook subscriptions.
+type WebhookDispatcher struct {
+	webhooks *WebhookService
+	interval time.Duration
+}
+
+// NewWebhookDispatcher creates a WebhookDispatcher that runs every interval.
+func NewWebhookDispatcher(webhooks *WebhookService, interval time.Duration) *WebhookDispatcher {
+	return &WebhookDispatcher{webhooks: webhooks, interval: interval}
+}
+
+// Run dispatches immediately and then on every tick until ctx is cancelled.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.webhooks.Dispatch(ctx, time.Now().UTC()); err != nil {
+			log.Printf("webhook dispatch failed: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
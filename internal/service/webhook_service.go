@@ -0,0 +1,329 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	mathrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	ErrInvalidWebhookURL = errors.New("webhook URL must be an absolute http or https URL")
+	ErrUnknownEventType  = errors.New("unknown event type")
+)
+
+// Headers sent with every webhook. The signature is the hex HMAC-SHA256, under the
+// subscription's secret, of the timestamp and the body joined by a dot, prefixed with "sha256=".
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+const (
+	// maxWebhookBackoff caps the wait between attempts at a delivery.
+	maxWebhookBackoff = time.Hour
+	// webhookFanOutBatch is how many events are fanned out to subscriptions at a time.
+	webhookFanOutBatch = 100
+	// webhookDeliveryBatch bounds the deliveries attempted per dispatch, so one pass cannot run forever.
+	webhookDeliveryBatch = 100
+	// webhookConcurrency bounds the deliveries a dispatcher makes at once, so that slow
+	// subscribers do not hold up the others.
+	webhookConcurrency = 8
+)
+
+var webhookEventTypes = []string{
+	repository.EventPolicyCreated,
+	repository.EventPolicyUpdated,
+	repository.EventPolicyDeleted,
+	repository.EventPolicyPurged,
+}
+
+// WebhookService manages webhook subscriptions and delivers policy events to them.
+type WebhookService struct {
+	webhooks    repository.WebhookRepository
+	outbox      repository.OutboxRepository
+	client      *http.Client
+	timeout     time.Duration
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// WebhookServiceInterface defines the contract for webhook subscriptions.
+type WebhookServiceInterface interface {
+	CreateSubscription(ctx context.Context, rawURL string, eventTypes []string, userID string) (*repository.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]*repository.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id string) (bool, error)
+	ListDeliveries(ctx context.Context, subscriptionID string, limit int) ([]*repository.WebhookDelivery, error)
+}
+
+// NewWebhookService creates a WebhookService whose deliveries time out after timeout. A
+// delivery is attempted up to maxAttempts times, waiting about backoff before the second
+// attempt and twice as long before each one after that.
+func NewWebhookService(webhooks repository.WebhookRepository, outbox repository.OutboxRepository, timeout time.Duration, maxAttempts int, backoff time.Duration) *WebhookService {
+	return &WebhookService{
+		webhooks:    webhooks,
+		outbox:      outbox,
+		client:      &http.Client{Timeout: timeout},
+		timeout:     timeout,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+	}
+}
+
+// CreateSubscription subscribes rawURL to policy events of eventTypes, or all of them if it is
+// empty, optionally only for userID's policies. The returned subscription carries its signing
+// secret, which is not shown again.
+func (s *WebhookService) CreateSubscription(ctx context.Context, rawURL string, eventTypes []string, userID string) (*repository.WebhookSubscription, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidWebhookURL
+	}
+	for _, eventType := range eventTypes {
+		if !slices.Contains(webhookEventTypes, eventType) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownEventType, eventType)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	sub := &repository.WebhookSubscription{
+		URL:        u.String(),
+		Secret:     hex.EncodeToString(secret),
+		EventTypes: eventTypes,
+		UserID:     userID,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := s.webhooks.CreateSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// ListSubscriptions lists every subscription, without their secrets.
+func (s *WebhookService) ListSubscriptions(ctx context.Context) ([]*repository.WebhookSubscription, error) {
+	subs, err := s.webhooks.ListSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range subs {
+		sub.Secret = ""
+	}
+	return subs, nil
+}
+
+func (s *WebhookService) DeleteSubscription(ctx context.Context, id string) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, nil
+	}
+	return s.webhooks.DeleteSubscription(ctx, objID)
+}
+
+// ListDeliveries lists up to limit of a subscription's most recent deliveries, with every
+// attempt made at each. It returns nil if there is no such subscription.
+func (s *WebhookService) ListDeliveries(ctx context.Context, subscriptionID string, limit int) ([]*repository.WebhookDelivery, error) {
+	objID, err := primitive.ObjectIDFromHex(subscriptionID)
+	if err != nil {
+		return nil, nil
+	}
+	sub, err := s.webhooks.GetSubscription(ctx, objID)
+	if err != nil || sub == nil {
+		return nil, err
+	}
+	return s.webhooks.ListDeliveries(ctx, objID, limit)
+}
+
+// Dispatch hands events recorded since the last dispatch to the subscriptions that want them,
+// then attempts the deliveries that are due, up to webhookConcurrency at a time.
+func (s *WebhookService) Dispatch(ctx context.Context, now time.Time) error {
+	if err := s.fanOut(ctx, now); err != nil {
+		return err
+	}
+
+	var remaining atomic.Int64
+	remaining.Store(webhookDeliveryBatch)
+	var g errgroup.Group
+	for range webhookConcurrency {
+		g.Go(func() error {
+			for remaining.Add(-1) >= 0 {
+				// Each delivery is claimed as a worker frees up, so its lease starts when its
+				// attempt does. The lease outlasts the request, so a delivery is only retried
+				// by another dispatcher if this one died while making it.
+				d, err := s.webhooks.ClaimDueDelivery(ctx, time.Now().UTC(), 2*s.timeout)
+				if err != nil || d == nil {
+					return err
+				}
+				if err := s.deliver(ctx, d); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// fanOut creates a delivery of every undispatched event for each subscription that wants it.
+// Deliveries are unique per event and subscription, so an interrupted fan-out is safe to repeat.
+func (s *WebhookService) fanOut(ctx context.Context, now time.Time) error {
+	for {
+		events, err := s.outbox.ListUndispatchedEvents(ctx, webhookFanOutBatch)
+		if err != nil || len(events) == 0 {
+			return err
+		}
+		subs, err := s.webhooks.ListSubscriptions(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			payload, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			for _, sub := range subs {
+				if !sub.Wants(e) {
+					continue
+				}
+				err := s.webhooks.CreateDelivery(ctx, &repository.WebhookDelivery{
+					SubscriptionID: sub.ID,
+					EventSeq:       e.Seq,
+					EventType:      e.Type,
+					Payload:        payload,
+					Status:         repository.DeliveryPending,
+					Attempts:       []repository.DeliveryAttempt{},
+					NextAttemptAt:  &now,
+					CreatedAt:      now,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			if err := s.outbox.MarkEventDispatched(ctx, e.ID, now); err != nil {
+				return err
+			}
+		}
+		if len(events) < webhookFanOutBatch {
+			return nil
+		}
+	}
+}
+
+// deliver makes one attempt at a claimed delivery and records its outcome, scheduling the next
+// attempt after a failure until maxAttempts is reached. The outcome is dropped if the lease on
+// the delivery ran out and another dispatcher claimed it meanwhile.
+func (s *WebhookService) deliver(ctx context.Context, d *repository.WebhookDelivery) error {
+	sub, err := s.webhooks.GetSubscription(ctx, d.SubscriptionID)
+	if err != nil {
+		return err
+	}
+
+	var attempt repository.DeliveryAttempt
+	if sub == nil {
+		attempt = repository.DeliveryAttempt{At: time.Now().UTC(), Error: "subscription was deleted"}
+	} else {
+		attempt = s.send(ctx, sub, d)
+	}
+
+	status := repository.DeliveryPending
+	var next *time.Time
+	attempts := len(d.Attempts) + 1
+	switch {
+	case attempt.Error == "":
+		status = repository.DeliverySucceeded
+	case sub == nil || attempts >= s.maxAttempts:
+		status = repository.DeliveryFailed
+	default:
+		at := attempt.At.Add(s.backoffFor(attempts))
+		next = &at
+	}
+
+	recorded, err := s.webhooks.RecordDeliveryAttempt(ctx, d.ID, *d.NextAttemptAt, attempt, status, next)
+	if err == nil && recorded == nil {
+		log.Printf("webhook delivery %s was claimed again before its attempt was recorded\n", d.ID.Hex())
+	}
+	return err
+}
+
+// send POSTs a delivery's payload to the subscription, signed with its secret. Any response
+// other than 2xx counts as a failure.
+func (s *WebhookService) send(ctx context.Context, sub *repository.WebhookSubscription, d *repository.WebhookDelivery) repository.DeliveryAttempt {
+	start := time.Now()
+	attempt := repository.DeliveryAttempt{At: start.UTC()}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	timestamp := strconv.FormatInt(start.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, d.EventType)
+	req.Header.Set(WebhookDeliveryHeader, d.ID.Hex())
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(sub.Secret, timestamp, d.Payload))
+
+	resp, err := s.client.Do(req)
+	attempt.Duration = time.Since(start)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	attempt.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		attempt.Error = "unexpected status " + resp.Status
+	}
+	return attempt
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of timestamp and payload joined by a dot, under
+// secret. Subscribers recompute it to check that a delivery is genuine, and check the
+// timestamp to reject replays.
+func SignWebhook(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoffFor is the wait before retrying a delivery that has failed attempts times. It doubles
+// with every attempt up to maxWebhookBackoff, and is jittered down by up to half so that
+// deliveries that failed together are not retried together.
+func (s *WebhookService) backoffFor(attempts int) time.Duration {
+	d := maxWebhookBackoff
+	if shift := attempts - 1; shift < 32 {
+		if scaled := s.backoff << shift; scaled > 0 && scaled < maxWebhookBackoff {
+			d = scaled
+		}
+	}
+	return d/2 + time.Duration(mathrand.Int64N(int64(d/2)+1))
+}
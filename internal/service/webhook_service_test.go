@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LittleAksMax/bids-policy-service/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestWebhookBackoff(t *testing.T) {
+	s := &WebhookService{backoff: 10 * time.Second}
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{4, 80 * time.Second},
+		{20, maxWebhookBackoff},
+		{100, maxWebhookBackoff},
+	}
+	for _, tt := range tests {
+		for range 100 {
+			got := s.backoffFor(tt.attempts)
+			if got < tt.want/2 || got > tt.want {
+				t.Fatalf("backoffFor(%d) = %s, want between %s and %s", tt.attempts, got, tt.want/2, tt.want)
+			}
+		}
+	}
+}
+
+func TestSignWebhook(t *testing.T) {
+	payload := []byte(`{"seq":1}`)
+	signature := SignWebhook("secret", "1700000000", payload)
+
+	if len(signature) != 64 {
+		t.Fatalf("expected a hex SHA-256 digest, got %q", signature)
+	}
+	if SignWebhook("secret", "1700000000", payload) != signature {
+		t.Fatal("expected signing to be deterministic")
+	}
+	if SignWebhook("other", "1700000000", payload) == signature {
+		t.Fatal("expected the signature to depend on the secret")
+	}
+	if SignWebhook("secret", "1700000001", payload) == signature {
+		t.Fatal("expected the signature to cover the timestamp")
+	}
+}
+
+// memoryWebhooks holds deliveries that are all due, to one subscription.
+type memoryWebhooks struct {
+	repository.WebhookRepository
+	sub *repository.WebhookSubscription
+
+	mu       sync.Mutex
+	due      []*repository.WebhookDelivery
+	recorded int
+}
+
+func (m *memoryWebhooks) ClaimDueDelivery(_ context.Context, now time.Time, lease time.Duration) (*repository.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.due) == 0 {
+		return nil, nil
+	}
+	d := m.due[0]
+	m.due = m.due[1:]
+	leasedUntil := now.Add(lease)
+	d.NextAttemptAt = &leasedUntil
+	return d, nil
+}
+
+func (m *memoryWebhooks) GetSubscription(context.Context, primitive.ObjectID) (*repository.WebhookSubscription, error) {
+	return m.sub, nil
+}
+
+func (m *memoryWebhooks) RecordDeliveryAttempt(_ context.Context, _ primitive.ObjectID, _ time.Time, attempt repository.DeliveryAttempt, status string, _ *time.Time) (*repository.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if status != repository.DeliverySucceeded {
+		return nil, errors.New(attempt.Error)
+	}
+	m.recorded++
+	return &repository.WebhookDelivery{Status: status}, nil
+}
+
+func TestDispatchDeliversConcurrently(t *testing.T) {
+	var inFlight, peak atomic.Int64
+	release := make(chan struct{})
+	var releaseOnce sync.Once
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		if n == webhookConcurrency {
+			// Hold the first requests until as many are in flight as a dispatcher allows
+			releaseOnce.Do(func() { close(release) })
+		}
+		<-release
+	}))
+	defer server.Close()
+
+	webhooks := &memoryWebhooks{sub: &repository.WebhookSubscription{URL: server.URL, Secret: "secret"}}
+	for range 2 * webhookConcurrency {
+		webhooks.due = append(webhooks.due, &repository.WebhookDelivery{ID: primitive.NewObjectID()})
+	}
+	s := NewWebhookService(webhooks, &memoryOutbox{}, 5*time.Second, 3, time.Second)
+
+	if err := s.Dispatch(context.Background(), time.Now()); err != nil {
+		t.Fatalf("expected dispatch to succeed, got %v", err)
+	}
+	if webhooks.recorded != 2*webhookConcurrency {
+		t.Fatalf("expected %d deliveries, got %d", 2*webhookConcurrency, webhooks.recorded)
+	}
+	if peak.Load() != webhookConcurrency {
+		t.Fatalf("expected up to %d deliveries at once, got %d", webhookConcurrency, peak.Load())
+	}
+}
@@ -2,6 +2,7 @@ package validation
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/LittleAksMax/bids-policy-service/internal/repository"
@@ -42,6 +43,31 @@ func (e *MarketplaceValidationError) Error() string {
 	return strings.Join(e.Fields, ", ") + " must be one of: " + allowedMarketplacesStr
 }
 
+type TagsValidationError struct {
+	utilsvalidation.ValidationError
+}
+
+func (e *TagsValidationError) Error() string {
+	return fmt.Sprintf("%s must contain at most %d non-blank tags of up to %d characters",
+		strings.Join(e.Fields, ", "), MaxTags, MaxTagLength)
+}
+
+type InitialStatusValidationError struct {
+	utilsvalidation.ValidationError
+}
+
+func (e *InitialStatusValidationError) Error() string {
+	return strings.Join(e.Fields, ", ") + " must be one of: " + repository.StatusDraft + ", " + repository.StatusActive
+}
+
+type EntityTypeValidationError struct {
+	utilsvalidation.ValidationError
+}
+
+func (e *EntityTypeValidationError) Error() string {
+	return strings.Join(e.Fields, ", ") + " must be one of: " + repository.EntityCampaign + ", " + repository.EntityAdGroup + ", " + repository.EntityTarget
+}
+
 type policyValidationError struct {
 	utilsvalidation.ValidationError
 	Details []error
@@ -2,14 +2,24 @@ package validation
 
 import (
 	"reflect"
+	"strings"
 
 	"github.com/LittleAksMax/bids-policy-service/internal/repository"
 	utilsvalidation "github.com/LittleAksMax/bids-util/validation"
 )
 
 // ValidateMarketplace checks fields with validate:"marketplace" using repository.IsValidMarketplace.
+// A string slice field must hold only valid marketplaces.
 func ValidateMarketplace(v interface{}) error {
 	invalid := utilsvalidation.ValidateByTag(v, "marketplace", func(field reflect.StructField, fv reflect.Value) bool {
+		if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.String {
+			for i := 0; i < fv.Len(); i++ {
+				if !repository.IsValidMarketplace(fv.Index(i).String()) {
+					return true
+				}
+			}
+			return false
+		}
 		if fv.Kind() != reflect.String {
 			return false
 		}
@@ -20,3 +30,63 @@ func ValidateMarketplace(v interface{}) error {
 	}
 	return nil
 }
+
+const (
+	MaxTags      = 32
+	MaxTagLength = 64
+)
+
+// ValidateTags checks fields with validate:"tags" are string slices of at most MaxTags
+// non-blank tags, each no longer than MaxTagLength.
+func ValidateTags(v interface{}) error {
+	invalid := utilsvalidation.ValidateByTag(v, "tags", func(field reflect.StructField, fv reflect.Value) bool {
+		if fv.Kind() != reflect.Slice || fv.Type().Elem().Kind() != reflect.String {
+			return true
+		}
+		if fv.Len() > MaxTags {
+			return true
+		}
+		for i := 0; i < fv.Len(); i++ {
+			tag := strings.TrimSpace(fv.Index(i).String())
+			if tag == "" || len(tag) > MaxTagLength {
+				return true
+			}
+		}
+		return false
+	})
+	if len(invalid) > 0 {
+		return &TagsValidationError{utilsvalidation.ValidationError{Fields: invalid}}
+	}
+	return nil
+}
+
+// ValidateInitialStatus checks fields with validate:"initialstatus" are empty or a status a
+// policy may be created with: draft or active.
+func ValidateInitialStatus(v interface{}) error {
+	invalid := utilsvalidation.ValidateByTag(v, "initialstatus", func(field reflect.StructField, fv reflect.Value) bool {
+		if fv.Kind() != reflect.String {
+			return true
+		}
+		switch fv.String() {
+		case "", repository.StatusDraft, repository.StatusActive:
+			return false
+		default:
+			return true
+		}
+	})
+	if len(invalid) > 0 {
+		return &InitialStatusValidationError{utilsvalidation.ValidationError{Fields: invalid}}
+	}
+	return nil
+}
+
+// ValidateEntityType checks fields with validate:"entitytype" name an entity a policy can be assigned to.
+func ValidateEntityType(v interface{}) error {
+	invalid := utilsvalidation.ValidateByTag(v, "entitytype", func(field reflect.StructField, fv reflect.Value) bool {
+		return fv.Kind() != reflect.String || !repository.IsValidEntityType(fv.String())
+	})
+	if len(invalid) > 0 {
+		return &EntityTypeValidationError{utilsvalidation.ValidationError{Fields: invalid}}
+	}
+	return nil
+}